@@ -0,0 +1,309 @@
+// Package agent implements the agent that runs on the nodes of sessions. It reports the
+// health of the node to the API and runs the commands the API sends it.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/agent/protocol"
+)
+
+// AgentVersion is reported to the API with every heartbeat. It is set at build time.
+var AgentVersion = "dev"
+
+// maxOutputSize caps the output returned for exec commands.
+const maxOutputSize = 1024 * 1024
+
+type Config struct {
+	APIURL string `env:"UNWEAVE_AGENT_API_URL"`
+	Token  string `env:"UNWEAVE_AGENT_TOKEN"`
+	// HeartbeatInterval is used until the API tells the agent otherwise.
+	HeartbeatInterval time.Duration `env:"-"`
+	// PollTimeout is how long the API may hold a poll request open.
+	PollTimeout time.Duration `env:"-"`
+}
+
+type Agent struct {
+	cfg     Config
+	client  *http.Client
+	started time.Time
+	home    string
+
+	mu       sync.Mutex
+	interval time.Duration
+}
+
+func New(cfg Config) (*Agent, error) {
+	if cfg.APIURL == "" || cfg.Token == "" {
+		return nil, errors.New("api url and token are required")
+	}
+	if cfg.HeartbeatInterval == 0 {
+		cfg.HeartbeatInterval = 15 * time.Second
+	}
+	if cfg.PollTimeout == 0 {
+		cfg.PollTimeout = 30 * time.Second
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return &Agent{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: cfg.PollTimeout + 30*time.Second},
+		started:  time.Now(),
+		home:     home,
+		interval: cfg.HeartbeatInterval,
+	}, nil
+}
+
+// Run sends heartbeats and runs commands until ctx is canceled or the API sends a
+// shutdown command.
+func (a *Agent) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log.Ctx(ctx).Info().Msgf("Starting agent %s (protocol v%d)", AgentVersion, protocol.Version)
+
+	go a.heartbeats(ctx)
+
+	for {
+		cmds, err := a.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to poll for commands")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(a.heartbeatInterval()):
+			}
+			continue
+		}
+		for _, cmd := range cmds {
+			res := a.handle(ctx, cmd)
+			if err = a.sendResult(ctx, res); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msgf("Failed to send result of command %s", cmd.ID)
+			}
+			if cmd.Kind == protocol.CommandShutdown {
+				log.Ctx(ctx).Info().Msg("Shutting down on request of the API")
+				return nil
+			}
+		}
+	}
+}
+
+func (a *Agent) heartbeatInterval() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.interval
+}
+
+func (a *Agent) heartbeats(ctx context.Context) {
+	for {
+		if err := a.heartbeat(ctx); err != nil && ctx.Err() == nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to send heartbeat")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.heartbeatInterval()):
+		}
+	}
+}
+
+func (a *Agent) heartbeat(ctx context.Context) error {
+	hb := protocol.Heartbeat{
+		AgentVersion:  AgentVersion,
+		Time:          time.Now().UTC(),
+		UptimeSeconds: int64(time.Since(a.started) / time.Second),
+	}
+	var res protocol.HeartbeatResponse
+	if err := a.do(ctx, http.MethodPost, protocol.PathHeartbeat, hb, &res); err != nil {
+		return err
+	}
+	if res.IntervalSeconds > 0 {
+		a.mu.Lock()
+		a.interval = time.Duration(res.IntervalSeconds) * time.Second
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+func (a *Agent) poll(ctx context.Context) ([]protocol.Command, error) {
+	path := fmt.Sprintf("%s?wait=%d", protocol.PathCommands, int(a.cfg.PollTimeout/time.Second))
+	var res protocol.PollResponse
+	if err := a.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Commands, nil
+}
+
+func (a *Agent) sendResult(ctx context.Context, res protocol.CommandResult) error {
+	path := strings.Replace(protocol.PathCommandResult, "{commandID}", res.ID, 1)
+	return a.do(ctx, http.MethodPost, path, res, nil)
+}
+
+func (a *Agent) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(a.cfg.APIURL, "/")+path, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(protocol.HeaderVersion, fmt.Sprint(protocol.Version))
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(res.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, path, res.Status, strings.TrimSpace(msg.String()))
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (a *Agent) handle(ctx context.Context, cmd protocol.Command) protocol.CommandResult {
+	log.Ctx(ctx).Info().Msgf("Running %s command %s", cmd.Kind, cmd.ID)
+
+	res := protocol.CommandResult{ID: cmd.ID}
+	var err error
+	switch cmd.Kind {
+	case protocol.CommandExec:
+		if cmd.Exec == nil {
+			err = errors.New("missing exec command")
+			break
+		}
+		res.ExitCode, res.Output, err = a.exec(ctx, *cmd.Exec)
+	case protocol.CommandFilePush:
+		if cmd.FilePush == nil {
+			err = errors.New("missing file push command")
+			break
+		}
+		err = a.pushFile(*cmd.FilePush)
+	case protocol.CommandShutdown:
+	default:
+		err = fmt.Errorf("unsupported command kind %q", cmd.Kind)
+	}
+	if err != nil {
+		res.ExitCode = -1
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func (a *Agent) exec(ctx context.Context, c protocol.ExecCommand) (int, string, error) {
+	if len(c.Args) == 0 {
+		return -1, "", errors.New("no program given")
+	}
+	if c.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(c.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Dir = a.home
+	if c.Dir != "" {
+		cmd.Dir = a.resolve(c.Dir)
+	}
+	out := &limitedBuffer{max: maxOutputSize}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), out.String(), nil
+	}
+	if err != nil {
+		return -1, out.String(), err
+	}
+	return 0, out.String(), nil
+}
+
+func (a *Agent) pushFile(c protocol.FilePushCommand) error {
+	if c.Path == "" {
+		return errors.New("no path given")
+	}
+	path := a.resolve(c.Path)
+	mode := os.FileMode(c.Mode)
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// Write to a temporary file first so that readers never see a partial file.
+	tmp := path + ".uwtmp"
+	if err := os.WriteFile(tmp, c.Content, mode); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return nil
+}
+
+func (a *Agent) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(a.home, path)
+}
+
+// limitedBuffer keeps the first max bytes written to it and drops the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
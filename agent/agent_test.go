@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/unweave/unweave/agent/protocol"
+)
+
+// fakeAPI is a minimal local implementation of the API side of the agent protocol.
+type fakeAPI struct {
+	t        *testing.T
+	mu       sync.Mutex
+	commands []protocol.Command
+	results  []protocol.CommandResult
+	beats    int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get(protocol.HeaderVersion) != "1" {
+		w.WriteHeader(http.StatusUpgradeRequired)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == protocol.PathHeartbeat:
+		f.beats++
+		_ = json.NewEncoder(w).Encode(protocol.HeartbeatResponse{IntervalSeconds: 1})
+	case r.Method == http.MethodGet && r.URL.Path == protocol.PathCommands:
+		cmds := f.commands
+		f.commands = nil
+		_ = json.NewEncoder(w).Encode(protocol.PollResponse{Commands: cmds})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/result"):
+		res := protocol.CommandResult{}
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			f.t.Error("Error decoding command result", err)
+		}
+		f.results = append(f.results, res)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_Agent_Runs_Commands_Until_Shutdown(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "hello.txt")
+
+	api := &fakeAPI{t: t, commands: []protocol.Command{
+		{ID: "1", Kind: protocol.CommandExec, Exec: &protocol.ExecCommand{Args: []string{"echo", "hello"}}},
+		{ID: "2", Kind: protocol.CommandExec, Exec: &protocol.ExecCommand{Args: []string{"sh", "-c", "exit 3"}}},
+		{ID: "3", Kind: protocol.CommandFilePush, FilePush: &protocol.FilePushCommand{Path: path, Mode: 0o600, Content: []byte("hi")}},
+		{ID: "4", Kind: "reboot"},
+		{ID: "5", Kind: protocol.CommandShutdown},
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	a, err := New(Config{APIURL: srv.URL, Token: "secret", PollTimeout: time.Second})
+	if err != nil {
+		t.Fatal("Error creating agent", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = a.Run(ctx); err != nil {
+		t.Fatal("Agent failed", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("Agent didn't shut down")
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if len(api.results) != 5 {
+		t.Fatalf("got %d results, want 5", len(api.results))
+	}
+	if r := api.results[0]; r.ExitCode != 0 || r.Output != "hello\n" {
+		t.Errorf("exec result = %+v, want exit code 0 and output hello", r)
+	}
+	if r := api.results[1]; r.ExitCode != 3 || r.Error != "" {
+		t.Errorf("exec result = %+v, want exit code 3", r)
+	}
+	if r := api.results[2]; r.Error != "" {
+		t.Errorf("file push failed: %s", r.Error)
+	}
+	if r := api.results[3]; r.Error == "" {
+		t.Error("unknown command kinds should fail")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "hi" {
+		t.Errorf("pushed file = %q, %v, want hi", data, err)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0o600 {
+		t.Errorf("pushed file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func Test_Agent_Wrong_Token_Should_Not_Run_Commands(t *testing.T) {
+	api := &fakeAPI{t: t, commands: []protocol.Command{
+		{ID: "1", Kind: protocol.CommandShutdown},
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	a, err := New(Config{APIURL: srv.URL, Token: "wrong", HeartbeatInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal("Error creating agent", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_ = a.Run(ctx)
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.commands) != 1 || len(api.results) != 0 {
+		t.Error("agent with an invalid token received commands")
+	}
+}
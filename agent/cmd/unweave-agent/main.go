@@ -0,0 +1,39 @@
+// unweave-agent runs on the nodes of sessions. It is installed and started by the API
+// once a node is running and is configured through environment variables.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/agent"
+	"github.com/unweave/unweave/tools/gonfig"
+)
+
+func main() {
+	cfg := agent.Config{}
+	gonfig.GetFromEnvVariables(&cfg)
+
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.RFC3339,
+	})
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	ctx = log.Logger.WithContext(ctx)
+
+	a, err := agent.New(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create agent")
+	}
+	if err = a.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Agent failed")
+	}
+}
@@ -0,0 +1,86 @@
+// Package protocol defines the messages exchanged between the node agent and the API.
+//
+// The agent authenticates with a per-session bearer token and talks to the API over
+// plain HTTP. It sends heartbeats to PathHeartbeat and long-polls PathCommands for
+// commands to run, reporting each result to PathCommandResult. Every request carries the
+// protocol version in HeaderVersion so that either side can reject peers it doesn't
+// understand. Breaking changes must bump Version and the path prefix.
+package protocol
+
+import "time"
+
+// Version is the version of the protocol implemented by this package.
+const Version = 1
+
+const (
+	// HeaderVersion is the header that carries the protocol version of the sender.
+	HeaderVersion = "Unweave-Agent-Protocol"
+
+	PathPrefix        = "/agent/v1"
+	PathHeartbeat     = PathPrefix + "/heartbeat"
+	PathCommands      = PathPrefix + "/commands"
+	PathCommandResult = PathPrefix + "/commands/{commandID}/result"
+)
+
+// Heartbeat is sent periodically by the agent to show that the node is healthy.
+type Heartbeat struct {
+	AgentVersion  string    `json:"agentVersion"`
+	Time          time.Time `json:"time"`
+	UptimeSeconds int64     `json:"uptimeSeconds"`
+}
+
+type HeartbeatResponse struct {
+	// IntervalSeconds is how often the API expects heartbeats.
+	IntervalSeconds int `json:"intervalSeconds"`
+}
+
+type CommandKind string
+
+const (
+	// CommandExec runs a process on the node and returns its exit code and output.
+	CommandExec CommandKind = "exec"
+	// CommandFilePush writes a file on the node.
+	CommandFilePush CommandKind = "filePush"
+	// CommandShutdown stops the agent once the result has been sent.
+	CommandShutdown CommandKind = "shutdown"
+)
+
+type ExecCommand struct {
+	// Args is the program and its arguments. It isn't run through a shell.
+	Args []string `json:"args"`
+	// Dir is the working directory. Defaults to the agent's home directory.
+	Dir string `json:"dir,omitempty"`
+	// TimeoutSeconds kills the process if it runs longer. Zero means no timeout.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+type FilePushCommand struct {
+	// Path is absolute or relative to the agent's home directory. Parent directories
+	// are created as needed.
+	Path    string `json:"path"`
+	Mode    uint32 `json:"mode"`
+	Content []byte `json:"content"`
+}
+
+// Command is an instruction from the API. Exactly one of the kind specific fields is
+// set, matching Kind.
+type Command struct {
+	ID       string           `json:"id"`
+	Kind     CommandKind      `json:"kind"`
+	Exec     *ExecCommand     `json:"exec,omitempty"`
+	FilePush *FilePushCommand `json:"filePush,omitempty"`
+}
+
+// PollResponse is returned from PathCommands. It is empty if no commands arrived while
+// the request was held open.
+type PollResponse struct {
+	Commands []Command `json:"commands"`
+}
+
+type CommandResult struct {
+	ID       string `json:"id"`
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output,omitempty"`
+	// Error is set if the command couldn't be run at all.
+	Error string `json:"error,omitempty"`
+}
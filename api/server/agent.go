@@ -0,0 +1,331 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/agent/protocol"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+const (
+	agentBinaryNodePath = ".unweave/unweave-agent"
+	agentEnvNodePath    = ".unweave/agent.env"
+	agentLogNodePath    = ".unweave/agent.log"
+	agentPIDNodePath    = ".unweave/agent.pid"
+)
+
+// agentBinaryPath is the path to the linux build of the node agent. The agent isn't
+// installed on nodes if it is empty. It is initialized when the API starts.
+var agentBinaryPath string
+
+var (
+	// agentHeartbeatInterval is how often agents are asked to send heartbeats.
+	agentHeartbeatInterval = 15 * time.Second
+	// agentHeartbeatTimeout is how long a session can go without heartbeats before it
+	// is degraded.
+	agentHeartbeatTimeout = 4 * agentHeartbeatInterval
+	// agentMaxPollWait caps how long a poll request from an agent is held open.
+	agentMaxPollWait = 60 * time.Second
+	// agentCommandTimeout is how long to wait for the result of a command that doesn't
+	// have a timeout of its own.
+	agentCommandTimeout = 5 * time.Minute
+	// agentCommandPollInterval is how often the db is checked for new commands and
+	// command results.
+	agentCommandPollInterval = time.Second
+)
+
+func hashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAgentToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate agent token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sendAgentCommand queues cmd for the session's agent and waits for its result.
+// Commands and results go through the db so that the agent can poll any replica.
+func sendAgentCommand(ctx context.Context, sessionID string, cmd protocol.Command) (protocol.CommandResult, error) {
+	cmd.ID = uuid.New().String()
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return protocol.CommandResult{}, fmt.Errorf("failed to marshal agent command: %w", err)
+	}
+	params := db.SessionAgentCommandCreateParams{
+		ID:        cmd.ID,
+		SessionID: sessionID,
+		Command:   data,
+	}
+	if err = db.Q.SessionAgentCommandCreate(ctx, params); err != nil {
+		return protocol.CommandResult{}, fmt.Errorf("failed to queue agent command: %w", err)
+	}
+	defer func() {
+		// The caller might have given up already but the command still needs cleaning up.
+		if e := db.Q.SessionAgentCommandDelete(context.Background(), cmd.ID); e != nil {
+			log.Ctx(ctx).Warn().Err(e).Msgf("Failed to delete agent command %s", cmd.ID)
+		}
+	}()
+
+	ticker := time.NewTicker(agentCommandPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return protocol.CommandResult{}, ctx.Err()
+		case <-ticker.C:
+		}
+
+		data, err := db.Q.SessionAgentCommandResultGet(ctx, cmd.ID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return protocol.CommandResult{}, fmt.Errorf("failed to get agent command result: %w", err)
+		}
+		res := protocol.CommandResult{}
+		if err = json.Unmarshal(data, &res); err != nil {
+			return protocol.CommandResult{}, fmt.Errorf("failed to unmarshal agent command result: %w", err)
+		}
+		return res, nil
+	}
+}
+
+// pollAgentCommands returns the queued commands of a session, waiting up to wait for one
+// to arrive. Returned commands are marked as delivered and aren't returned again.
+func pollAgentCommands(ctx context.Context, sessionID string, wait time.Duration) ([]protocol.Command, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(agentCommandPollInterval)
+	defer ticker.Stop()
+
+	for {
+		rows, err := db.Q.SessionAgentCommandsDeliver(ctx, sessionID)
+		if err != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("failed to get agent commands: %w", err)
+		}
+		if len(rows) > 0 {
+			cmds := make([]protocol.Command, 0, len(rows))
+			for _, r := range rows {
+				cmd := protocol.Command{}
+				if err = json.Unmarshal(r, &cmd); err != nil {
+					log.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal agent command")
+					continue
+				}
+				cmds = append(cmds, cmd)
+			}
+			return cmds, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			return []protocol.Command{}, nil
+		case <-ctx.Done():
+			return []protocol.Command{}, nil
+		}
+	}
+}
+
+// completeAgentCommand stores the result of a command for the caller waiting for it.
+// Results of commands that were sent to another session or that nobody waits for
+// anymore are dropped.
+func completeAgentCommand(ctx context.Context, sessionID string, res protocol.CommandResult) (bool, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal agent command result: %w", err)
+	}
+	params := db.SessionAgentCommandCompleteParams{
+		ID:        res.ID,
+		SessionID: sessionID,
+		Result:    data,
+	}
+	if _, err = db.Q.SessionAgentCommandComplete(ctx, params); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to complete agent command: %w", err)
+	}
+	return true, nil
+}
+
+// installAgent copies the agent to the session's node and starts it. A new token is
+// issued on every install, which invalidates the token of any previous agent.
+func installAgent(ctx context.Context, sessionID string, conn types.ConnectionInfo) error {
+	if agentBinaryPath == "" || apiPublicURL == "" {
+		log.Ctx(ctx).Debug().Msg("Node agent disabled, skipping install")
+		return nil
+	}
+
+	token, err := newAgentToken()
+	if err != nil {
+		return err
+	}
+	params := db.SessionAgentCreateParams{
+		SessionID: sessionID,
+		TokenHash: hashAgentToken(token),
+	}
+	if err = db.Q.SessionAgentCreate(ctx, params); err != nil {
+		return fmt.Errorf("failed to create session agent in db: %w", err)
+	}
+
+	bin, err := os.Open(agentBinaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to open agent binary: %w", err)
+	}
+	defer bin.Close()
+
+	if err = pushNodeFile(ctx, conn, agentBinaryNodePath, 0o755, bin); err != nil {
+		return fmt.Errorf("failed to copy agent to node: %w", err)
+	}
+	env := fmt.Sprintf("UNWEAVE_AGENT_API_URL=%s\nUNWEAVE_AGENT_TOKEN=%s\n",
+		shellQuote([]string{apiPublicURL}), token)
+	if err = pushNodeFile(ctx, conn, agentEnvNodePath, 0o600, strings.NewReader(env)); err != nil {
+		return fmt.Errorf("failed to copy agent config to node: %w", err)
+	}
+
+	// Stop the agent of a previous install before starting the new one.
+	cmd := fmt.Sprintf("(test -f %[4]s && kill $(cat %[4]s) 2>/dev/null); "+
+		"set -a && . ./%[2]s && set +a && "+
+		"nohup ./%[1]s >> %[3]s 2>&1 < /dev/null & echo $! > %[4]s",
+		agentBinaryNodePath, agentEnvNodePath, agentLogNodePath, agentPIDNodePath)
+	code, out, err := runNodeCommand(ctx, conn, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+	if code != 0 {
+		return fmt.Errorf("failed to start agent, exit code %d: %s", code, out)
+	}
+
+	log.Ctx(ctx).Info().Msg("Installed node agent")
+	return nil
+}
+
+// checkAgentHeartbeat degrades a running session if its agent stopped sending heartbeats
+// and restores it once they resume. Sessions without an agent are left alone.
+func checkAgentHeartbeat(ctx context.Context, sessionID string) error {
+	agent, err := db.Q.SessionAgentGet(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get session agent from db: %w", err)
+	}
+	sess, err := db.Q.SessionGet(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session from db: %w", err)
+	}
+
+	last := agent.InstalledAt
+	if agent.LastHeartbeatAt.Valid {
+		last = agent.LastHeartbeatAt.Time
+	}
+	healthy := time.Since(last) < agentHeartbeatTimeout
+
+	var status db.UnweaveSessionStatus
+	switch {
+	case sess.Status == db.UnweaveSessionStatusRunning && !healthy:
+		log.Ctx(ctx).Warn().Msgf("No heartbeat from node agent since %s, session degraded", last.Format(time.RFC3339))
+		status = db.UnweaveSessionStatusDegraded
+	case sess.Status == db.UnweaveSessionStatusDegraded && healthy:
+		log.Ctx(ctx).Info().Msg("Node agent heartbeats resumed")
+		status = db.UnweaveSessionStatusRunning
+	default:
+		return nil
+	}
+
+	params := db.SessionStatusUpdateParams{ID: sessionID, Status: status}
+	if err = setSessionStatus(ctx, params); err != nil {
+		return fmt.Errorf("failed to update session status: %w", err)
+	}
+	return nil
+}
+
+// AgentCommand sends a command to the agent on the session's node and waits for its
+// result.
+func (s *SessionService) AgentCommand(ctx context.Context, sessionID string, cmd protocol.Command) (*protocol.CommandResult, error) {
+	agent, err := db.Q.SessionAgentGet(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &types.Error{
+				Code:    http.StatusConflict,
+				Message: "The session has no node agent",
+			}
+		}
+		return nil, fmt.Errorf("failed to get session agent from db: %w", err)
+	}
+	if !agent.LastHeartbeatAt.Valid || time.Since(agent.LastHeartbeatAt.Time) > agentHeartbeatTimeout {
+		return nil, &types.Error{
+			Code:       http.StatusConflict,
+			Message:    "The session's node agent isn't connected",
+			Suggestion: "Wait for the session to be running",
+		}
+	}
+
+	res, err := sendAgentCommand(ctx, sessionID, cmd)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, &types.Error{
+				Code:    http.StatusGatewayTimeout,
+				Message: "Timed out waiting for the node agent",
+				Err:     err,
+			}
+		}
+		return nil, err
+	}
+	return &res, nil
+}
+
+// AgentHeartbeat records a heartbeat from the agent of a session.
+func (s *SessionService) AgentHeartbeat(ctx context.Context, sessionID string, hb protocol.Heartbeat) error {
+	params := db.SessionAgentHeartbeatParams{
+		SessionID:       sessionID,
+		AgentVersion:    sql.NullString{String: hb.AgentVersion, Valid: hb.AgentVersion != ""},
+		ProtocolVersion: sql.NullInt32{Int32: protocol.Version, Valid: true},
+	}
+	if err := db.Q.SessionAgentHeartbeat(ctx, params); err != nil {
+		return fmt.Errorf("failed to record agent heartbeat: %w", err)
+	}
+	return nil
+}
+
+// AgentCommandResult hands the result of a command to the caller waiting for it.
+func (s *SessionService) AgentCommandResult(ctx context.Context, sessionID string, res protocol.CommandResult) error {
+	ok, err := completeAgentCommand(ctx, sessionID, res)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &types.Error{
+			Code:    http.StatusNotFound,
+			Message: fmt.Sprintf("Command %s not found", res.ID),
+		}
+	}
+	return nil
+}
+
+// AgentPoll waits for commands for the agent of a session.
+func (s *SessionService) AgentPoll(ctx context.Context, sessionID string, wait time.Duration) ([]protocol.Command, error) {
+	if wait > agentMaxPollWait {
+		wait = agentMaxPollWait
+	}
+	return pollAgentCommands(ctx, sessionID, wait)
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/artifacts"
+	"github.com/unweave/unweave/artifacts/local"
+	"github.com/unweave/unweave/artifacts/s3"
+	"github.com/unweave/unweave/db"
+)
+
+var defaultArtifactTimeout = 10 * time.Minute
+
+// artifactStore is initialized when the API starts. Artifacts aren't collected if no
+// store is configured.
+var artifactStore artifacts.Store
+
+type ArtifactStoreConfig struct {
+	// Store is either local or s3. Artifacts aren't collected if it is empty.
+	Store string `json:"store" env:"UNWEAVE_ARTIFACT_STORE"`
+	// LocalPath is the directory artifacts are stored in by the local store.
+	LocalPath         string `json:"localPath" env:"UNWEAVE_ARTIFACT_LOCAL_PATH"`
+	S3Endpoint        string `json:"s3Endpoint" env:"UNWEAVE_ARTIFACT_S3_ENDPOINT"`
+	S3Region          string `json:"s3Region" env:"UNWEAVE_ARTIFACT_S3_REGION"`
+	S3Bucket          string `json:"s3Bucket" env:"UNWEAVE_ARTIFACT_S3_BUCKET"`
+	S3Prefix          string `json:"s3Prefix" env:"UNWEAVE_ARTIFACT_S3_PREFIX"`
+	S3AccessKeyID     string `json:"s3AccessKeyID" env:"UNWEAVE_ARTIFACT_S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `json:"s3SecretAccessKey" env:"UNWEAVE_ARTIFACT_S3_SECRET_ACCESS_KEY"`
+	S3Insecure        bool   `json:"s3Insecure" env:"UNWEAVE_ARTIFACT_S3_INSECURE"`
+}
+
+func newArtifactStore(cfg ArtifactStoreConfig) (artifacts.Store, error) {
+	switch cfg.Store {
+	case "":
+		return nil, nil
+	case "local":
+		if cfg.LocalPath == "" {
+			return nil, fmt.Errorf("the local artifact store requires a path")
+		}
+		return local.NewStore(cfg.LocalPath)
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			return nil, fmt.Errorf("the s3 artifact store requires an endpoint and a bucket")
+		}
+		return s3.NewStore(s3.Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			Prefix:          cfg.S3Prefix,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			Insecure:        cfg.S3Insecure,
+		})
+	}
+	return nil, fmt.Errorf("invalid artifact store %q", cfg.Store)
+}
+
+// ArtifactsV1 versions the artifact spec stored in the DB.
+type ArtifactsV1 struct {
+	Version int                `json:"version"`
+	Spec    types.ArtifactSpec `json:"spec"`
+}
+
+// parseArtifacts decodes the artifact spec of a session. Sessions created before
+// artifacts were supported don't collect any.
+func parseArtifacts(data json.RawMessage) (types.ArtifactSpec, error) {
+	a := ArtifactsV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &a); err != nil {
+			return types.ArtifactSpec{}, fmt.Errorf("failed to unmarshal artifact spec: %w", err)
+		}
+	}
+	return a.Spec, nil
+}
+
+// artifactKey returns the key an artifact is stored under. The key is derived from a
+// hash of the path on the node so that paths can't escape the session's prefix in the
+// store. The path itself is recorded in the db.
+func artifactKey(sessionID, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "sessions/" + sessionID + "/" + hex.EncodeToString(sum[:])
+}
+
+func artifactFromDB(a db.UnweaveSessionArtifact) types.Artifact {
+	return types.Artifact{
+		ID:        a.ID,
+		SessionID: a.SessionID,
+		Path:      a.Path,
+		Size:      a.Size,
+		SHA256:    a.Sha256,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+// collectArtifacts copies the files declared in the session's artifact spec from the node
+// to the artifact store. Paths that don't exist on the node are skipped.
+func collectArtifacts(ctx context.Context, sess db.UnweaveSession) error {
+	if artifactStore == nil {
+		return nil
+	}
+	if sess.Status != db.UnweaveSessionStatusRunning && sess.Status != db.UnweaveSessionStatusDegraded {
+		return nil
+	}
+	spec, err := parseArtifacts(sess.Artifacts)
+	if err != nil {
+		return err
+	}
+	if len(spec.Paths) == 0 {
+		return nil
+	}
+
+	timeout := defaultArtifactTimeout
+	if spec.TimeoutSeconds > 0 {
+		timeout = time.Duration(spec.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, _, err := connectionWithHostKey(ctx, sess.ID)
+	if err != nil {
+		return err
+	}
+	sc, closeSFTP, err := dialSFTP(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer closeSFTP()
+
+	count := 0
+	for _, p := range spec.Paths {
+		walker := sc.Walk(path.Clean(p))
+		for walker.Step() {
+			if err = ctx.Err(); err != nil {
+				return fmt.Errorf("artifact collection timed out after %s: %w", timeout, err)
+			}
+			if err = walker.Err(); err != nil {
+				if os.IsNotExist(err) {
+					log.Ctx(ctx).Warn().Msgf("Artifact path %s doesn't exist on the node", p)
+					continue
+				}
+				return fmt.Errorf("failed to walk %s: %w", walker.Path(), err)
+			}
+			if !walker.Stat().Mode().IsRegular() {
+				continue
+			}
+			if err = storeArtifact(ctx, sc, sess.ID, walker.Path(), walker.Stat().Size()); err != nil {
+				return err
+			}
+			count++
+		}
+	}
+
+	log.Ctx(ctx).Info().Msgf("Collected %d artifacts from session %s", count, sess.ID)
+	return nil
+}
+
+func storeArtifact(ctx context.Context, sc *sftp.Client, sessionID, name string, size int64) error {
+	f, err := sc.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	key := artifactKey(sessionID, name)
+	if err = artifactStore.Put(ctx, key, io.TeeReader(f, h), size); err != nil {
+		return fmt.Errorf("failed to store %s: %w", name, err)
+	}
+
+	params := db.SessionArtifactAddParams{
+		SessionID: sessionID,
+		Path:      name,
+		Key:       key,
+		Size:      size,
+		Sha256:    hex.EncodeToString(h.Sum(nil)),
+	}
+	if _, err = db.Q.SessionArtifactAdd(ctx, params); err != nil {
+		return fmt.Errorf("failed to add artifact to db: %w", err)
+	}
+	return nil
+}
+
+func (s *SessionService) Artifacts(ctx context.Context, sessionID string) ([]types.Artifact, error) {
+	rows, err := db.Q.SessionArtifactsList(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artifacts from db: %w", err)
+	}
+	res := make([]types.Artifact, 0, len(rows))
+	for _, a := range rows {
+		res = append(res, artifactFromDB(a))
+	}
+	return res, nil
+}
+
+// OpenArtifact returns the artifact and its content. The caller must close the reader.
+func (s *SessionService) OpenArtifact(ctx context.Context, sessionID, artifactID string) (types.Artifact, io.ReadCloser, error) {
+	notFound := &types.Error{
+		Code:    http.StatusNotFound,
+		Message: "Artifact not found",
+	}
+
+	a, err := db.Q.SessionArtifactGet(ctx, artifactID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.Artifact{}, nil, notFound
+		}
+		return types.Artifact{}, nil, fmt.Errorf("failed to get artifact from db: %w", err)
+	}
+	if a.SessionID != sessionID {
+		return types.Artifact{}, nil, notFound
+	}
+	if artifactStore == nil {
+		return types.Artifact{}, nil, &types.Error{
+			Code:       http.StatusServiceUnavailable,
+			Message:    "No artifact store is configured",
+			Suggestion: "Set UNWEAVE_ARTIFACT_STORE to enable artifacts",
+		}
+	}
+
+	r, err := artifactStore.Get(ctx, a.Key)
+	if err != nil {
+		if err == artifacts.ErrNotFound {
+			return types.Artifact{}, nil, &types.Error{
+				Code:    http.StatusGone,
+				Message: "Artifact is no longer in the artifact store",
+				Err:     err,
+			}
+		}
+		return types.Artifact{}, nil, err
+	}
+	return artifactFromDB(a), r, nil
+}
+
+// artifactFileName returns the name an artifact is downloaded as.
+func artifactFileName(a types.Artifact) string {
+	return strings.ReplaceAll(path.Base(a.Path), `"`, "")
+}
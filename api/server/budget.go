@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/runtime"
+)
+
+// budgetCheckInterval is how often project spend is checked against budgets.
+var budgetCheckInterval = time.Minute
+
+const budgetLeaseKey = "budgets"
+
+// BudgetV1 versions the project budget stored in the DB.
+type BudgetV1 struct {
+	Version int                 `json:"version"`
+	Budget  types.ProjectBudget `json:"budget"`
+}
+
+// parseBudget decodes the budget of a project. Projects that haven't set one have no
+// limit.
+func parseBudget(data json.RawMessage) (types.ProjectBudget, error) {
+	b := BudgetV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &b); err != nil {
+			return types.ProjectBudget{}, fmt.Errorf("failed to unmarshal budget: %w", err)
+		}
+	}
+	return b.Budget, nil
+}
+
+func budgetThresholds(b types.ProjectBudget) []int {
+	if len(b.Thresholds) == 0 {
+		return types.DefaultBudgetThresholds
+	}
+	return b.Thresholds
+}
+
+// projectSpend returns the usage the project accrued since the start of the month.
+func projectSpend(ctx context.Context, projectID string, now time.Time) (int64, error) {
+	month := monthStart(now)
+	records, err := db.Q.SessionUsageListByProject(ctx, db.SessionUsageListByProjectParams{
+		ProjectID: projectID,
+		Until:     now,
+		Since:     month,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get usage from db: %w", err)
+	}
+	rows, err := aggregateUsage(records, month, now, now, nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].CostCents, nil
+}
+
+// checkBudget returns an error if the project has spent its budget and the budget is a
+// hard limit.
+func checkBudget(ctx context.Context, projectID string) error {
+	project, err := db.Q.ProjectGet(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project from db: %w", err)
+	}
+	budget, err := parseBudget(project.Budget)
+	if err != nil {
+		return err
+	}
+	if !budget.HardLimit || budget.MonthlyLimitCents == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	spent, err := projectSpend(ctx, projectID, now)
+	if err != nil {
+		return err
+	}
+	if spent < budget.MonthlyLimitCents {
+		return nil
+	}
+	return &types.Error{
+		Code: http.StatusForbidden,
+		Message: fmt.Sprintf("Project has spent $%.2f of its $%.2f monthly budget",
+			float64(spent)/100, float64(budget.MonthlyLimitCents)/100),
+		Suggestion: fmt.Sprintf("Raise the project's budget or wait until it resets on %s",
+			monthStart(now).AddDate(0, 1, 0).Format("January 2")),
+	}
+}
+
+func (p *ProjectService) Budget(ctx context.Context, projectID string) (*types.ProjectBudgetResponse, error) {
+	project, err := p.get(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	budget, err := parseBudget(project.Budget)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	spent, err := projectSpend(ctx, projectID, now)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ProjectBudgetResponse{
+		Budget:     budget,
+		Month:      now.Format("2006-01"),
+		SpentCents: spent,
+		Exceeded:   budget.MonthlyLimitCents > 0 && spent >= budget.MonthlyLimitCents,
+	}, nil
+}
+
+func (p *ProjectService) SetBudget(ctx context.Context, projectID string, budget types.ProjectBudget) error {
+	if err := budget.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(BudgetV1{Version: 1, Budget: budget})
+	if err != nil {
+		return fmt.Errorf("failed to marshal budget: %w", err)
+	}
+	params := db.ProjectBudgetUpdateParams{ID: projectID, Budget: data}
+	if err = db.Q.ProjectBudgetUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update budget: %w", err)
+	}
+	return nil
+}
+
+// BudgetMonitor alerts on the budget thresholds projects reach and terminates the
+// sessions of projects that exceed a hard limit. Only one replica monitors budgets at a
+// time.
+type BudgetMonitor struct {
+	rti      runtime.Initializer
+	interval time.Duration
+}
+
+func NewBudgetMonitor(rti runtime.Initializer, interval time.Duration) *BudgetMonitor {
+	return &BudgetMonitor{rti: rti, interval: interval}
+}
+
+func (m *BudgetMonitor) Start(ctx context.Context) {
+	leases.every(ctx, budgetLeaseKey, m.interval, func(leaseCtx context.Context) {
+		if err := m.Run(leaseCtx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to check budgets")
+		}
+	})
+}
+
+// Run checks the spend of every project with a budget.
+func (m *BudgetMonitor) Run(ctx context.Context) error {
+	projects, err := db.Q.ProjectsWithBudget(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get projects from db: %w", err)
+	}
+	for _, p := range projects {
+		c := log.Ctx(ctx).With().Str(ProjectIDCtxKey, p.ID).Logger().WithContext(ctx)
+		if err = m.check(c, p); err != nil {
+			log.Ctx(c).Error().Err(err).Msg("Failed to check project budget")
+		}
+	}
+	return nil
+}
+
+func (m *BudgetMonitor) check(ctx context.Context, project db.UnweaveProject) error {
+	budget, err := parseBudget(project.Budget)
+	if err != nil {
+		return err
+	}
+	if budget.MonthlyLimitCents == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	spent, err := projectSpend(ctx, project.ID, now)
+	if err != nil {
+		return err
+	}
+
+	thresholds := append([]int{}, budgetThresholds(budget)...)
+	sort.Ints(thresholds)
+	for _, t := range thresholds {
+		if spent*100 < budget.MonthlyLimitCents*int64(t) {
+			break
+		}
+		// Alerts are recorded so that every threshold is only published once a month.
+		if _, err = db.Q.ProjectBudgetAlertAdd(ctx, db.ProjectBudgetAlertAddParams{
+			ProjectID: project.ID,
+			Month:     monthStart(now),
+			Threshold: int32(t),
+			Spent:     spent,
+		}); err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return fmt.Errorf("failed to record budget alert: %w", err)
+		}
+		log.Ctx(ctx).Info().Msgf("Project reached %d%% of its budget", t)
+		publishEvent(ctx, project.ID, types.EventBudgetThreshold, types.BudgetEventData{
+			Month:      now.Format("2006-01"),
+			Threshold:  t,
+			SpentCents: spent,
+			LimitCents: budget.MonthlyLimitCents,
+		})
+	}
+
+	if !budget.HardLimit || !budget.TerminateOnExceeded || spent < budget.MonthlyLimitCents {
+		return nil
+	}
+	sessions, err := db.Q.MxSessionsGet(ctx, project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions from db: %w", err)
+	}
+	srv := NewCtxService(m.rti, project.OwnerID)
+	for _, s := range sessions {
+		if !isActiveSessionStatus(s.Status) {
+			continue
+		}
+		log.Ctx(ctx).Warn().Msgf("Terminating session %s, the project exceeded its budget", s.ID)
+		if err = srv.Session.Terminate(ctx, s.ID, TerminateOptions{}); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Failed to terminate session %s", s.ID)
+		}
+	}
+	return nil
+}
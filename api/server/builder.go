@@ -2,12 +2,14 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/unweave/unweave/api/types"
 	"github.com/unweave/unweave/db"
@@ -19,6 +21,26 @@ type BuildMetaDataV1 struct {
 	Error   string `json:"error"`
 }
 
+// imageRepo returns the namespace and repository name images for a project are pushed to.
+func imageRepo(accountID uuid.UUID, projectID string) (namespace, reponame string) {
+	// reponame must be lowercase for dockerhub
+	return strings.ToLower(accountID.String()), strings.ToLower(projectID)
+}
+
+// publishBuildStatus publishes the outcome of a build. Builds that errored are reported
+// as failed too.
+func publishBuildStatus(ctx context.Context, projectID, buildID string, status db.UnweaveBuildStatus, errMsg string) {
+	event := types.EventBuildFailed
+	if status == db.UnweaveBuildStatusSuccess {
+		event = types.EventBuildSucceeded
+	}
+	data := types.BuildEventData{BuildID: buildID, Status: string(status)}
+	if errMsg != "" {
+		data.Error = &errMsg
+	}
+	publishEvent(ctx, projectID, event, data)
+}
+
 type BuilderService struct {
 	srv *Service
 }
@@ -75,13 +97,13 @@ func (b *BuilderService) Build(ctx context.Context, projectID string, params *ty
 			if derr := db.Q.BuildUpdate(c, p); derr != nil {
 				log.Ctx(c).Error().Err(derr).Msg("Failed to set build error in DB")
 			}
+			publishBuildStatus(c, projectID, buildID, p.Status, errmeta)
 			return
 		}
 
 		// Push
 
-		reponame := strings.ToLower(projectID) // reponame must be lowercase for dockerhub
-		namespace := strings.ToLower(b.srv.cid.String())
+		namespace, reponame := imageRepo(b.srv.cid, projectID)
 		err = builder.Push(c, buildID, namespace, reponame)
 		if err != nil {
 			log.Ctx(c).Error().Err(err).Msg("Failed to push image")
@@ -101,6 +123,7 @@ func (b *BuilderService) Build(ctx context.Context, projectID string, params *ty
 			if e := db.Q.BuildUpdate(c, p); e != nil {
 				log.Ctx(c).Error().Err(e).Msg("Failed to set build error in DB")
 			}
+			publishBuildStatus(c, projectID, buildID, p.Status, fmt.Sprintf("Build push failed: %v", err))
 			return
 		}
 
@@ -117,6 +140,7 @@ func (b *BuilderService) Build(ctx context.Context, projectID string, params *ty
 		if err := db.Q.BuildUpdate(c, p); err != nil {
 			log.Ctx(c).Error().Err(err).Msg("Failed to set build success in DB")
 		}
+		publishBuildStatus(c, projectID, buildID, p.Status, "")
 	}()
 
 	return buildID, nil
@@ -139,3 +163,38 @@ func (b *BuilderService) GetLogs(ctx context.Context, buildID string) ([]types.L
 	}
 	return logs, nil
 }
+
+// GetImageURI returns the URI of the image produced by a successful build.
+func (b *BuilderService) GetImageURI(ctx context.Context, projectID, buildID string) (string, error) {
+	build, err := db.Q.BuildGet(ctx, buildID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", &types.Error{
+				Code:       http.StatusNotFound,
+				Message:    fmt.Sprintf("Build %q not found", buildID),
+				Suggestion: "Make sure the build id is valid",
+			}
+		}
+		return "", fmt.Errorf("failed to get build: %w", err)
+	}
+	if build.ProjectID != projectID {
+		return "", &types.Error{
+			Code:    http.StatusNotFound,
+			Message: fmt.Sprintf("Build %q not found", buildID),
+		}
+	}
+	if build.Status != db.UnweaveBuildStatusSuccess {
+		return "", &types.Error{
+			Code:       http.StatusBadRequest,
+			Message:    fmt.Sprintf("Build %q has status %q", buildID, build.Status),
+			Suggestion: "Only successful builds can be used to run code",
+		}
+	}
+
+	builder, err := b.srv.InitializeBuilder(ctx, build.BuilderType)
+	if err != nil {
+		return "", fmt.Errorf("failed to initializer builder: %w", err)
+	}
+	namespace, reponame := imageRepo(b.srv.cid, projectID)
+	return builder.GetImageURI(buildID, namespace, reponame), nil
+}
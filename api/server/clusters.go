@@ -0,0 +1,398 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/tools/random"
+)
+
+// clusterPollInterval is how often a cluster checks on the status of its members.
+var clusterPollInterval = 10 * time.Second
+
+// clusterKeyPath is where each member's copy of the cluster's private key is written,
+// relative to the home directory of the node's user.
+const clusterKeyPath = ".ssh/id_unweave_cluster"
+
+type ClusterService struct {
+	srv *Service
+}
+
+// Create launches a session on every node of the cluster. All members share the same
+// SSH key and are launched in the same region. Once they are running, a keypair for the
+// cluster is distributed to all of them so that they can reach each other over SSH. If
+// any member fails to launch, the members that were already launched are terminated.
+func (c *ClusterService) Create(ctx context.Context, projectID string, params types.ClusterCreateParams) (*types.Cluster, error) {
+	rt, err := c.srv.InitializeRuntime(ctx, params.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create runtime: %w", err)
+	}
+
+	ctx = log.With().
+		Stringer(types.RuntimeProviderKey, rt.GetProvider()).
+		Logger().
+		WithContext(ctx)
+
+	sshKey, err := fetchCredentials(ctx, c.srv.cid, params.SSHKeyName, params.SSHPublicKey, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup credentials: %w", err)
+	}
+
+	sp := types.SessionCreateParams{
+		Provider:   params.Provider,
+		NodeTypeID: params.NodeTypeID,
+		Region:     params.Region,
+		Labels:     params.Labels,
+		Readiness:  params.Readiness,
+	}
+
+	// The first member picks the region if none was requested. The rest of the members
+	// are pinned to it.
+	first, err := c.srv.Session.launch(ctx, rt, projectID, sp, sshKey)
+	if err != nil {
+		return nil, err
+	}
+
+	name := random.GenerateRandomPhrase(4, "-")
+	if params.Name != nil {
+		name = *params.Name
+	}
+	clusterID, err := db.Q.ClusterCreate(ctx, db.ClusterCreateParams{
+		Name:       name,
+		ProjectID:  projectID,
+		CreatedBy:  c.srv.cid,
+		Provider:   params.Provider.String(),
+		NodeTypeID: params.NodeTypeID,
+		Region:     first.Region,
+		Size:       int32(params.Size),
+	})
+	if err != nil {
+		if e := c.srv.Session.Terminate(context.Background(), first.ID, TerminateOptions{}); e != nil {
+			log.Ctx(ctx).Error().Err(e).Msgf("Failed to terminate session %s", first.ID)
+		}
+		return nil, fmt.Errorf("failed to create cluster in db: %w", err)
+	}
+
+	ctx = log.Ctx(ctx).With().Str(ClusterIDCtxKey, clusterID).Logger().WithContext(ctx)
+	sp.Region = &first.Region
+
+	for rank := 0; rank < params.Size; rank++ {
+		sessionID := first.ID
+		if rank > 0 {
+			session, err := c.srv.Session.launch(ctx, rt, projectID, sp, sshKey)
+			if err != nil {
+				c.fail(ctx, clusterID, fmt.Sprintf("Failed to launch member %d", rank))
+				return nil, err
+			}
+			sessionID = session.ID
+		}
+		scp := db.SessionSetClusterParams{
+			ID:          sessionID,
+			ClusterID:   sql.NullString{String: clusterID, Valid: true},
+			ClusterRank: sql.NullInt32{Int32: int32(rank), Valid: true},
+		}
+		if err = db.Q.SessionSetCluster(ctx, scp); err != nil {
+			if e := c.srv.Session.Terminate(context.Background(), sessionID, TerminateOptions{}); e != nil {
+				log.Ctx(ctx).Error().Err(e).Msgf("Failed to terminate session %s", sessionID)
+			}
+			c.fail(ctx, clusterID, fmt.Sprintf("Failed to add member %d", rank))
+			return nil, fmt.Errorf("failed to add session to cluster in db: %w", err)
+		}
+	}
+
+	return c.Get(ctx, projectID, clusterID)
+}
+
+// fail marks the cluster as errored and terminates all of its members.
+func (c *ClusterService) fail(ctx context.Context, clusterID string, msg string) {
+	log.Ctx(ctx).Error().Msgf("Tearing down cluster: %s", msg)
+
+	params := db.ClusterStatusUpdateParams{
+		ID:     clusterID,
+		Status: db.UnweaveSessionStatusError,
+		Error:  sql.NullString{String: msg, Valid: true},
+	}
+	if err := db.Q.ClusterStatusUpdate(ctx, params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to update cluster status")
+	}
+	c.terminateMembers(ctx, clusterID)
+}
+
+func (c *ClusterService) terminateMembers(ctx context.Context, clusterID string) {
+	// Always clean up the nodes, even if the request was canceled.
+	ctx = log.Ctx(ctx).WithContext(context.Background())
+
+	members, err := db.Q.ClusterMembersGet(ctx, sql.NullString{String: clusterID, Valid: true})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get cluster members from db")
+		return
+	}
+	for _, m := range members {
+		if m.Status == db.UnweaveSessionStatusTerminated {
+			continue
+		}
+		if err = c.srv.Session.Terminate(ctx, m.ID, TerminateOptions{}); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Failed to terminate cluster member %s", m.ID)
+		}
+	}
+}
+
+func (c *ClusterService) get(ctx context.Context, projectID, clusterID string) (db.UnweaveCluster, error) {
+	cl, err := db.Q.ClusterGet(ctx, clusterID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.UnweaveCluster{}, &types.Error{
+				Code:    http.StatusNotFound,
+				Message: "Cluster not found",
+			}
+		}
+		return db.UnweaveCluster{}, fmt.Errorf("failed to get cluster from db: %w", err)
+	}
+	if cl.ProjectID != projectID {
+		return db.UnweaveCluster{}, &types.Error{
+			Code:    http.StatusNotFound,
+			Message: "Cluster not found",
+		}
+	}
+	return cl, nil
+}
+
+func (c *ClusterService) toAPI(ctx context.Context, cl db.UnweaveCluster) (types.Cluster, error) {
+	members, err := db.Q.ClusterMembersGet(ctx, sql.NullString{String: cl.ID, Valid: true})
+	if err != nil {
+		return types.Cluster{}, fmt.Errorf("failed to get cluster members from db: %w", err)
+	}
+
+	cluster := types.Cluster{
+		ID:         cl.ID,
+		Name:       cl.Name,
+		Status:     types.SessionStatus(cl.Status),
+		Provider:   types.RuntimeProvider(cl.Provider),
+		NodeTypeID: cl.NodeTypeID,
+		Region:     cl.Region,
+		Size:       int(cl.Size),
+		CreatedAt:  cl.CreatedAt,
+		Members:    make([]types.ClusterMember, len(members)),
+	}
+	if cl.Error.Valid {
+		cluster.Error = &cl.Error.String
+	}
+
+	hosts := make([]string, len(members))
+	for i, m := range members {
+		connInfo, err := parseConnectionInfo(m.ConnectionInfo)
+		if err != nil {
+			return types.Cluster{}, err
+		}
+		cluster.Members[i] = types.ClusterMember{
+			Rank:      int(m.ClusterRank.Int32),
+			SessionID: m.ID,
+			Status:    types.SessionStatus(m.Status),
+		}
+		if connInfo.Host != "" {
+			conn := connInfo.toAPI()
+			cluster.Members[i].Connection = &conn
+		}
+		hosts[i] = connInfo.Host
+	}
+	if cl.Status == db.UnweaveSessionStatusRunning {
+		hostfile := strings.Join(hosts, "\n") + "\n"
+		cluster.Hostfile = &hostfile
+	}
+	return cluster, nil
+}
+
+func (c *ClusterService) Get(ctx context.Context, projectID, clusterID string) (*types.Cluster, error) {
+	cl, err := c.get(ctx, projectID, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := c.toAPI(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+func (c *ClusterService) List(ctx context.Context, projectID string) ([]types.Cluster, error) {
+	cls, err := db.Q.ClustersGet(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clusters from db: %w", err)
+	}
+
+	res := make([]types.Cluster, 0, len(cls))
+	for _, cl := range cls {
+		cluster, err := c.toAPI(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, cluster)
+	}
+	return res, nil
+}
+
+func (c *ClusterService) Terminate(ctx context.Context, projectID, clusterID string) error {
+	cl, err := c.get(ctx, projectID, clusterID)
+	if err != nil {
+		return err
+	}
+	if cl.Status == db.UnweaveSessionStatusTerminated {
+		return nil
+	}
+
+	// Mark the cluster as terminated first so that the members exiting isn't treated as
+	// a failure.
+	params := db.ClusterStatusUpdateParams{
+		ID:     clusterID,
+		Status: db.UnweaveSessionStatusTerminated,
+		Error:  cl.Error,
+	}
+	if err = db.Q.ClusterStatusUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update cluster status: %w", err)
+	}
+	c.terminateMembers(ctx, clusterID)
+	return nil
+}
+
+// Execute starts monitoring the members of a cluster in the background. The cluster
+// becomes running once all of its members are running and reachable. If any member
+// errors or exits, the whole cluster is torn down. The members must already be watched
+// for their status to change. Only one replica of the API monitors a cluster at a time.
+func (c *ClusterService) Execute(ctx context.Context, clusterID string) error {
+	if _, err := db.Q.ClusterGet(ctx, clusterID); err != nil {
+		return fmt.Errorf("failed to get cluster from db: %w", err)
+	}
+
+	leaseKey := clusterLeaseKey(clusterID)
+	leaseCtx, ok, err := leases.acquire(ctx, leaseKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Ctx(ctx).Info().Msgf("Cluster %s is already monitored", clusterID)
+		return nil
+	}
+
+	log.Ctx(ctx).Info().Msgf("Starting to monitor cluster %s", clusterID)
+
+	go func() {
+		defer leases.release(ctx, leaseKey)
+		c.monitor(leaseCtx, clusterID)
+	}()
+	return nil
+}
+
+func (c *ClusterService) monitor(ctx context.Context, clusterID string) {
+	ticker := time.NewTicker(clusterPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cl, err := db.Q.ClusterGet(ctx, clusterID)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to get cluster from db")
+			continue
+		}
+		if cl.Status != db.UnweaveSessionStatusInitializing && cl.Status != db.UnweaveSessionStatusRunning {
+			return
+		}
+
+		members, err := db.Q.ClusterMembersGet(ctx, sql.NullString{String: clusterID, Valid: true})
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to get cluster members from db")
+			continue
+		}
+		if len(members) < int(cl.Size) {
+			// The API stopped before all members were launched.
+			c.fail(ctx, clusterID, "Interrupted by an API restart while launching members")
+			return
+		}
+
+		ready := 0
+		for _, m := range members {
+			switch m.Status {
+			case db.UnweaveSessionStatusError, db.UnweaveSessionStatusTerminated:
+				c.fail(ctx, clusterID, fmt.Sprintf("Member %d (session %s) is %s", m.ClusterRank.Int32, m.ID, m.Status))
+				return
+			case db.UnweaveSessionStatusRunning, db.UnweaveSessionStatusDegraded:
+				if connInfo, err := parseConnectionInfo(m.ConnectionInfo); err == nil && connInfo.Host != "" {
+					ready++
+				}
+			}
+		}
+
+		if cl.Status == db.UnweaveSessionStatusInitializing && ready == len(members) {
+			if err = distributeClusterKey(ctx, members); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("Failed to distribute cluster key")
+				c.fail(ctx, clusterID, "Failed to set up SSH access between members")
+				return
+			}
+			params := db.ClusterStatusUpdateParams{
+				ID:     clusterID,
+				Status: db.UnweaveSessionStatusRunning,
+			}
+			if err = db.Q.ClusterStatusUpdate(ctx, params); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("Failed to update cluster status")
+				continue
+			}
+			log.Ctx(ctx).Info().Msgf("All %d cluster members are running", ready)
+		}
+	}
+}
+
+// distributeClusterKey generates a keypair for the cluster and gives it to every member.
+// Each member authorizes the public key and uses the private key for SSH connections to
+// the other members.
+func distributeClusterKey(ctx context.Context, members []db.ClusterMembersGetRow) error {
+	prv, pub, err := createSSHKeyPair()
+	if err != nil {
+		return fmt.Errorf("failed to generate cluster ssh key: %w", err)
+	}
+
+	conns := make([]types.ConnectionInfo, len(members))
+	hosts := make([]string, len(members))
+	for i, m := range members {
+		connInfo, err := parseConnectionInfo(m.ConnectionInfo)
+		if err != nil {
+			return err
+		}
+		conns[i] = connInfo.toAPI()
+		hosts[i] = connInfo.Host
+	}
+
+	sshConfig := fmt.Sprintf("Host %s\n  IdentityFile ~/%s\n  StrictHostKeyChecking accept-new\n",
+		strings.Join(hosts, " "), clusterKeyPath)
+	cmd := "touch ~/.ssh/config && chmod 600 ~/.ssh/config && " +
+		"(grep -qF " + shellQuote([]string{clusterKeyPath}) + " ~/.ssh/config || printf '%s' " +
+		shellQuote([]string{sshConfig}) + " >> ~/.ssh/config)"
+
+	for i, conn := range conns {
+		if err = authorizeNodeKey(ctx, conn, pub); err != nil {
+			return fmt.Errorf("member %d: %w", members[i].ClusterRank.Int32, err)
+		}
+		if err = pushNodeFile(ctx, conn, clusterKeyPath, 0o600, strings.NewReader(prv)); err != nil {
+			return fmt.Errorf("member %d: %w", members[i].ClusterRank.Int32, err)
+		}
+		code, out, err := runNodeCommand(ctx, conn, cmd)
+		if err != nil {
+			return fmt.Errorf("member %d: %w", members[i].ClusterRank.Int32, err)
+		}
+		if code != 0 {
+			return fmt.Errorf("member %d: failed to update ssh config, exit code %d: %s",
+				members[i].ClusterRank.Int32, code, out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type ConnectionInfoV1 struct {
+	Version int    `json:"version"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	User    string `json:"user"`
+}
+
+// ConnectionInfoV2 adds the node's SSH host key to ConnectionInfoV1 so that connections
+// to the node can be verified instead of trusting the node on first use.
+type ConnectionInfoV2 struct {
+	Version int    `json:"version"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	User    string `json:"user"`
+	// HostKey is the node's SSH host public key in the authorized_keys format. It is
+	// captured once the node is running.
+	HostKey string `json:"hostKey,omitempty"`
+}
+
+// parseConnectionInfo decodes connection info stored in the db in any version and
+// upgrades it to the latest version.
+func parseConnectionInfo(data json.RawMessage) (ConnectionInfoV2, error) {
+	v := struct {
+		Version int `json:"version"`
+	}{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return ConnectionInfoV2{}, fmt.Errorf("failed to unmarshal connection info: %w", err)
+	}
+
+	switch v.Version {
+	case 2:
+		c := ConnectionInfoV2{}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return ConnectionInfoV2{}, fmt.Errorf("failed to unmarshal connection info: %w", err)
+		}
+		return c, nil
+	default:
+		// Version 1 or connection info that was stored before it was versioned. The host
+		// key of these nodes is unknown.
+		c := ConnectionInfoV1{}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return ConnectionInfoV2{}, fmt.Errorf("failed to unmarshal connection info: %w", err)
+		}
+		return ConnectionInfoV2{Version: 2, Host: c.Host, Port: c.Port, User: c.User}, nil
+	}
+}
+
+func (c ConnectionInfoV2) toAPI() types.ConnectionInfo {
+	return types.ConnectionInfo{
+		Host:    c.Host,
+		Port:    c.Port,
+		User:    c.User,
+		HostKey: c.HostKey,
+	}
+}
+
+// fetchHostKey connects to the node and returns the host key it presents. Only the key
+// exchange has to succeed so the node doesn't need to accept the platform key yet. sshd
+// might not accept connections right after the provider reports the node as running so
+// connection failures are retried.
+func fetchHostKey(ctx context.Context, conn types.ConnectionInfo) (ssh.PublicKey, error) {
+	addr := net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port))
+	errGotKey := errors.New("got host key")
+
+	for attempt := 1; ; attempt++ {
+		var hostKey ssh.PublicKey
+		cfg := &ssh.ClientConfig{
+			User: conn.User,
+			HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+				hostKey = key
+				// Abort the handshake, we're only interested in the key.
+				return errGotKey
+			},
+			Timeout: 30 * time.Second,
+		}
+
+		d := net.Dialer{Timeout: cfg.Timeout}
+		c, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			_, _, _, err = ssh.NewClientConn(c, addr, cfg)
+			c.Close()
+			if hostKey != nil {
+				return hostKey, nil
+			}
+		}
+		if attempt == nodeSSHRetries {
+			return nil, fmt.Errorf("failed to get host key of %q: %w", addr, err)
+		}
+		log.Ctx(ctx).Warn().Err(err).Msgf("Failed to reach node, retrying (attempt %d/%d)", attempt, nodeSSHRetries)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(nodeSSHRetryInterval):
+		}
+	}
+}
+
+// knownHostsLine returns the known_hosts entry of a node. It is empty if the node's host
+// key is unknown.
+func knownHostsLine(conn types.ConnectionInfo) (string, error) {
+	if conn.HostKey == "" {
+		return "", nil
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(conn.HostKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse host key: %w", err)
+	}
+	addr := knownhosts.Normalize(net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port)))
+	return knownhosts.Line([]string{addr}, key), nil
+}
+
+// sshKnownHostsFile is the known_hosts file the ssh config of sessions points at. The
+// line returned by the known_hosts endpoint must be added to it.
+const sshKnownHostsFile = "~/.ssh/unweave_known_hosts"
+
+// hostKeyBackfillTimeout caps how long reading the connection info of a session waits
+// for the host key of a node that was launched before host keys were captured.
+var hostKeyBackfillTimeout = 30 * time.Second
+
+// sshConfigBlock returns a ~/.ssh/config entry that connects to the session's node with
+// `ssh <sessionID>` using the private key named sshKeyName in ~/.ssh. Host key checking
+// is strict so the node's known_hosts line must be added to sshKnownHostsFile.
+func sshConfigBlock(sessionID, sshKeyName string, conn types.ConnectionInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host %s\n", sessionID)
+	fmt.Fprintf(&b, "  HostName %s\n", conn.Host)
+	fmt.Fprintf(&b, "  Port %d\n", conn.Port)
+	fmt.Fprintf(&b, "  User %s\n", conn.User)
+	fmt.Fprintf(&b, "  IdentityFile ~/.ssh/%s\n", sshKeyName)
+	fmt.Fprintf(&b, "  IdentitiesOnly yes\n")
+	fmt.Fprintf(&b, "  UserKnownHostsFile %s\n", sshKnownHostsFile)
+	fmt.Fprintf(&b, "  StrictHostKeyChecking yes\n")
+	return b.String()
+}
+
+// connectionWithHostKey returns the connection info of a session whose node's host key
+// has been captured, along with the name of the session's SSH key. Nodes that were
+// launched before host keys were captured have theirs fetched and stored on first read.
+func connectionWithHostKey(ctx context.Context, sessionID string) (types.ConnectionInfo, string, error) {
+	sess, err := db.Q.MxSessionGet(ctx, sessionID)
+	if err != nil {
+		return types.ConnectionInfo{}, "", fmt.Errorf("failed to get session from db: %w", err)
+	}
+	connInfo, err := parseConnectionInfo(sess.ConnectionInfo)
+	if err != nil {
+		return types.ConnectionInfo{}, "", err
+	}
+	conn := connInfo.toAPI()
+
+	running := sess.Status == db.UnweaveSessionStatusRunning || sess.Status == db.UnweaveSessionStatusDegraded
+	if running && conn.Host != "" && conn.HostKey == "" {
+		c, cancel := context.WithTimeout(ctx, hostKeyBackfillTimeout)
+		defer cancel()
+		if updated, err := updateConnectionInfo(c, sessionID, conn); err == nil {
+			conn = updated
+		} else {
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to backfill host key")
+		}
+	}
+
+	if conn.Host == "" || conn.HostKey == "" {
+		return types.ConnectionInfo{}, "", &types.Error{
+			Code:       http.StatusConflict,
+			Message:    fmt.Sprintf("The host key of session %s is not known yet", sessionID),
+			Suggestion: "The host key is available once the session is running",
+		}
+	}
+	return conn, sess.SshKeyName, nil
+}
+
+// KnownHosts returns the known_hosts line of the session's node.
+func (s *SessionService) KnownHosts(ctx context.Context, sessionID string) (string, error) {
+	conn, _, err := connectionWithHostKey(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	line, err := knownHostsLine(conn)
+	if err != nil {
+		return "", err
+	}
+	return line + "\n", nil
+}
+
+// SSHConfig returns a ~/.ssh/config entry for the session's node.
+func (s *SessionService) SSHConfig(ctx context.Context, sessionID string) (string, error) {
+	conn, sshKeyName, err := connectionWithHostKey(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	return sshConfigBlock(sessionID, sshKeyName, conn), nil
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// Platform ledgers credit moves between. Account ledgers are named "account:<id>".
+const (
+	creditFundingLedger    = "platform:funding"
+	creditRevenueLedger    = "platform:revenue"
+	creditAdjustmentLedger = "platform:adjustments"
+)
+
+const creditLeaseKey = "credit"
+
+var (
+	// creditDebitInterval is how often accrued usage is debited from credit. Each debit is
+	// a transaction per session, so debiting more often mostly grows the ledger.
+	creditDebitInterval = 15 * time.Minute
+	// creditDebitWindow is how long after a session ended its usage is still debited.
+	creditDebitWindow = 7 * 24 * time.Hour
+	// maxCreditTransactions limits the number of transactions returned at once.
+	maxCreditTransactions int32 = 1000
+)
+
+// creditEnforced and creditMinRuntime are initialized when the API starts. When credit
+// is enforced, which it is unless opted out of, sessions are only created if the
+// account's credit covers the minimum runtime of the node.
+var (
+	creditEnforced   = true
+	creditMinRuntime = time.Hour
+)
+
+func creditTransactionFromDB(t db.UnweaveCreditTransaction) types.CreditTransaction {
+	res := types.CreditTransaction{
+		ID:          t.ID,
+		Kind:        types.CreditTransactionKind(t.Kind),
+		AmountCents: t.Amount,
+		Description: t.Description,
+		CreatedAt:   t.CreatedAt,
+	}
+	if t.SessionID.Valid {
+		res.SessionID = &t.SessionID.String
+	}
+	return res
+}
+
+func creditBalance(ctx context.Context, accountID uuid.UUID) (types.CreditBalance, error) {
+	balance, err := db.Q.CreditBalance(ctx, accountID)
+	if err != nil {
+		return types.CreditBalance{}, fmt.Errorf("failed to get credit balance from db: %w", err)
+	}
+	return types.CreditBalance{
+		AccountID:    accountID,
+		BalanceCents: balance,
+		Credit:       types.FormatCredit(balance),
+	}, nil
+}
+
+// checkCredit returns an error if credit is enforced and the account's balance doesn't
+// cover the minimum runtime of the node type. Node types without a known price only
+// require a positive balance.
+func checkCredit(ctx context.Context, accountID uuid.UUID, nodeType *types.NodeType) error {
+	if !creditEnforced {
+		return nil
+	}
+	balance, err := creditBalance(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	var required int64 = 1
+	if nodeType != nil && nodeType.Price != nil {
+		required = int64(math.Ceil(float64(*nodeType.Price) * creditMinRuntime.Hours()))
+	}
+	if balance.BalanceCents >= required {
+		return nil
+	}
+	return &types.Error{
+		Code: http.StatusPaymentRequired,
+		Message: fmt.Sprintf("Insufficient credit: the account has $%s but needs $%s to run the node for %s",
+			balance.Credit, types.FormatCredit(required), creditMinRuntime),
+		Suggestion: "Ask your platform team to top up your credit or pick a cheaper node type",
+	}
+}
+
+type CreditService struct {
+	srv *Service
+}
+
+// Balance returns the credit balance of the caller.
+func (c *CreditService) Balance(ctx context.Context) (types.CreditBalance, error) {
+	return creditBalance(ctx, c.srv.cid)
+}
+
+// Transactions returns the most recent credit transactions of the caller.
+func (c *CreditService) Transactions(ctx context.Context) ([]types.CreditTransaction, error) {
+	return creditTransactions(ctx, c.srv.cid)
+}
+
+func creditTransactions(ctx context.Context, accountID uuid.UUID) ([]types.CreditTransaction, error) {
+	params := db.CreditTransactionsListParams{AccountID: accountID, Limit: maxCreditTransactions}
+	rows, err := db.Q.CreditTransactionsList(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit transactions from db: %w", err)
+	}
+	res := make([]types.CreditTransaction, 0, len(rows))
+	for _, t := range rows {
+		res = append(res, creditTransactionFromDB(t))
+	}
+	return res, nil
+}
+
+// AddTransaction tops up or adjusts the credit of an account. It is only exposed on the
+// admin API.
+func (c *CreditService) AddTransaction(
+	ctx context.Context,
+	accountID uuid.UUID,
+	params types.CreditTransactionCreateParams,
+) (*types.CreditTransactionResponse, error) {
+	counter := creditFundingLedger
+	if params.Kind == types.CreditAdjustment {
+		counter = creditAdjustmentLedger
+	}
+	t, err := db.Q.CreditTransactionCreate(ctx, db.CreditTransactionCreateParams{
+		AccountID:     accountID,
+		Kind:          db.UnweaveCreditTransactionKind(params.Kind),
+		Amount:        params.AmountCents,
+		Description:   params.Description,
+		CounterLedger: counter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credit transaction: %w", err)
+	}
+	balance, err := creditBalance(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	log.Ctx(ctx).Info().Msgf("Added %s of %d cents to the credit of %s", params.Kind, params.AmountCents, accountID)
+	return &types.CreditTransactionResponse{Transaction: creditTransactionFromDB(t), Balance: balance}, nil
+}
+
+// CreditDebiter debits the usage sessions accrue from the credit of the accounts that
+// launched them. Only one replica debits usage at a time.
+type CreditDebiter struct {
+	interval time.Duration
+}
+
+func NewCreditDebiter(interval time.Duration) *CreditDebiter {
+	return &CreditDebiter{interval: interval}
+}
+
+func (d *CreditDebiter) Start(ctx context.Context) {
+	leases.every(ctx, creditLeaseKey, d.interval, func(leaseCtx context.Context) {
+		if err := d.Run(leaseCtx, time.Now()); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to debit usage")
+		}
+	})
+}
+
+// Run debits the usage every session accrued up to now that hasn't been debited yet.
+// Usage is debited in whole cents, the remainder is debited once it adds up to a cent.
+func (d *CreditDebiter) Run(ctx context.Context, now time.Time) error {
+	records, err := db.Q.SessionUsageToDebit(ctx, now.Add(-creditDebitWindow))
+	if err != nil {
+		return fmt.Errorf("failed to get usage from db: %w", err)
+	}
+	for _, u := range records {
+		end := now
+		if u.EndedAt.Valid && u.EndedAt.Time.Before(now) {
+			end = u.EndedAt.Time
+		}
+		if !end.After(u.StartedAt) {
+			continue
+		}
+		accrued := int64(math.Floor(end.Sub(u.StartedAt).Hours() * float64(u.Price.Int32)))
+		due := accrued - u.Debited
+		if due <= 0 {
+			continue
+		}
+
+		_, err = db.Q.CreditTransactionCreate(ctx, db.CreditTransactionCreateParams{
+			AccountID:     u.AccountID,
+			Kind:          db.UnweaveCreditTransactionKindUsage,
+			Amount:        -due,
+			Description:   fmt.Sprintf("%s on %s", u.NodeTypeID, u.Provider),
+			SessionID:     sql.NullString{String: u.SessionID, Valid: true},
+			CounterLedger: creditRevenueLedger,
+		})
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Failed to debit usage of session %s", u.SessionID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/events"
+	"github.com/unweave/unweave/events/memory"
+)
+
+// eventBus carries events between the replicas of the API. It is replaced with a bus
+// backed by Postgres when the API starts.
+var eventBus events.Bus = memory.NewBus()
+
+// eventStreamKeepAlive is how often a comment is sent on idle event streams so that
+// proxies don't close them.
+var eventStreamKeepAlive = 30 * time.Second
+
+// publishEvent queues the event for the project's webhooks and publishes it on the event
+// bus. Failing to publish an event never fails the caller.
+func publishEvent(ctx context.Context, projectID string, event types.EventType, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to marshal %s event", event)
+		return
+	}
+	e := types.Event{
+		ID:        "ev_" + uuid.NewString(),
+		Type:      event,
+		ProjectID: projectID,
+		CreatedAt: time.Now(),
+		Data:      raw,
+	}
+
+	// Deliveries are queued before the event is published so that the dispatchers it
+	// wakes up find them.
+	if err = queueWebhookDeliveries(ctx, e); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to queue webhook deliveries of %s", event)
+	}
+	if err = eventBus.Publish(ctx, e); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to publish %s", event)
+	}
+}
+
+// parseEventTypes parses a comma separated list of event types. An empty list matches
+// every event.
+func parseEventTypes(s string) ([]types.EventType, error) {
+	var res []types.EventType
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		et := types.EventType(t)
+		if !et.Valid() {
+			return nil, &types.Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Unknown event type %q", t),
+			}
+		}
+		res = append(res, et)
+	}
+	return res, nil
+}
+
+// streamEvents writes the events of the subscription to w as server-sent events until
+// the context is cancelled.
+func streamEvents(ctx context.Context, w http.ResponseWriter, sub *events.Subscription) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("response writer doesn't support streaming")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("failed to marshal event: %w", err)
+			}
+			if _, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,441 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+)
+
+// maxFilesArchiveSize caps the size of archives uploaded to sessions.
+var maxFilesArchiveSize int64 = 4 * 1024 * 1024 * 1024 // 4GB
+
+type archiveEntryKind int
+
+const (
+	archiveDir archiveEntryKind = iota
+	archiveFile
+	archiveSymlink
+)
+
+type archiveEntry struct {
+	// name is the cleaned path of the entry relative to the extraction directory.
+	name     string
+	kind     archiveEntryKind
+	mode     os.FileMode
+	linkname string
+}
+
+// cleanArchivePath validates the path of an archive entry and returns it relative to
+// the extraction directory. Entries must not escape the directory they're extracted to.
+func cleanArchivePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("archive entry %q has an absolute path", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("archive entry %q escapes the target directory", name)
+		}
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return "", nil
+	}
+	return name, nil
+}
+
+// checkSymlinkTarget returns an error if the symlink name, pointing to target, resolves
+// outside the extraction directory. Targets can't go through another symlink of the
+// archive since that one could point anywhere by the time the target is followed.
+func checkSymlinkTarget(links map[string]bool, name, target string) error {
+	if target == "" || path.IsAbs(target) || strings.Contains(target, "\\") {
+		return fmt.Errorf("symlink %q has an invalid target %q", name, target)
+	}
+	var parts []string
+	if dir := path.Dir(name); dir != "." {
+		parts = strings.Split(dir, "/")
+	}
+	segs := strings.Split(target, "/")
+	for i, s := range segs {
+		switch s {
+		case "", ".":
+			continue
+		case "..":
+			if len(parts) == 0 {
+				return fmt.Errorf("symlink %q points outside the target directory", name)
+			}
+			parts = parts[:len(parts)-1]
+			continue
+		}
+		parts = append(parts, s)
+		if p := strings.Join(parts, "/"); i < len(segs)-1 && links[p] {
+			return fmt.Errorf("symlink %q resolves through symlink %q", name, p)
+		}
+	}
+	return nil
+}
+
+// checkNotThroughSymlink returns an error if writing name would follow one of the
+// symlinks already extracted from the archive.
+func checkNotThroughSymlink(links map[string]bool, name string) error {
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		if p := strings.Join(parts[:i+1], "/"); links[p] {
+			return fmt.Errorf("archive entry %q is written through symlink %q", name, p)
+		}
+	}
+	return nil
+}
+
+// walkArchive calls fn for every directory, regular file and symlink in the archive in
+// the order they are stored. The reader passed to fn is only valid during the call.
+// Other entries such as devices are skipped. Symlinks that point outside the target
+// directory and entries that would be written through a symlink are rejected.
+func walkArchive(f io.ReadSeeker, format types.ArchiveFormat, fn func(e archiveEntry, r io.Reader) error) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind archive: %w", err)
+	}
+
+	if format == types.ArchiveZip {
+		ra, ok := f.(io.ReaderAt)
+		if !ok {
+			return fmt.Errorf("zip archive isn't seekable")
+		}
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("failed to get archive size: %w", err)
+		}
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			return badArchive(err)
+		}
+		for _, zf := range zr.File {
+			name, err := cleanArchivePath(zf.Name)
+			if err != nil {
+				return badArchive(err)
+			}
+			if name == "" {
+				continue
+			}
+			mode := zf.Mode()
+			e := archiveEntry{name: name, mode: mode.Perm()}
+			switch {
+			case mode.IsDir():
+				e.kind = archiveDir
+			case mode.IsRegular():
+				e.kind = archiveFile
+			default:
+				continue
+			}
+
+			rc, err := zf.Open()
+			if err != nil {
+				return badArchive(err)
+			}
+			err = fn(e, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = f
+	if format == types.ArchiveTarGz {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return badArchive(err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	links := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return badArchive(err)
+		}
+		name, err := cleanArchivePath(hdr.Name)
+		if err != nil {
+			return badArchive(err)
+		}
+		if name == "" {
+			continue
+		}
+		if err = checkNotThroughSymlink(links, name); err != nil {
+			return badArchive(err)
+		}
+		e := archiveEntry{name: name, mode: os.FileMode(hdr.Mode).Perm()}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			e.kind = archiveDir
+		case tar.TypeReg:
+			e.kind = archiveFile
+		case tar.TypeSymlink:
+			if err = checkSymlinkTarget(links, name, hdr.Linkname); err != nil {
+				return badArchive(err)
+			}
+			links[name] = true
+			e.kind = archiveSymlink
+			e.linkname = hdr.Linkname
+		default:
+			continue
+		}
+		if err = fn(e, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func badArchive(err error) error {
+	return &types.Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("Invalid archive: %s", err),
+		Err:     err,
+	}
+}
+
+// archiveManifest returns the sha256 of every regular file in the archive.
+func archiveManifest(f io.ReadSeeker, format types.ArchiveFormat) (map[string]string, error) {
+	hashes := map[string]string{}
+	err := walkArchive(f, format, func(e archiveEntry, r io.Reader) error {
+		if e.kind != archiveFile {
+			return nil
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return badArchive(err)
+		}
+		hashes[e.name] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	return hashes, err
+}
+
+// parseSHA256Sums parses the output of `sha256sum` run on paths relative to the current
+// directory. Names with backslashes or newlines are escaped by sha256sum and flagged
+// with a leading backslash.
+func parseSHA256Sums(out string) map[string]string {
+	unescape := strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+	hashes := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		escaped := strings.HasPrefix(line, `\`)
+		line = strings.TrimPrefix(line, `\`)
+		if len(line) < 67 || line[64:66] != "  " {
+			continue
+		}
+		hash, name := line[:64], line[66:]
+		if escaped {
+			name = unescape.Replace(name)
+		}
+		hashes[strings.TrimPrefix(name, "./")] = hash
+	}
+	return hashes
+}
+
+// remoteManifest returns the sha256 of every regular file under dir on the node. A
+// missing directory has no files.
+func remoteManifest(ctx context.Context, conn types.ConnectionInfo, dir string) (map[string]string, error) {
+	cmd := fmt.Sprintf("cd %s 2>/dev/null || exit 0; find . -type f -print0 | xargs -0 -r sha256sum",
+		shellQuote([]string{dir}))
+	code, out, err := runNodeCommand(ctx, conn, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash files on node: %w", err)
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("failed to hash files on node, exit code %d: %s", code, strings.TrimSpace(out))
+	}
+	return parseSHA256Sums(out), nil
+}
+
+// dialSFTP opens an SFTP session on the node. The session is closed when the context is
+// done.
+func dialSFTP(ctx context.Context, conn types.ConnectionInfo) (*sftp.Client, func(), error) {
+	client, err := dialNode(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("failed to start sftp session: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		sc.Close()
+		client.Close()
+	}()
+	return sc, func() { close(done) }, nil
+}
+
+func writeRemoteFile(sc *sftp.Client, target string, mode os.FileMode, r io.Reader) (int64, error) {
+	if err := sc.MkdirAll(path.Dir(target)); err != nil {
+		return 0, fmt.Errorf("failed to create directory of %s: %w", target, err)
+	}
+	f, err := sc.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", target, err)
+	}
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return n, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err = f.Close(); err != nil {
+		return n, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if mode != 0 {
+		if err = sc.Chmod(target, mode); err != nil {
+			return n, fmt.Errorf("failed to set mode of %s: %w", target, err)
+		}
+	}
+	return n, nil
+}
+
+// FilesManifest returns the sha256 of every file under dir on the session's node so that
+// clients can sync only the files that changed.
+func (s *SessionService) FilesManifest(ctx context.Context, sessionID, dir string) (types.FileManifest, error) {
+	conn, _, err := connectionWithHostKey(ctx, sessionID)
+	if err != nil {
+		return types.FileManifest{}, err
+	}
+	hashes, err := remoteManifest(ctx, conn, dir)
+	if err != nil {
+		return types.FileManifest{}, err
+	}
+
+	manifest := types.FileManifest{Path: dir, Files: make([]types.FileEntry, 0, len(hashes))}
+	for name, hash := range hashes {
+		manifest.Files = append(manifest.Files, types.FileEntry{Path: name, SHA256: hash})
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	return manifest, nil
+}
+
+// UploadFiles extracts an archive to a directory on the session's node over SFTP. In sync
+// mode files that are already up to date are skipped and, if requested, files that
+// aren't part of the sync are deleted.
+func (s *SessionService) UploadFiles(
+	ctx context.Context,
+	sessionID string,
+	params types.SessionFilesUploadParams,
+) (*types.SessionFilesUploadResponse, error) {
+	conn, _, err := connectionWithHostKey(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var remote, local map[string]string
+	keep := map[string]bool{}
+	if params.Sync {
+		if remote, err = remoteManifest(ctx, conn, params.Path); err != nil {
+			return nil, err
+		}
+		if local, err = archiveManifest(params.Archive, params.Format); err != nil {
+			return nil, err
+		}
+		for name := range local {
+			keep[name] = true
+		}
+		for _, f := range params.Manifest {
+			name, err := cleanArchivePath(f.Path)
+			if err != nil {
+				return nil, &types.Error{
+					Code:    http.StatusBadRequest,
+					Message: fmt.Sprintf("Invalid manifest: %s", err),
+				}
+			}
+			keep[name] = true
+		}
+	}
+
+	sc, closeSFTP, err := dialSFTP(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSFTP()
+
+	if err = sc.MkdirAll(params.Path); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", params.Path, err)
+	}
+
+	res := &types.SessionFilesUploadResponse{Path: params.Path}
+	err = walkArchive(params.Archive, params.Format, func(e archiveEntry, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		target := path.Join(params.Path, e.name)
+
+		switch e.kind {
+		case archiveDir:
+			if err := sc.MkdirAll(target); err != nil {
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+		case archiveSymlink:
+			_ = sc.Remove(target)
+			if err := sc.Symlink(e.linkname, target); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", target, err)
+			}
+		case archiveFile:
+			if params.Sync && remote[e.name] == local[e.name] {
+				res.Skipped++
+				return nil
+			}
+			n, err := writeRemoteFile(sc, target, e.mode, r)
+			if err != nil {
+				return err
+			}
+			res.Written++
+			res.Bytes += n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Delete {
+		for name := range remote {
+			if keep[name] {
+				continue
+			}
+			if err = sc.Remove(path.Join(params.Path, name)); err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", name, err)
+			}
+			res.Deleted++
+		}
+	}
+
+	log.Ctx(ctx).Info().
+		Int("written", res.Written).
+		Int("skipped", res.Skipped).
+		Int("deleted", res.Deleted).
+		Msgf("Uploaded files to %s", params.Path)
+	return res, nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/unweave/unweave/api/types"
+)
+
+func buildTar(t *testing.T, files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func Test_cleanArchivePath(t *testing.T) {
+	valid := map[string]string{
+		"a/b.txt":     "a/b.txt",
+		"./a/./b":     "a/b",
+		"dir/":        "dir",
+		"./":          "",
+		`win\style`:   "win/style",
+		"a//b///c.py": "a/b/c.py",
+	}
+	for in, want := range valid {
+		got, err := cleanArchivePath(in)
+		if err != nil {
+			t.Errorf("cleanArchivePath(%q) failed: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("cleanArchivePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"/etc/passwd", "../x", "a/../../x", `..\x`} {
+		if _, err := cleanArchivePath(in); err == nil {
+			t.Errorf("cleanArchivePath(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func Test_walkArchive_RejectsEscapingEntries(t *testing.T) {
+	f := buildTar(t, map[string]string{"../../.ssh/authorized_keys": "key"})
+	err := walkArchive(f, types.ArchiveTar, func(e archiveEntry, r io.Reader) error {
+		t.Errorf("unexpected entry %q", e.name)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("walkArchive succeeded, want error")
+	}
+}
+
+func Test_walkArchive_RejectsEscapingSymlinks(t *testing.T) {
+	link := func(name, target string) *tar.Header {
+		return &tar.Header{Name: name, Linkname: target, Typeflag: tar.TypeSymlink}
+	}
+	file := func(name string) *tar.Header {
+		return &tar.Header{Name: name, Mode: 0o644, Typeflag: tar.TypeReg}
+	}
+
+	tests := []struct {
+		name    string
+		entries []*tar.Header
+		wantErr bool
+	}{
+		{"relative link", []*tar.Header{file("data/a.txt"), link("latest", "data/a.txt")}, false},
+		{"link to parent inside", []*tar.Header{link("data/up", "..")}, false},
+		{"absolute link", []*tar.Header{link("ssh", "/root/.ssh")}, true},
+		{"escaping link", []*tar.Header{link("data/up", "../..")}, true},
+		{"link through link", []*tar.Header{link("data/up", ".."), link("out", "data/up/..")}, true},
+		{"write through link", []*tar.Header{link("ssh", "data"), file("ssh/authorized_keys")}, true},
+		{"overwrite link", []*tar.Header{link("key", "data/key"), file("key")}, true},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range tt.entries {
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		err := walkArchive(bytes.NewReader(buf.Bytes()), types.ArchiveTar, func(e archiveEntry, r io.Reader) error {
+			return nil
+		})
+		if tt.wantErr && err == nil {
+			t.Errorf("%s: walkArchive succeeded, want error", tt.name)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: walkArchive failed: %v", tt.name, err)
+		}
+	}
+}
+
+func Test_archiveManifest_Zip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("src/main.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("print('hi')\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = zw.Create("empty/"); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := archiveManifest(bytes.NewReader(buf.Bytes()), types.ArchiveZip)
+	if err != nil {
+		t.Fatal("archiveManifest failed", err)
+	}
+	sum := sha256.Sum256([]byte("print('hi')\n"))
+	want := hex.EncodeToString(sum[:])
+	if len(hashes) != 1 {
+		t.Fatalf("got %d hashes, want 1: %v", len(hashes), hashes)
+	}
+	if hashes["src/main.py"] != want {
+		t.Errorf("src/main.py = %q, want %q", hashes["src/main.py"], want)
+	}
+}
+
+func Test_parseSHA256Sums(t *testing.T) {
+	h1 := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	h2 := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	out := h1 + "  ./empty.txt\n" +
+		`\` + h2 + `  ./odd\nname` + "\n" +
+		"garbage\n"
+
+	got := parseSHA256Sums(out)
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2: %v", len(got), got)
+	}
+	if got["empty.txt"] != h1 {
+		t.Errorf("empty.txt = %q, want %q", got["empty.txt"], h1)
+	}
+	if got["odd\nname"] != h2 {
+		t.Errorf("odd\\nname = %q, want %q", got["odd\nname"], h2)
+	}
+}
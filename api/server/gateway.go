@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	gatewayAccountIDExt = "accountID"
+	gatewaySessionIDExt = "sessionID"
+)
+
+var errGatewayUnauthorized = errors.New("unauthorized")
+
+// Gateway is an SSH server that routes connections to the nodes of sessions. Users
+// connect with the session ID as the username, e.g. `ssh se_xxx@gateway`, and
+// authenticate with any of the SSH keys registered in their account. The gateway then
+// connects to the node with the platform key and proxies all channels to it.
+type Gateway struct {
+	cfg *ssh.ServerConfig
+}
+
+func NewGateway(hostKey ssh.Signer) *Gateway {
+	g := &Gateway{}
+	g.cfg = &ssh.ServerConfig{
+		PublicKeyCallback: g.authenticate,
+		ServerVersion:     "SSH-2.0-unweave-gateway",
+	}
+	g.cfg.AddHostKey(hostKey)
+	return g
+}
+
+// authenticate checks that key belongs to an account that has access to the project of
+// the session the user is connecting to.
+func (g *Gateway) authenticate(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	ctx := log.With().
+		Str(SessionIDCtxKey, meta.User()).
+		Stringer("remoteAddr", meta.RemoteAddr()).
+		Logger().WithContext(context.Background())
+
+	accountID, err := authorizeGatewayKey(ctx, meta.User(), key)
+	if err != nil {
+		if !errors.Is(err, errGatewayUnauthorized) {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to authenticate gateway connection")
+		}
+		return nil, errGatewayUnauthorized
+	}
+	return &ssh.Permissions{
+		Extensions: map[string]string{
+			gatewayAccountIDExt: accountID.String(),
+			gatewaySessionIDExt: meta.User(),
+		},
+	}, nil
+}
+
+func authorizeGatewayKey(ctx context.Context, sessionID string, key ssh.PublicKey) (uuid.UUID, error) {
+	// The platform key is never a user's key even if it was saved in an account.
+	if platformSigner != nil && bytes.Equal(platformSigner.PublicKey().Marshal(), key.Marshal()) {
+		return uuid.Nil, errGatewayUnauthorized
+	}
+	sess, err := db.Q.SessionGet(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, errGatewayUnauthorized
+		}
+		return uuid.Nil, fmt.Errorf("failed to get session from db: %w", err)
+	}
+	project, err := db.Q.ProjectGet(ctx, sess.ProjectID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get project from db: %w", err)
+	}
+
+	// Projects are only accessible to their owner so only the owner's keys can be used.
+	keys, err := db.Q.SSHKeysGet(ctx, project.OwnerID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get ssh keys from db: %w", err)
+	}
+	for _, k := range keys {
+		if !k.IsActive {
+			continue
+		}
+		pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(pk.Marshal(), key.Marshal()) {
+			return k.OwnerID, nil
+		}
+	}
+	return uuid.Nil, errGatewayUnauthorized
+}
+
+// ListenAndServe accepts connections on addr until ctx is canceled.
+func (g *Gateway) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %w", addr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	log.Ctx(ctx).Info().Msgf("🔑 SSH gateway listening on %s", addr)
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to accept gateway connection")
+			continue
+		}
+		go g.handleConn(ctx, c)
+	}
+}
+
+func (g *Gateway) handleConn(ctx context.Context, c net.Conn) {
+	defer c.Close()
+
+	sc, chans, reqs, err := ssh.NewServerConn(c, g.cfg)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msgf("Gateway handshake with %s failed", c.RemoteAddr())
+		return
+	}
+	defer sc.Close()
+	// Global requests such as remote port forwarding aren't supported.
+	go ssh.DiscardRequests(reqs)
+
+	sessionID := sc.Permissions.Extensions[gatewaySessionIDExt]
+	ctx = log.Ctx(ctx).With().
+		Str(AccountIDCtxKey, sc.Permissions.Extensions[gatewayAccountIDExt]).
+		Str(SessionIDCtxKey, sessionID).
+		Logger().WithContext(ctx)
+
+	log.Ctx(ctx).Info().Msgf("Gateway connection from %s", sc.RemoteAddr())
+
+	upstream, err := dialSession(ctx, sessionID)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to connect to session node")
+		msg := "Failed to connect to session"
+		var e *types.Error
+		if errors.As(err, &e) {
+			msg = e.Message
+		}
+		for nc := range chans {
+			rejectChannel(nc, msg)
+		}
+		return
+	}
+	defer upstream.Close()
+
+	go func() {
+		// Close the client connection if the node goes away.
+		_ = upstream.Wait()
+		sc.Close()
+	}()
+
+	for nc := range chans {
+		go proxyChannel(ctx, upstream, nc)
+	}
+}
+
+// dialSession opens an SSH connection to the node of a running session.
+func dialSession(ctx context.Context, sessionID string) (*ssh.Client, error) {
+	sess, err := db.Q.SessionGet(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session from db: %w", err)
+	}
+	// Degraded sessions are still reachable over SSH, only their agent is unhealthy.
+	if sess.Status != db.UnweaveSessionStatusRunning && sess.Status != db.UnweaveSessionStatusDegraded {
+		return nil, &types.Error{
+			Code:    http.StatusConflict,
+			Message: fmt.Sprintf("Session %s is %s", sessionID, sess.Status),
+		}
+	}
+	connInfo, err := parseConnectionInfo(sess.ConnectionInfo)
+	if err != nil {
+		return nil, err
+	}
+	return dialNode(ctx, connInfo.toAPI())
+}
+
+// rejectChannel refuses a channel. Session channels are accepted long enough to show msg
+// to the user since most clients don't display the reason a channel was rejected.
+func rejectChannel(nc ssh.NewChannel, msg string) {
+	if nc.ChannelType() != "session" {
+		_ = nc.Reject(ssh.ConnectionFailed, msg)
+		return
+	}
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	_, _ = fmt.Fprintf(ch.Stderr(), "unweave: %s\r\n", msg)
+	status := struct{ Status uint32 }{Status: 255}
+	_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(&status))
+	_ = ch.Close()
+}
+
+// proxyChannel opens a channel of the same type on upstream and copies data and requests
+// between the two until the upstream channel is closed.
+func proxyChannel(ctx context.Context, upstream *ssh.Client, nc ssh.NewChannel) {
+	uch, ureqs, err := upstream.OpenChannel(nc.ChannelType(), nc.ExtraData())
+	if err != nil {
+		var oce *ssh.OpenChannelError
+		if errors.As(err, &oce) {
+			_ = nc.Reject(oce.Reason, oce.Message)
+			return
+		}
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer uch.Close()
+
+	dch, dreqs, err := nc.Accept()
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to accept gateway channel")
+		return
+	}
+	defer dch.Close()
+
+	go forwardRequests(dreqs, uch)
+	go func() {
+		_, _ = io.Copy(uch, dch)
+		_ = uch.CloseWrite()
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(dch, uch)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(dch.Stderr(), uch.Stderr())
+	}()
+
+	// Requests such as exit-status are sent by the node right before it closes the
+	// channel, so keep forwarding until the upstream closes it.
+	forwardRequests(ureqs, dch)
+	wg.Wait()
+	_ = dch.CloseWrite()
+}
+
+func forwardRequests(in <-chan *ssh.Request, out ssh.Channel) {
+	for req := range in {
+		ok, err := out.SendRequest(req.Type, req.WantReply, req.Payload)
+		if err != nil {
+			ok = false
+		}
+		if req.WantReply {
+			_ = req.Reply(ok, nil)
+		}
+	}
+}
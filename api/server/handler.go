@@ -3,16 +3,336 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/agent/protocol"
 	"github.com/unweave/unweave/api/types"
 	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/events"
 	"github.com/unweave/unweave/runtime"
 )
 
+// Admin
+
+// AdminReconcilerGet returns the report of the last reconciler run.
+func AdminReconcilerGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminReconcilerGet request")
+
+		report := nodeReconciler.LastReport()
+		if report == nil {
+			render.Render(w, r.WithContext(ctx), &types.Error{
+				Code:       http.StatusNotFound,
+				Message:    "The reconciler hasn't run yet",
+				Suggestion: "Trigger a run with POST /admin/reconciler/run",
+			})
+			return
+		}
+		render.JSON(w, r, types.ReconcileReportResponse{Report: *report})
+	}
+}
+
+// AdminReconcilerRun runs the reconciler and returns its report. The query param `dryRun`
+// defaults to true unless the reconciler is configured to enforce.
+func AdminReconcilerRun(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminReconcilerRun request")
+
+		dryRun := nodeReconciler.Mode() != ReconcileEnforce
+		if v := r.URL.Query().Get("dryRun"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				err = &types.Error{
+					Code:    http.StatusBadRequest,
+					Message: "Invalid query parameter 'dryRun'",
+				}
+				render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+				return
+			}
+			dryRun = b
+		}
+
+		report := nodeReconciler.Run(ctx, dryRun)
+		render.JSON(w, r, types.ReconcileReportResponse{Report: report})
+	}
+}
+
+// AdminSupervisorGet returns the session watchers running on the replica that serves the
+// request.
+func AdminSupervisorGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminSupervisorGet request")
+
+		render.JSON(w, r, types.SupervisorStateResponse{State: supervisor.state()})
+	}
+}
+
+// adminAccountID parses the account id in the url of admin requests.
+func adminAccountID(r *http.Request) (uuid.UUID, error) {
+	accountID, err := uuid.Parse(chi.URLParam(r, "accountID"))
+	if err != nil {
+		return uuid.Nil, &types.Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid account id",
+			Err:     err,
+		}
+	}
+	return accountID, nil
+}
+
+// AdminCreditGet returns the credit balance of an account.
+func AdminCreditGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminCreditGet request")
+
+		accountID, err := adminAccountID(r)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		balance, err := creditBalance(ctx, accountID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get credit balance"))
+			return
+		}
+		render.JSON(w, r, types.CreditBalanceResponse{Balance: balance})
+	}
+}
+
+// AdminCreditAdd tops up or adjusts the credit of an account.
+func AdminCreditAdd(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminCreditAdd request")
+
+		accountID, err := adminAccountID(r)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		params := types.CreditTransactionCreateParams{}
+		if err = render.Bind(r, &params); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		srv := NewCtxService(rti, accountID)
+		res, err := srv.Credit.AddTransaction(ctx, accountID, params)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to add credit transaction"))
+			return
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+// adminProjectID returns the project id in the url of admin requests.
+func adminProjectID(r *http.Request) string {
+	return chi.URLParam(r, "projectID")
+}
+
+// AdminAccountQuotaGet returns the quota and usage of an account.
+func AdminAccountQuotaGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminAccountQuotaGet request")
+
+		accountID, err := adminAccountID(r)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		now := time.Now().UTC()
+		srv := NewCtxService(rti, accountID)
+		status, err := srv.Quota.AccountStatus(ctx, accountID, now)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get account quota"))
+			return
+		}
+		render.JSON(w, r, types.QuotaResponse{Month: now.Format("2006-01"), Account: status})
+	}
+}
+
+// AdminAccountQuotaUpdate replaces the quota of an account.
+func AdminAccountQuotaUpdate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminAccountQuotaUpdate request")
+
+		accountID, err := adminAccountID(r)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		quota := types.Quota{}
+		if err = render.Bind(r, &quota); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		srv := NewCtxService(rti, accountID)
+		if err = srv.Quota.SetAccountQuota(ctx, accountID, quota); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to update account quota"))
+			return
+		}
+		now := time.Now().UTC()
+		status, err := srv.Quota.AccountStatus(ctx, accountID, now)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get account quota"))
+			return
+		}
+		render.JSON(w, r, types.QuotaResponse{Month: now.Format("2006-01"), Account: status})
+	}
+}
+
+// AdminProjectQuotaGet returns the quota and usage of a project.
+func AdminProjectQuotaGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminProjectQuotaGet request")
+
+		projectID := adminProjectID(r)
+		now := time.Now().UTC()
+		srv := NewCtxService(rti, uuid.Nil)
+		status, err := srv.Quota.ProjectStatus(ctx, projectID, now)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get project quota"))
+			return
+		}
+		render.JSON(w, r, types.QuotaResponse{Month: now.Format("2006-01"), Project: status})
+	}
+}
+
+// AdminProjectQuotaUpdate replaces the quota of a project.
+func AdminProjectQuotaUpdate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AdminProjectQuotaUpdate request")
+
+		projectID := adminProjectID(r)
+		quota := types.Quota{}
+		if err := render.Bind(r, &quota); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		srv := NewCtxService(rti, uuid.Nil)
+		if err := srv.Quota.SetProjectQuota(ctx, projectID, quota); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to update project quota"))
+			return
+		}
+		now := time.Now().UTC()
+		status, err := srv.Quota.ProjectStatus(ctx, projectID, now)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get project quota"))
+			return
+		}
+		render.JSON(w, r, types.QuotaResponse{Month: now.Format("2006-01"), Project: status})
+	}
+}
+
+// Agent
+
+// AgentHeartbeat records a heartbeat from a node agent.
+func AgentHeartbeat(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Debug().Msgf("Executing AgentHeartbeat request")
+
+		hb := protocol.Heartbeat{}
+		if err := render.DecodeJSON(r.Body, &hb); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		if err := srv.Session.AgentHeartbeat(ctx, sessionID, hb); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to record heartbeat"))
+			return
+		}
+		render.JSON(w, r, protocol.HeartbeatResponse{
+			IntervalSeconds: int(agentHeartbeatInterval / time.Second),
+		})
+	}
+}
+
+// AgentPoll holds the request open until commands for the agent arrive or the wait
+// time given in the `wait` query parameter, in seconds, passes.
+func AgentPoll(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		wait := agentMaxPollWait
+		if v := r.URL.Query().Get("wait"); v != "" {
+			secs, err := strconv.Atoi(v)
+			if err != nil || secs < 0 {
+				err = &types.Error{
+					Code:    http.StatusBadRequest,
+					Message: "Invalid query parameter 'wait'",
+				}
+				render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+				return
+			}
+			wait = time.Duration(secs) * time.Second
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		cmds, err := srv.Session.AgentPoll(ctx, sessionID, wait)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to poll for commands"))
+			return
+		}
+		render.JSON(w, r, protocol.PollResponse{Commands: cmds})
+	}
+}
+
+func AgentCommandResult(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing AgentCommandResult request")
+
+		res := protocol.CommandResult{}
+		if err := render.DecodeJSON(r.Body, &res); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+		res.ID = chi.URLParam(r, "commandID")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		if err := srv.Session.AgentCommandResult(ctx, sessionID, res); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to record command result"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+	}
+}
+
 // Builder
 
 // BuildsCreate expects a request body containing both the build context and the json
@@ -27,10 +347,1136 @@ import (
 func BuildsCreate(rti runtime.Initializer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		log.Ctx(ctx).Info().Msgf("Executing BuildsCreate request")
+		log.Ctx(ctx).Info().Msgf("Executing BuildsCreate request")
+
+		ibp := &types.BuildsCreateParams{}
+		if err := ibp.Bind(r); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+
+		srv := NewCtxService(rti, accountID)
+
+		buildID, err := srv.Builder.Build(ctx, projectID, ibp)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to build image"))
+			return
+		}
+
+		res := &types.BuildsCreateResponse{BuildID: buildID}
+		render.JSON(w, r, res)
+	}
+}
+
+// BuildsGet returns the details of a build. If the query param `logs` is set to
+// true, the logs of the build will be returned as well.
+func BuildsGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing BuildsGet request")
+
+		buildID := chi.URLParam(r, "buildID")
+		getLogs := r.URL.Query().Get("logs") == "true"
+
+		accountID := GetAccountIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		// get build from db
+		build, err := db.Q.BuildGet(ctx, buildID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get build"))
+			return
+		}
+
+		res := &types.BuildsGetResponse{
+			BuildID: buildID,
+			Status:  string(build.Status),
+			Logs:    nil,
+		}
+
+		if getLogs {
+			logs, err := srv.Builder.GetLogs(ctx, buildID)
+			if err != nil {
+				render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get build logs"))
+				return
+			}
+			res.Logs = &logs
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+// Clusters
+
+// ClustersCreate launches a group of sessions on nodes of the same type in the same
+// region and starts monitoring them.
+func ClustersCreate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ClustersCreate request")
+
+		params := types.ClusterCreateParams{}
+		if err := render.Bind(r, &params); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		cluster, err := srv.Cluster.Create(ctx, projectID, params)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create cluster"))
+			return
+		}
+
+		for _, m := range cluster.Members {
+			c := log.With().
+				Stringer(AccountIDCtxKey, accountID).
+				Str(ProjectIDCtxKey, projectID).
+				Str(SessionIDCtxKey, m.SessionID).
+				Logger().WithContext(context.Background())
+
+			if e := srv.Session.Watch(c, m.SessionID); e != nil {
+				log.Ctx(ctx).Error().Err(e).Msgf("Failed to watch session")
+			}
+		}
+
+		c := log.With().
+			Stringer(AccountIDCtxKey, accountID).
+			Str(ProjectIDCtxKey, projectID).
+			Str(ClusterIDCtxKey, cluster.ID).
+			Logger().WithContext(context.Background())
+
+		if err = srv.Cluster.Execute(c, cluster.ID); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to monitor cluster"))
+			return
+		}
+		render.JSON(w, r, types.ClusterGetResponse{Cluster: *cluster})
+	}
+}
+
+func ClustersGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ClustersGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		clusterID := chi.URLParam(r, "clusterID")
+		srv := NewCtxService(rti, accountID)
+
+		cluster, err := srv.Cluster.Get(ctx, projectID, clusterID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get cluster"))
+			return
+		}
+		render.JSON(w, r, types.ClusterGetResponse{Cluster: *cluster})
+	}
+}
+
+// ClustersHostfile returns the hostfile of a running cluster as plain text so that it can
+// be passed straight to tools like mpirun or deepspeed.
+func ClustersHostfile(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ClustersHostfile request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		clusterID := chi.URLParam(r, "clusterID")
+		srv := NewCtxService(rti, accountID)
+
+		cluster, err := srv.Cluster.Get(ctx, projectID, clusterID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get cluster"))
+			return
+		}
+		if cluster.Hostfile == nil {
+			err = &types.Error{
+				Code:       http.StatusConflict,
+				Message:    fmt.Sprintf("Cluster is %s", cluster.Status),
+				Suggestion: "The hostfile is available once all members of the cluster are running",
+			}
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Cluster not running"))
+			return
+		}
+		render.PlainText(w, r, *cluster.Hostfile)
+	}
+}
+
+func ClustersList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ClustersList request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		clusters, err := srv.Cluster.List(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list clusters"))
+			return
+		}
+		render.JSON(w, r, types.ClustersListResponse{Clusters: clusters})
+	}
+}
+
+func ClustersTerminate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ClustersTerminate request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		clusterID := chi.URLParam(r, "clusterID")
+		srv := NewCtxService(rti, accountID)
+
+		if err := srv.Cluster.Terminate(ctx, projectID, clusterID); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to terminate cluster"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+	}
+}
+
+// Credit
+
+// CreditBalanceGet returns the credit balance of the caller's account.
+func CreditBalanceGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing CreditBalanceGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		balance, err := srv.Credit.Balance(ctx)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get credit balance"))
+			return
+		}
+		render.JSON(w, r, types.CreditBalanceResponse{Balance: balance})
+	}
+}
+
+// CreditTransactionsList returns the most recent credit transactions of the caller's
+// account, newest first.
+func CreditTransactionsList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing CreditTransactionsList request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		transactions, err := srv.Credit.Transactions(ctx)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get credit transactions"))
+			return
+		}
+		render.JSON(w, r, types.CreditTransactionsListResponse{Transactions: transactions})
+	}
+}
+
+// Pipelines
+
+// PipelinesCreate accepts a pipeline definition as either YAML or JSON and starts
+// executing it.
+//
+//	eg. curl -X POST \
+//			 -H 'Authorization: Bearer <token>' \
+//			 -H 'Content-Type: application/yaml' \
+//			 --data-binary @pipeline.yaml \
+//			 https://<api-host>/projects/<project-id>/pipelines
+func PipelinesCreate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing PipelinesCreate request")
+
+		params := &types.PipelineCreateParams{}
+		if err := params.Bind(r); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		pipeline, err := srv.Pipeline.Create(ctx, projectID, *params)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create pipeline"))
+			return
+		}
+
+		c := log.With().
+			Stringer(AccountIDCtxKey, accountID).
+			Str(ProjectIDCtxKey, projectID).
+			Str(PipelineIDCtxKey, pipeline.ID).
+			Logger().WithContext(context.Background())
+
+		if err = srv.Pipeline.Execute(c, pipeline.ID); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to start pipeline"))
+			return
+		}
+		render.JSON(w, r, types.PipelineGetResponse{Pipeline: *pipeline})
+	}
+}
+
+// PipelinesGet returns a pipeline along with the status of each of its steps.
+func PipelinesGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing PipelinesGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		pipelineID := chi.URLParam(r, "pipelineID")
+		srv := NewCtxService(rti, accountID)
+
+		pipeline, err := srv.Pipeline.Get(ctx, projectID, pipelineID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get pipeline"))
+			return
+		}
+		render.JSON(w, r, types.PipelineGetResponse{Pipeline: *pipeline})
+	}
+}
+
+func PipelinesList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing PipelinesList request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		pipelines, err := srv.Pipeline.List(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list pipelines"))
+			return
+		}
+		render.JSON(w, r, types.PipelinesListResponse{Pipelines: pipelines})
+	}
+}
+
+func PipelinesCancel(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing PipelinesCancel request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		pipelineID := chi.URLParam(r, "pipelineID")
+		srv := NewCtxService(rti, accountID)
+
+		if err := srv.Pipeline.Cancel(ctx, projectID, pipelineID); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to cancel pipeline"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+	}
+}
+
+// Projects
+
+func ProjectsWatchPolicyGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsWatchPolicyGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		policy, err := srv.Project.WatchPolicy(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get watch policy"))
+			return
+		}
+		render.JSON(w, r, types.WatchPolicyResponse{Policy: policy})
+	}
+}
+
+// ProjectsWatchPolicyUpdate updates the watch policy of the project. Fields missing from
+// the request body keep their current value.
+func ProjectsWatchPolicyUpdate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsWatchPolicyUpdate request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		policy, err := srv.Project.WatchPolicy(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get watch policy"))
+			return
+		}
+		if err = render.Bind(r, &policy); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+		if err = srv.Project.SetWatchPolicy(ctx, projectID, policy); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to update watch policy"))
+			return
+		}
+		render.JSON(w, r, types.WatchPolicyResponse{Policy: policy})
+	}
+}
+
+func ProjectsDefaultsGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsDefaultsGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		defaults, err := srv.Project.Defaults(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get project defaults"))
+			return
+		}
+		render.JSON(w, r, types.ProjectDefaultsResponse{Defaults: defaults})
+	}
+}
+
+// ProjectsBudgetGet returns the budget of the project and what it spent this month.
+func ProjectsBudgetGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsBudgetGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		res, err := srv.Project.Budget(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get project budget"))
+			return
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+// ProjectsBudgetUpdate replaces the budget of the project.
+func ProjectsBudgetUpdate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsBudgetUpdate request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		budget := types.ProjectBudget{}
+		if err := render.Bind(r, &budget); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+		if err := srv.Project.SetBudget(ctx, projectID, budget); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to update project budget"))
+			return
+		}
+		res, err := srv.Project.Budget(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get project budget"))
+			return
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+// ProjectsEventsStream streams the events of the project as server-sent events. The
+// types query parameter takes a comma separated list of event types to stream.
+func ProjectsEventsStream(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsEventsStream request")
+
+		projectID := GetProjectIDFromContext(ctx)
+		eventTypes, err := parseEventTypes(r.URL.Query().Get("types"))
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid event types"))
+			return
+		}
+
+		sub := eventBus.Subscribe(events.Filter{ProjectID: projectID, Types: eventTypes})
+		defer sub.Close()
+
+		if err = streamEvents(ctx, w, sub); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Event stream ended")
+		}
+	}
+}
+
+// ProjectsDefaultsUpdate replaces the defaults applied to sessions created in the project.
+func ProjectsDefaultsUpdate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsDefaultsUpdate request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		defaults := types.ProjectDefaults{}
+		if err := render.Bind(r, &defaults); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+		if err := srv.Project.SetDefaults(ctx, projectID, defaults); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to update project defaults"))
+			return
+		}
+		render.JSON(w, r, types.ProjectDefaultsResponse{Defaults: defaults})
+	}
+}
+
+// Provider
+
+// NodeTypesList returns a list of node types available for the user. If the query param
+// `available` is set to true, only node types that are currently available to be
+// scheduled will be returned.
+func NodeTypesList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		provider := types.RuntimeProvider(chi.URLParam(r, "provider"))
+		log.Ctx(ctx).Info().Msgf("Executing NodeTypesList request for provider %s", provider)
+
+		filterAvailable := r.URL.Query().Get("available") == "true"
+
+		accountID := GetAccountIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		nodeTypes, err := srv.Provider.ListNodeTypes(ctx, provider, filterAvailable)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list node types"))
+			return
+		}
+
+		res := &types.NodeTypesListResponse{NodeTypes: nodeTypes}
+		render.JSON(w, r, res)
+	}
+}
+
+// Quotas
+
+// QuotasGet returns the quota and usage of the caller's account.
+func QuotasGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing QuotasGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		res, err := srv.Quota.Get(ctx, nil)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get quota"))
+			return
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+// ProjectsQuotaGet returns the quota and usage of the project and of the caller's
+// account, both of which sessions in the project are limited by.
+func ProjectsQuotaGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing ProjectsQuotaGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		res, err := srv.Quota.Get(ctx, &projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get quota"))
+			return
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+// Sessions
+
+func SessionsAgentCommand(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsAgentCommand request")
+
+		params := types.SessionAgentCommandParams{}
+		if err := render.Bind(r, &params); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		timeout := agentCommandTimeout
+		if params.Exec != nil && params.Exec.TimeoutSeconds > 0 {
+			timeout = time.Duration(params.Exec.TimeoutSeconds)*time.Second + agentHeartbeatInterval
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		cmd := protocol.Command{Kind: params.Kind, Exec: params.Exec, FilePush: params.FilePush}
+		res, err := srv.Session.AgentCommand(ctx, sessionID, cmd)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to run agent command"))
+			return
+		}
+		render.JSON(w, r, types.SessionAgentCommandResponse{Result: *res})
+	}
+}
+
+func SessionsCreate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsCreate request")
+
+		scr := types.SessionCreateParams{}
+		if err := render.Bind(r, &scr); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		if v := r.URL.Query().Get("dryRun"); v != "" {
+			dryRun, err := strconv.ParseBool(v)
+			if err != nil {
+				err = &types.Error{
+					Code:    http.StatusBadRequest,
+					Message: "Invalid query parameter 'dryRun'",
+				}
+				render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+				return
+			}
+			if dryRun {
+				sessionsCreateDryRun(w, r, srv, projectID, scr)
+				return
+			}
+		}
+
+		session, err := srv.Session.Create(ctx, projectID, scr)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create session"))
+			return
+		}
+
+		go func() {
+			c := context.Background()
+			c = log.With().
+				Stringer(AccountIDCtxKey, accountID).
+				Str(ProjectIDCtxKey, projectID).
+				Str(SessionIDCtxKey, session.ID).
+				Logger().WithContext(c)
+
+			if e := srv.Session.Watch(c, session.ID); e != nil {
+				log.Ctx(ctx).Error().Err(e).Msgf("Failed to watch session")
+			}
+		}()
+
+		render.JSON(w, r, session)
+	}
+}
+
+// sessionsCreateDryRun responds with the session a create request would launch and its
+// cost for the runtime in the 'duration' query parameter.
+func sessionsCreateDryRun(w http.ResponseWriter, r *http.Request, srv *Service, projectID string, scr types.SessionCreateParams) {
+	ctx := r.Context()
+
+	var duration time.Duration
+	if v := r.URL.Query().Get("duration"); v != "" {
+		// Either a duration such as 2h or a number of seconds.
+		if secs, e := strconv.Atoi(v); e == nil {
+			duration = time.Duration(secs) * time.Second
+		} else if d, e := time.ParseDuration(v); e == nil {
+			duration = d
+		}
+		if duration <= 0 {
+			err := &types.Error{
+				Code:       http.StatusBadRequest,
+				Message:    "Invalid query parameter 'duration'",
+				Suggestion: "Use a positive duration, e.g. 3600 or 1h",
+			}
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+	}
+
+	res, err := srv.Session.DryRun(ctx, projectID, scr, duration)
+	if err != nil {
+		render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create session"))
+		return
+	}
+	render.JSON(w, r, res)
+}
+
+func SessionsGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		session, err := srv.Session.Get(ctx, sessionID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get session"))
+			return
+		}
+
+		render.JSON(w, r, types.SessionGetResponse{Session: *session})
+	}
+}
+
+func SessionsKnownHosts(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsKnownHosts request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		line, err := srv.Session.KnownHosts(ctx, sessionID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get known hosts"))
+			return
+		}
+		render.PlainText(w, r, line)
+	}
+}
+
+func SessionsList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		listTerminated := r.URL.Query().Get("terminated") == "true"
+
+		log.Ctx(ctx).Info().Msgf("Executing SessionsList request")
+
+		srv := NewCtxService(rti, accountID)
+		sessions, err := srv.Session.List(ctx, projectID, listTerminated)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list sessions"))
+			return
+		}
+		render.JSON(w, r, types.SessionsListResponse{Sessions: sessions})
+	}
+}
+
+// parseTimeParam parses a query parameter that is either a unix timestamp in seconds or
+// an RFC 3339 time.
+func parseTimeParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, &types.Error{
+			Code:       http.StatusBadRequest,
+			Message:    fmt.Sprintf("Invalid query parameter %q", name),
+			Suggestion: "Use a unix timestamp in seconds or an RFC 3339 time",
+		}
+	}
+	return t, nil
+}
+
+// maxMetricsSamples limits the number of samples a single metrics request can return.
+const maxMetricsSamples = 10000
+
+func SessionsMetrics(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsMetrics request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		now := time.Now().UTC()
+		to, err := parseTimeParam(r, "to", now)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		from, err := parseTimeParam(r, "from", to.Add(-time.Hour))
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		if !from.Before(to) {
+			err = &types.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Query parameter 'from' must be before 'to'",
+			}
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+
+		step := metricsInterval
+		if v := r.URL.Query().Get("step"); v != "" {
+			// Either a duration such as 5m or a number of seconds.
+			if secs, e := strconv.Atoi(v); e == nil {
+				step = time.Duration(secs) * time.Second
+			} else if d, e := time.ParseDuration(v); e == nil {
+				step = d
+			} else {
+				step = 0
+			}
+			if step < time.Second {
+				err = &types.Error{
+					Code:       http.StatusBadRequest,
+					Message:    "Invalid query parameter 'step'",
+					Suggestion: "Use a duration of at least 1s, e.g. 60 or 1m",
+				}
+				render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+				return
+			}
+		}
+		if to.Sub(from)/step > maxMetricsSamples {
+			err = &types.Error{
+				Code:       http.StatusBadRequest,
+				Message:    fmt.Sprintf("Requested more than %d samples", maxMetricsSamples),
+				Suggestion: "Increase the step or narrow the time range",
+			}
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+
+		res, err := srv.Session.Metrics(ctx, sessionID, from, to, step)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get session metrics"))
+			return
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+func SessionsSSHConfig(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsSSHConfig request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		config, err := srv.Session.SSHConfig(ctx, sessionID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get ssh config"))
+			return
+		}
+		render.PlainText(w, r, config)
+	}
+}
+
+// SessionsTerminate terminates the session. Its artifacts are collected first unless the
+// query param `skipArtifacts` is true.
+func SessionsTerminate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		accountID := GetAccountIDFromContext(ctx)
+
+		log.Ctx(ctx).
+			Info().
+			Msgf("Executing SessionsTerminate request")
+
+		opts := TerminateOptions{}
+		if v := r.URL.Query().Get("skipArtifacts"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				err = &types.Error{
+					Code:    http.StatusBadRequest,
+					Message: "Invalid query parameter 'skipArtifacts'",
+				}
+				render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+				return
+			}
+			opts.SkipArtifacts = b
+		}
+
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		if err := srv.Session.Terminate(ctx, sessionID, opts); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to terminate session"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+	}
+}
+
+// SessionsSetupLogs returns the output of the session's setup script.
+func SessionsSetupLogs(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsSetupLogs request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		logs, err := srv.Session.SetupLogs(ctx, sessionID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get setup logs"))
+			return
+		}
+		render.JSON(w, r, types.SessionLogsResponse{Logs: logs})
+	}
+}
+
+// SessionsArtifactsList lists the artifacts collected from the session.
+func SessionsArtifactsList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsArtifactsList request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		list, err := srv.Session.Artifacts(ctx, sessionID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list artifacts"))
+			return
+		}
+		render.JSON(w, r, types.ArtifactsListResponse{Artifacts: list})
+	}
+}
+
+// SessionsArtifactDownload streams the content of an artifact.
+func SessionsArtifactDownload(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsArtifactDownload request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		artifactID := chi.URLParam(r, "artifactID")
+		srv := NewCtxService(rti, accountID)
+
+		artifact, rc, err := srv.Session.OpenArtifact(ctx, sessionID, artifactID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get artifact"))
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(artifact.Size, 10))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, artifactFileName(artifact)))
+		w.Header().Set("X-Checksum-Sha256", artifact.SHA256)
+		if _, err = io.Copy(w, rc); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to stream artifact")
+		}
+	}
+}
+
+// SessionsWatchDecisions lists what was done about failures to watch the session and why.
+func SessionsWatchDecisions(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsWatchDecisions request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		decisions, err := srv.Session.WatchDecisions(ctx, sessionID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list watch decisions"))
+			return
+		}
+		render.JSON(w, r, types.WatchDecisionsListResponse{Decisions: decisions})
+	}
+}
+
+// SessionsFilesManifest returns the sha256 of every file under the directory given by the
+// query param `path` on the session's node.
+func SessionsFilesManifest(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsFilesManifest request")
+
+		dir := r.URL.Query().Get("path")
+		if dir == "" {
+			err := &types.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Query parameter 'path' is required",
+			}
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		manifest, err := srv.Session.FilesManifest(ctx, sessionID, dir)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get files manifest"))
+			return
+		}
+		render.JSON(w, r, types.SessionFilesManifestResponse{Manifest: manifest})
+	}
+}
+
+// SessionsFilesUpload expects a multipart form with the upload params as JSON in a field
+// called `params` and a tar or zip archive in a file called `archive`. The archive is
+// extracted to the session's node over SFTP.
+func SessionsFilesUpload(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsFilesUpload request")
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxFilesArchiveSize)
+		params := types.SessionFilesUploadParams{}
+		if err := render.Bind(r, &params); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		defer params.Archive.Close()
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		res, err := srv.Session.UploadFiles(ctx, sessionID, params)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to upload files"))
+			return
+		}
+		render.JSON(w, r, res)
+	}
+}
+
+// SessionsExposePort serves a port of the session's node through the API.
+func SessionsExposePort(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsExposePort request")
+
+		params := types.SessionExposePortParams{}
+		if err := render.Bind(r, &params); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		port, err := srv.Session.ExposePort(ctx, sessionID, params.Port)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to expose port"))
+			return
+		}
+		render.JSON(w, r, types.SessionExposePortResponse{ExposedPort: *port})
+	}
+}
+
+func SessionsUnexposePort(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionsUnexposePort request")
+
+		port, err := strconv.Atoi(chi.URLParam(r, "port"))
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid port"))
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		if err = srv.Session.UnexposePort(ctx, sessionID, port); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to unexpose port"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+	}
+}
+
+// SessionsProxyPort forwards requests to an exposed port of the session's node. Requests
+// to the port without a trailing slash are redirected so that relative links resolve.
+func SessionsProxyPort(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Debug().Msgf("Executing SessionsProxyPort request")
+
+		port, err := strconv.Atoi(chi.URLParam(r, "port"))
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid port"))
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/") && chi.URLParam(r, "*") == "" {
+			u := *r.URL
+			u.Path += "/"
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+
+		accountID := GetAccountIDFromContext(ctx)
+		sessionID := GetSessionIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		proxy, err := srv.Session.ProxyPort(ctx, sessionID, port)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to proxy port"))
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}
+}
+
+// Session Templates
+
+func SessionTemplatesCreate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionTemplatesCreate request")
 
-		ibp := &types.BuildsCreateParams{}
-		if err := ibp.Bind(r); err != nil {
+		params := types.SessionTemplateCreateParams{}
+		if err := render.Bind(r, &params); err != nil {
 			err = fmt.Errorf("failed to read body: %w", err)
 			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
 			return
@@ -38,94 +1484,123 @@ func BuildsCreate(rti runtime.Initializer) http.HandlerFunc {
 
 		accountID := GetAccountIDFromContext(ctx)
 		projectID := GetProjectIDFromContext(ctx)
-
 		srv := NewCtxService(rti, accountID)
 
-		buildID, err := srv.Builder.Build(ctx, projectID, ibp)
+		tmpl, err := srv.SessionTemplate.Create(ctx, projectID, params)
 		if err != nil {
-			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to build image"))
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create session template"))
 			return
 		}
-
-		res := &types.BuildsCreateResponse{BuildID: buildID}
-		render.JSON(w, r, res)
+		render.JSON(w, r, types.SessionTemplateGetResponse{Template: *tmpl})
 	}
 }
 
-// BuildsGet returns the details of a build. If the query param `logs` is set to
-// true, the logs of the build will be returned as well.
-func BuildsGet(rti runtime.Initializer) http.HandlerFunc {
+// SessionTemplatesGet returns the latest version of a session template. If the query
+// param `version` is set, that version of the template is returned instead.
+func SessionTemplatesGet(rti runtime.Initializer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		log.Ctx(ctx).Info().Msgf("Executing BuildsGet request")
+		log.Ctx(ctx).Info().Msgf("Executing SessionTemplatesGet request")
 
-		buildID := chi.URLParam(r, "buildID")
-		getLogs := r.URL.Query().Get("logs") == "true"
+		var version *int
+		if v := r.URL.Query().Get("version"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				err = fmt.Errorf("failed to parse version: %w", err)
+				render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid query param 'version'"))
+				return
+			}
+			version = &n
+		}
 
 		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		templateID := chi.URLParam(r, "templateID")
 		srv := NewCtxService(rti, accountID)
 
-		// get build from db
-		build, err := db.Q.BuildGet(ctx, buildID)
+		tmpl, err := srv.SessionTemplate.Get(ctx, projectID, templateID, version)
 		if err != nil {
-			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get build"))
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get session template"))
 			return
 		}
+		render.JSON(w, r, types.SessionTemplateGetResponse{Template: *tmpl})
+	}
+}
 
-		res := &types.BuildsGetResponse{
-			BuildID: buildID,
-			Status:  string(build.Status),
-			Logs:    nil,
-		}
+func SessionTemplatesList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionTemplatesList request")
 
-		if getLogs {
-			logs, err := srv.Builder.GetLogs(ctx, buildID)
-			if err != nil {
-				render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get build logs"))
-				return
-			}
-			res.Logs = &logs
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		tmpls, err := srv.SessionTemplate.List(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list session templates"))
+			return
 		}
-		render.JSON(w, r, res)
+		render.JSON(w, r, types.SessionTemplatesListResponse{Templates: tmpls})
 	}
 }
 
-// Provider
-
-// NodeTypesList returns a list of node types available for the user. If the query param
-// `available` is set to true, only node types that are currently available to be
-// scheduled will be returned.
-func NodeTypesList(rti runtime.Initializer) http.HandlerFunc {
+// SessionTemplatesUpdate creates a new version of a session template.
+func SessionTemplatesUpdate(rti runtime.Initializer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		provider := types.RuntimeProvider(chi.URLParam(r, "provider"))
-		log.Ctx(ctx).Info().Msgf("Executing NodeTypesList request for provider %s", provider)
+		log.Ctx(ctx).Info().Msgf("Executing SessionTemplatesUpdate request")
 
-		filterAvailable := r.URL.Query().Get("available") == "true"
+		params := types.SessionTemplateUpdateParams{}
+		if err := render.Bind(r, &params); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
 
 		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		templateID := chi.URLParam(r, "templateID")
 		srv := NewCtxService(rti, accountID)
 
-		nodeTypes, err := srv.Provider.ListNodeTypes(ctx, provider, filterAvailable)
+		tmpl, err := srv.SessionTemplate.Update(ctx, projectID, templateID, params)
 		if err != nil {
-			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list node types"))
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to update session template"))
 			return
 		}
+		render.JSON(w, r, types.SessionTemplateGetResponse{Template: *tmpl})
+	}
+}
 
-		res := &types.NodeTypesListResponse{NodeTypes: nodeTypes}
-		render.JSON(w, r, res)
+func SessionTemplateVersionsList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SessionTemplateVersionsList request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		templateID := chi.URLParam(r, "templateID")
+		srv := NewCtxService(rti, accountID)
+
+		versions, err := srv.SessionTemplate.ListVersions(ctx, projectID, templateID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list session template versions"))
+			return
+		}
+		render.JSON(w, r, types.SessionTemplateVersionsListResponse{Versions: versions})
 	}
 }
 
-// Sessions
+// Sweeps
 
-func SessionsCreate(rti runtime.Initializer) http.HandlerFunc {
+// SweepsCreate expands a parameter space into trials and starts running them as jobs.
+func SweepsCreate(rti runtime.Initializer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		log.Ctx(ctx).Info().Msgf("Executing SessionsCreate request")
+		log.Ctx(ctx).Info().Msgf("Executing SweepsCreate request")
 
-		scr := types.SessionCreateParams{}
-		if err := render.Bind(r, &scr); err != nil {
+		params := types.SweepCreateParams{}
+		if err := render.Bind(r, &params); err != nil {
 			err = fmt.Errorf("failed to read body: %w", err)
 			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
 			return
@@ -135,81 +1610,76 @@ func SessionsCreate(rti runtime.Initializer) http.HandlerFunc {
 		projectID := GetProjectIDFromContext(ctx)
 		srv := NewCtxService(rti, accountID)
 
-		session, err := srv.Session.Create(ctx, projectID, scr)
+		sweep, err := srv.Sweep.Create(ctx, projectID, params)
 		if err != nil {
-			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create session"))
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create sweep"))
 			return
 		}
 
-		go func() {
-			c := context.Background()
-			c = log.With().
-				Stringer(AccountIDCtxKey, accountID).
-				Str(ProjectIDCtxKey, projectID).
-				Str(SessionIDCtxKey, session.ID).
-				Logger().WithContext(c)
-
-			if e := srv.Session.Watch(c, session.ID); e != nil {
-				log.Ctx(ctx).Error().Err(e).Msgf("Failed to watch session")
-			}
-		}()
+		c := log.With().
+			Stringer(AccountIDCtxKey, accountID).
+			Str(ProjectIDCtxKey, projectID).
+			Str(SweepIDCtxKey, sweep.ID).
+			Logger().WithContext(context.Background())
 
-		render.JSON(w, r, session)
+		if err = srv.Sweep.Execute(c, sweep.ID); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to start sweep"))
+			return
+		}
+		render.JSON(w, r, types.SweepGetResponse{Sweep: *sweep})
 	}
 }
 
-func SessionsGet(rti runtime.Initializer) http.HandlerFunc {
+// SweepsGet returns a sweep along with the parameters and status of each trial.
+func SweepsGet(rti runtime.Initializer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		log.Ctx(ctx).Info().Msgf("Executing SessionsGet request")
+		log.Ctx(ctx).Info().Msgf("Executing SweepsGet request")
 
 		accountID := GetAccountIDFromContext(ctx)
-		sessionID := GetSessionIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		sweepID := chi.URLParam(r, "sweepID")
 		srv := NewCtxService(rti, accountID)
 
-		session, err := srv.Session.Get(ctx, sessionID)
+		sweep, err := srv.Sweep.Get(ctx, projectID, sweepID)
 		if err != nil {
-			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get session"))
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get sweep"))
 			return
 		}
-
-		render.JSON(w, r, types.SessionGetResponse{Session: *session})
+		render.JSON(w, r, types.SweepGetResponse{Sweep: *sweep})
 	}
 }
 
-func SessionsList(rti runtime.Initializer) http.HandlerFunc {
+func SweepsList(rti runtime.Initializer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing SweepsList request")
+
 		accountID := GetAccountIDFromContext(ctx)
 		projectID := GetProjectIDFromContext(ctx)
-		listTerminated := r.URL.Query().Get("terminated") == "true"
-
-		log.Ctx(ctx).Info().Msgf("Executing SessionsList request")
-
 		srv := NewCtxService(rti, accountID)
-		sessions, err := srv.Session.List(ctx, projectID, listTerminated)
+
+		sweeps, err := srv.Sweep.List(ctx, projectID)
 		if err != nil {
-			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list sessions"))
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list sweeps"))
 			return
 		}
-		render.JSON(w, r, types.SessionsListResponse{Sessions: sessions})
+		render.JSON(w, r, types.SweepsListResponse{Sweeps: sweeps})
 	}
 }
 
-func SessionsTerminate(rti runtime.Initializer) http.HandlerFunc {
+func SweepsCancel(rti runtime.Initializer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		accountID := GetAccountIDFromContext(ctx)
-
-		log.Ctx(ctx).
-			Info().
-			Msgf("Executing SessionsTerminate request")
+		log.Ctx(ctx).Info().Msgf("Executing SweepsCancel request")
 
-		sessionID := GetSessionIDFromContext(ctx)
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		sweepID := chi.URLParam(r, "sweepID")
 		srv := NewCtxService(rti, accountID)
 
-		if err := srv.Session.Terminate(ctx, sessionID); err != nil {
-			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to terminate session"))
+		if err := srv.Sweep.Cancel(ctx, projectID, sweepID); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to cancel sweep"))
 			return
 		}
 		render.Status(r, http.StatusOK)
@@ -293,3 +1763,200 @@ func SSHKeyList(rti runtime.Initializer) http.HandlerFunc {
 		render.JSON(w, r, res)
 	}
 }
+
+// Usage
+
+// UsageGet aggregates the usage of sessions between the `from` and `to` query params,
+// which default to the start of the current month and now. Usage is grouped by the
+// comma separated dimensions in `groupBy` and can be limited to a project with
+// `projectID`. The report is returned as CSV if `format` is csv.
+func UsageGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing UsageGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		now := time.Now().UTC()
+		to, err := parseTimeParam(r, "to", now)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		from, err := parseTimeParam(r, "from", monthStart(now))
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		if !from.Before(to) {
+			err = &types.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Query parameter 'from' must be before 'to'",
+			}
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		groupBy, err := types.ParseUsageGroupBy(r.URL.Query().Get("groupBy"))
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			err = &types.Error{
+				Code:       http.StatusBadRequest,
+				Message:    fmt.Sprintf("Invalid format %q", format),
+				Suggestion: "Use json or csv",
+			}
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request"))
+			return
+		}
+
+		params := types.UsageParams{From: from, To: to, GroupBy: groupBy}
+		if v := r.URL.Query().Get("projectID"); v != "" {
+			params.ProjectID = &v
+		}
+		report, err := srv.Usage.Report(ctx, params)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get usage"))
+			return
+		}
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="usage-%s-%s.csv"`,
+				from.Format("20060102"), to.Format("20060102")))
+			if err = writeUsageCSV(w, report); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("Failed to write usage CSV")
+			}
+			return
+		}
+		render.JSON(w, r, types.UsageResponse{Usage: *report})
+	}
+}
+
+// Webhooks
+
+// WebhooksCreate registers a webhook. The response is the only time the secret the
+// webhook's deliveries are signed with is returned.
+func WebhooksCreate(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing WebhooksCreate request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		params := types.WebhookCreateParams{}
+		if err := render.Bind(r, &params); err != nil {
+			err = fmt.Errorf("failed to read body: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrHTTPBadRequest(err, "Invalid request body"))
+			return
+		}
+		hook, err := srv.Webhook.Create(ctx, projectID, params)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to create webhook"))
+			return
+		}
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, types.WebhookResponse{Webhook: *hook})
+	}
+}
+
+func WebhooksList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing WebhooksList request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		srv := NewCtxService(rti, accountID)
+
+		hooks, err := srv.Webhook.List(ctx, projectID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list webhooks"))
+			return
+		}
+		render.JSON(w, r, types.WebhooksListResponse{Webhooks: hooks})
+	}
+}
+
+func WebhooksGet(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing WebhooksGet request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		webhookID := chi.URLParam(r, "webhookID")
+		srv := NewCtxService(rti, accountID)
+
+		hook, err := srv.Webhook.Get(ctx, projectID, webhookID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to get webhook"))
+			return
+		}
+		render.JSON(w, r, types.WebhookResponse{Webhook: *hook})
+	}
+}
+
+func WebhooksDelete(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing WebhooksDelete request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		webhookID := chi.URLParam(r, "webhookID")
+		srv := NewCtxService(rti, accountID)
+
+		if err := srv.Webhook.Delete(ctx, projectID, webhookID); err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to delete webhook"))
+			return
+		}
+		render.Status(r, http.StatusOK)
+	}
+}
+
+// WebhookDeliveriesList returns the latest deliveries of a webhook and their outcome.
+func WebhookDeliveriesList(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing WebhookDeliveriesList request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		webhookID := chi.URLParam(r, "webhookID")
+		srv := NewCtxService(rti, accountID)
+
+		deliveries, err := srv.Webhook.Deliveries(ctx, projectID, webhookID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to list webhook deliveries"))
+			return
+		}
+		render.JSON(w, r, types.WebhookDeliveriesListResponse{Deliveries: deliveries})
+	}
+}
+
+// WebhookDeliveryRedeliver queues a delivery to be sent again.
+func WebhookDeliveryRedeliver(rti runtime.Initializer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.Ctx(ctx).Info().Msgf("Executing WebhookDeliveryRedeliver request")
+
+		accountID := GetAccountIDFromContext(ctx)
+		projectID := GetProjectIDFromContext(ctx)
+		webhookID := chi.URLParam(r, "webhookID")
+		deliveryID := chi.URLParam(r, "deliveryID")
+		srv := NewCtxService(rti, accountID)
+
+		delivery, err := srv.Webhook.Redeliver(ctx, projectID, webhookID, deliveryID)
+		if err != nil {
+			render.Render(w, r.WithContext(ctx), ErrHTTPError(err, "Failed to redeliver webhook delivery"))
+			return
+		}
+		render.JSON(w, r, types.WebhookDeliveryResponse{Delivery: *delivery})
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// jobPollInterval is how often a job checks on the session it runs on.
+var jobPollInterval = 5 * time.Second
+
+// jobSpec describes a command to run to completion on a dedicated node.
+type jobSpec struct {
+	Provider   types.RuntimeProvider
+	NodeTypeID string
+	Region     *string
+	// BuildID is the build whose image the command runs in. If nil, the command runs
+	// directly on the node.
+	BuildID *string
+	Command []string
+	// SSHKeyName is the key in the caller's account that the node is launched with.
+	SSHKeyName string
+	// Artifacts are collected from the node once the command exits.
+	Artifacts *types.ArtifactSpec
+}
+
+type jobResult struct {
+	SessionID string
+	ExitCode  int
+	Output    string
+}
+
+// waitForRunning blocks until the session is running. The session must already be
+// watched for its status to change.
+func waitForRunning(ctx context.Context, sessionID string) (types.ConnectionInfo, error) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return types.ConnectionInfo{}, ctx.Err()
+		case <-ticker.C:
+			sess, err := db.Q.SessionGet(ctx, sessionID)
+			if err != nil {
+				return types.ConnectionInfo{}, fmt.Errorf("failed to get session from db: %w", err)
+			}
+			switch sess.Status {
+			case db.UnweaveSessionStatusInitializing, db.UnweaveSessionStatusProvisioning:
+				continue
+			case db.UnweaveSessionStatusRunning, db.UnweaveSessionStatusDegraded:
+				connInfo, err := parseConnectionInfo(sess.ConnectionInfo)
+				if err != nil {
+					return types.ConnectionInfo{}, err
+				}
+				if connInfo.Host == "" {
+					continue
+				}
+				return connInfo.toAPI(), nil
+			default:
+				return types.ConnectionInfo{}, fmt.Errorf("session %s exited with status %q: %s",
+					sessionID, sess.Status, sess.Error.String)
+			}
+		}
+	}
+}
+
+// runJob launches a session with the job's key, runs the job's command on it over the
+// platform key and terminates the session once the command exits. onSession is called as
+// soon as the session is created.
+func (s *SessionService) runJob(ctx context.Context, projectID string, spec jobSpec, onSession func(sessionID string)) (jobResult, error) {
+	var image string
+	if spec.BuildID != nil {
+		uri, err := s.srv.Builder.GetImageURI(ctx, projectID, *spec.BuildID)
+		if err != nil {
+			return jobResult{}, err
+		}
+		image = uri
+	}
+
+	rt, err := s.srv.InitializeRuntime(ctx, spec.Provider)
+	if err != nil {
+		return jobResult{}, fmt.Errorf("failed to create runtime: %w", err)
+	}
+	sshKey, err := fetchCredentials(ctx, s.srv.cid, &spec.SSHKeyName, nil, false)
+	if err != nil {
+		return jobResult{}, err
+	}
+
+	params := types.SessionCreateParams{
+		Provider:   spec.Provider,
+		NodeTypeID: spec.NodeTypeID,
+		Region:     spec.Region,
+		Artifacts:  spec.Artifacts,
+	}
+	session, err := s.launch(ctx, rt, projectID, params, sshKey)
+	if err != nil {
+		return jobResult{}, err
+	}
+	res := jobResult{SessionID: session.ID, ExitCode: -1}
+	if onSession != nil {
+		onSession(session.ID)
+	}
+
+	ctx = log.With().Str(SessionIDCtxKey, session.ID).Logger().WithContext(ctx)
+	defer func() {
+		// Always clean up the node, even if the job was canceled.
+		c := log.Ctx(ctx).WithContext(context.Background())
+		if e := s.Terminate(c, session.ID, TerminateOptions{}); e != nil {
+			log.Ctx(ctx).Error().Err(e).Msg("Failed to terminate job session")
+		}
+	}()
+
+	if err = s.Watch(ctx, session.ID); err != nil {
+		return res, fmt.Errorf("failed to watch session: %w", err)
+	}
+	conn, err := waitForRunning(ctx, session.ID)
+	if err != nil {
+		return res, err
+	}
+
+	cmd := shellQuote(spec.Command)
+	if image != "" {
+		cmd = "docker run --rm --gpus all " + shellQuote([]string{image}) + " " + cmd
+	}
+	log.Ctx(ctx).Info().Msgf("Running job command: %s", cmd)
+
+	res.ExitCode, res.Output, err = runNodeCommand(ctx, conn, cmd)
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// terminateInterruptedJob terminates the session of a job whose executor stopped while
+// the job was running, e.g. because the API restarted. Nothing waits for the job's result
+// anymore so its node would otherwise keep running.
+func (s *SessionService) terminateInterruptedJob(ctx context.Context, sessionID string) {
+	sess, err := db.Q.SessionGet(ctx, sessionID)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to get session %q of interrupted job", sessionID)
+		return
+	}
+	if sess.Status == db.UnweaveSessionStatusTerminated {
+		return
+	}
+	if err = s.Terminate(ctx, sessionID, TerminateOptions{}); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to terminate session %q of interrupted job", sessionID)
+	}
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/runtime"
+)
+
+var (
+	// leaseTTL is how long a lease is valid without being renewed. A replica that dies
+	// loses its leases after this long.
+	leaseTTL = 30 * time.Second
+	// leaseRenewInterval is how often held leases are renewed and unowned sessions are
+	// adopted.
+	leaseRenewInterval = 10 * time.Second
+)
+
+const reconcilerLeaseKey = "reconciler"
+
+func sessionLeaseKey(sessionID string) string {
+	return "session/" + sessionID
+}
+
+func clusterLeaseKey(clusterID string) string {
+	return "cluster/" + clusterID
+}
+
+func pipelineLeaseKey(pipelineID string) string {
+	return "pipeline/" + pipelineID
+}
+
+func sweepLeaseKey(sweepID string) string {
+	return "sweep/" + sweepID
+}
+
+// errLeaseLost is the cause of work under a lease being stopped because the lease was
+// taken over by another replica or couldn't be renewed.
+var errLeaseLost = errors.New("lease lost")
+
+type leaseLostCtxKey struct{}
+
+// leaseCause returns errLeaseLost if ctx was canceled because the lease it runs under was
+// lost and ctx.Err() otherwise. Work that loses its lease must not record an outcome
+// since the replica that adopts it picks up from what is in the DB.
+func leaseCause(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if lost, ok := ctx.Value(leaseLostCtxKey{}).(*atomic.Bool); ok && lost.Load() {
+		return errLeaseLost
+	}
+	return ctx.Err()
+}
+
+// heldLease is a lease held by this replica.
+type heldLease struct {
+	cancel context.CancelFunc
+	lost   *atomic.Bool
+}
+
+// lose cancels the work under the lease, marking errLeaseLost as the cause.
+func (h heldLease) lose() {
+	h.lost.Store(true)
+	h.cancel()
+}
+
+// leases is initialized when the API starts.
+var leases = newLeaseManager("")
+
+// leaseManager holds the leases of this replica. Work done under a lease runs with the
+// context returned by acquire, which is cancelled as soon as the lease can't be renewed.
+type leaseManager struct {
+	owner string
+
+	mu          sync.Mutex
+	held        map[string]heldLease
+	lastRenewed time.Time
+}
+
+func newLeaseManager(owner string) *leaseManager {
+	return &leaseManager{
+		owner:       owner,
+		held:        map[string]heldLease{},
+		lastRenewed: time.Now(),
+	}
+}
+
+// acquire takes the lease with the given key. ok is false if the lease is held by another
+// replica or is already held by this one.
+func (l *leaseManager) acquire(ctx context.Context, key string) (leaseCtx context.Context, ok bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, held := l.held[key]; held {
+		return nil, false, nil
+	}
+
+	params := db.LeaseAcquireParams{
+		Key:        key,
+		Owner:      l.owner,
+		TtlSeconds: int32(leaseTTL / time.Second),
+	}
+	if _, err = db.Q.LeaseAcquire(ctx, params); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to acquire lease %q: %w", key, err)
+	}
+
+	lost := &atomic.Bool{}
+	leaseCtx, cancel := context.WithCancel(context.WithValue(ctx, leaseLostCtxKey{}, lost))
+	l.held[key] = heldLease{cancel: cancel, lost: lost}
+	return leaseCtx, true, nil
+}
+
+// every calls fn at each tick of interval while this replica holds the lease with the
+// given key. The lease is taken on the first tick it is free and again whenever it is
+// lost, so only one replica runs fn at a time. fn runs with the context of the lease.
+func (l *leaseManager) every(ctx context.Context, key string, interval time.Duration, fn func(leaseCtx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var leaseCtx context.Context
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if leaseCtx == nil || leaseCtx.Err() != nil {
+				c, ok, err := l.acquire(ctx, key)
+				if err != nil {
+					log.Ctx(ctx).Error().Err(err).Msg("Failed to acquire lease")
+					continue
+				}
+				if !ok {
+					continue
+				}
+				leaseCtx = c
+			}
+			fn(leaseCtx)
+		}
+	}
+}
+
+// release gives up the lease so that another replica can take it over straight away.
+func (l *leaseManager) release(ctx context.Context, key string) {
+	l.mu.Lock()
+	h, ok := l.held[key]
+	delete(l.held, key)
+	l.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	h.cancel()
+
+	params := db.LeaseReleaseParams{Key: key, Owner: l.owner}
+	if err := db.Q.LeaseRelease(ctx, params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to release lease %q", key)
+	}
+}
+
+// renew extends all leases held by this replica. The work under leases that have been
+// taken over in the meantime is cancelled. If leases can't be renewed for longer than
+// their TTL all of them are given up since other replicas are free to take them over.
+func (l *leaseManager) renew(ctx context.Context) error {
+	params := db.LeaseRenewParams{
+		Owner:      l.owner,
+		TtlSeconds: int32(leaseTTL / time.Second),
+	}
+	keys, err := db.Q.LeaseRenew(ctx, params)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err != nil {
+		if time.Since(l.lastRenewed) > leaseTTL {
+			for key, h := range l.held {
+				h.lose()
+				delete(l.held, key)
+			}
+		}
+		return fmt.Errorf("failed to renew leases: %w", err)
+	}
+	l.lastRenewed = time.Now()
+
+	renewed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		renewed[key] = true
+	}
+	for key, h := range l.held {
+		if !renewed[key] {
+			log.Ctx(ctx).Warn().Msgf("Lost lease %q", key)
+			h.lose()
+			delete(l.held, key)
+		}
+	}
+	return nil
+}
+
+// run renews the leases of this replica and adopts the sessions, clusters, pipelines and
+// sweeps no replica is working on, e.g. because the replica that did died.
+func (l *leaseManager) run(ctx context.Context, rti runtime.Initializer) {
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.renew(ctx); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("Failed to renew leases")
+				continue
+			}
+
+			l.adopt(ctx, rti)
+		}
+	}
+}
+
+func (l *leaseManager) adopt(ctx context.Context, rti runtime.Initializer) {
+	ids, err := db.Q.SessionGetAllActiveUnleased(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get unwatched sessions")
+	}
+	for _, id := range ids {
+		sess, err := db.Q.SessionGet(ctx, id)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Failed to get session %q", id)
+			continue
+		}
+		log.Ctx(ctx).Info().Msgf("Adopting session %s", sess.ID)
+		watchSession(rti, sess)
+	}
+
+	ids, err = db.Q.ClusterGetAllActiveUnleased(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get unmonitored clusters")
+	}
+	for _, id := range ids {
+		cl, err := db.Q.ClusterGet(ctx, id)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Failed to get cluster %q", id)
+			continue
+		}
+		log.Ctx(ctx).Info().Msgf("Adopting cluster %s", cl.ID)
+		resumeCluster(ctx, rti, cl)
+	}
+
+	ids, err = db.Q.PipelineGetAllActiveUnleased(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get unexecuted pipelines")
+	}
+	for _, id := range ids {
+		pl, err := db.Q.PipelineGet(ctx, id)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Failed to get pipeline %q", id)
+			continue
+		}
+		log.Ctx(ctx).Info().Msgf("Adopting pipeline %s", pl.ID)
+		resumePipeline(ctx, rti, pl)
+	}
+
+	ids, err = db.Q.SweepGetAllActiveUnleased(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get unexecuted sweeps")
+	}
+	for _, id := range ids {
+		sw, err := db.Q.SweepGet(ctx, id)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Failed to get sweep %q", id)
+			continue
+		}
+		log.Ctx(ctx).Info().Msgf("Adopting sweep %s", sw.ID)
+		resumeSweep(ctx, rti, sw)
+	}
+}
@@ -0,0 +1,421 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// metricsInterval is how often resource utilization samples are collected from the nodes
+// of running sessions.
+var metricsInterval = 30 * time.Second
+
+// metricsCommand prints everything a sample is made of in one SSH round trip. Each
+// section starts with a `--- <name>` marker. /proc/stat is read twice since CPU
+// utilization is the difference between two readings.
+const metricsCommand = `echo '--- stat'; head -n1 /proc/stat; sleep 1; ` +
+	`echo '--- stat'; head -n1 /proc/stat; ` +
+	`echo '--- meminfo'; cat /proc/meminfo; ` +
+	`echo '--- df'; df -P -B1 "$HOME"; ` +
+	`echo '--- nvidia-smi'; nvidia-smi --query-gpu=index,utilization.gpu,memory.used,memory.total ` +
+	`--format=csv,noheader,nounits 2>/dev/null || true`
+
+const bytesPerMiB = 1024 * 1024
+
+type metricsSection struct {
+	name  string
+	lines []string
+}
+
+// splitMetricsSections splits the output of metricsCommand into its sections.
+func splitMetricsSections(out string) []metricsSection {
+	var sections []metricsSection
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.HasPrefix(line, "--- ") {
+			sections = append(sections, metricsSection{name: strings.TrimPrefix(line, "--- ")})
+			continue
+		}
+		if len(sections) == 0 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		last := &sections[len(sections)-1]
+		last.lines = append(last.lines, line)
+	}
+	return sections
+}
+
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+// parseCPUStat parses the aggregate cpu line of /proc/stat.
+func parseCPUStat(line string) (cpuTimes, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuTimes{}, fmt.Errorf("invalid /proc/stat cpu line %q", line)
+	}
+	// user nice system idle iowait irq softirq steal. guest and guest_nice are already
+	// accounted for in user and nice.
+	var t cpuTimes
+	for i, f := range fields[1:] {
+		if i >= 8 {
+			break
+		}
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("invalid /proc/stat cpu line %q: %w", line, err)
+		}
+		t.total += v
+		if i == 3 || i == 4 {
+			t.idle += v
+		}
+	}
+	return t, nil
+}
+
+// cpuPercent returns the CPU utilization between two readings of /proc/stat.
+func cpuPercent(prev, cur cpuTimes) float64 {
+	total := float64(cur.total) - float64(prev.total)
+	if total <= 0 {
+		return 0
+	}
+	idle := float64(cur.idle) - float64(prev.idle)
+	return (total - idle) / total * 100
+}
+
+// parseMeminfo returns the used and total memory in bytes from /proc/meminfo. Memory
+// that is available for reclaiming, such as the page cache, isn't counted as used.
+func parseMeminfo(lines []string) (used, total int64, err error) {
+	var available int64 = -1
+	total = -1
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		var dst *int64
+		switch fields[0] {
+		case "MemTotal:":
+			dst = &total
+		case "MemAvailable:":
+			dst = &available
+		default:
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid /proc/meminfo line %q: %w", line, err)
+		}
+		*dst = kb * 1024
+	}
+	if total < 0 || available < 0 {
+		return 0, 0, fmt.Errorf("MemTotal or MemAvailable missing from /proc/meminfo")
+	}
+	return total - available, total, nil
+}
+
+// parseDF returns the used and total bytes of the filesystem reported by `df -P -B1`.
+func parseDF(lines []string) (used, total int64, err error) {
+	if len(lines) < 2 {
+		return 0, 0, fmt.Errorf("unexpected df output: %q", strings.Join(lines, "\n"))
+	}
+	fields := strings.Fields(lines[1])
+	if len(fields) < 6 {
+		return 0, 0, fmt.Errorf("invalid df line %q", lines[1])
+	}
+	if total, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid df line %q: %w", lines[1], err)
+	}
+	if used, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid df line %q: %w", lines[1], err)
+	}
+	return used, total, nil
+}
+
+// parseNvidiaSMI parses the csv output of `nvidia-smi --query-gpu=index,utilization.gpu,
+// memory.used,memory.total --format=csv,noheader,nounits`. Values nvidia-smi can't read
+// are reported as zero.
+func parseNvidiaSMI(lines []string) ([]types.GPUMetrics, error) {
+	gpus := make([]types.GPUMetrics, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid nvidia-smi line %q", line)
+		}
+		vals := make([]float64, 4)
+		for i, f := range fields {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "[") {
+				// [N/A] or [Not Supported]
+				continue
+			}
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nvidia-smi line %q: %w", line, err)
+			}
+			vals[i] = v
+		}
+		gpus = append(gpus, types.GPUMetrics{
+			Index:              int(vals[0]),
+			UtilizationPercent: vals[1],
+			MemoryUsedBytes:    int64(vals[2] * bytesPerMiB),
+			MemoryTotalBytes:   int64(vals[3] * bytesPerMiB),
+		})
+	}
+	return gpus, nil
+}
+
+// parseMetricsOutput builds a sample from the output of metricsCommand.
+func parseMetricsOutput(out string, at time.Time) (types.MetricsSample, error) {
+	sample := types.MetricsSample{Time: at, GPUs: []types.GPUMetrics{}}
+
+	var stats []cpuTimes
+	for _, s := range splitMetricsSections(out) {
+		var err error
+		switch s.name {
+		case "stat":
+			if len(s.lines) == 0 {
+				return types.MetricsSample{}, fmt.Errorf("empty /proc/stat output")
+			}
+			var t cpuTimes
+			if t, err = parseCPUStat(s.lines[0]); err == nil {
+				stats = append(stats, t)
+			}
+		case "meminfo":
+			sample.MemoryUsedBytes, sample.MemoryTotalBytes, err = parseMeminfo(s.lines)
+		case "df":
+			sample.DiskUsedBytes, sample.DiskTotalBytes, err = parseDF(s.lines)
+		case "nvidia-smi":
+			sample.GPUs, err = parseNvidiaSMI(s.lines)
+		}
+		if err != nil {
+			return types.MetricsSample{}, err
+		}
+	}
+	if len(stats) != 2 {
+		return types.MetricsSample{}, fmt.Errorf("expected 2 /proc/stat readings, got %d", len(stats))
+	}
+	sample.CPUPercent = cpuPercent(stats[0], stats[1])
+	return sample, nil
+}
+
+// downsampleMetrics averages samples over buckets of step starting at from. Buckets
+// without samples are left out.
+func downsampleMetrics(samples []types.MetricsSample, from time.Time, step time.Duration) []types.MetricsSample {
+	type bucket struct {
+		sum   types.MetricsSample
+		n     int
+		gpus  map[int]*types.GPUMetrics
+		gpuN  map[int]int
+		order []int
+	}
+
+	var res []types.MetricsSample
+	var cur *bucket
+	var curStart time.Time
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		n := float64(cur.n)
+		s := types.MetricsSample{
+			Time:             curStart,
+			CPUPercent:       cur.sum.CPUPercent / n,
+			MemoryUsedBytes:  int64(float64(cur.sum.MemoryUsedBytes) / n),
+			MemoryTotalBytes: int64(float64(cur.sum.MemoryTotalBytes) / n),
+			DiskUsedBytes:    int64(float64(cur.sum.DiskUsedBytes) / n),
+			DiskTotalBytes:   int64(float64(cur.sum.DiskTotalBytes) / n),
+			GPUs:             make([]types.GPUMetrics, 0, len(cur.order)),
+		}
+		for _, idx := range cur.order {
+			g, gn := cur.gpus[idx], float64(cur.gpuN[idx])
+			s.GPUs = append(s.GPUs, types.GPUMetrics{
+				Index:              idx,
+				UtilizationPercent: g.UtilizationPercent / gn,
+				MemoryUsedBytes:    int64(float64(g.MemoryUsedBytes) / gn),
+				MemoryTotalBytes:   int64(float64(g.MemoryTotalBytes) / gn),
+			})
+		}
+		res = append(res, s)
+	}
+
+	for _, s := range samples {
+		start := from.Add(s.Time.Sub(from) / step * step)
+		if cur == nil || !start.Equal(curStart) {
+			flush()
+			cur = &bucket{gpus: map[int]*types.GPUMetrics{}, gpuN: map[int]int{}}
+			curStart = start
+		}
+		cur.n++
+		cur.sum.CPUPercent += s.CPUPercent
+		cur.sum.MemoryUsedBytes += s.MemoryUsedBytes
+		cur.sum.MemoryTotalBytes += s.MemoryTotalBytes
+		cur.sum.DiskUsedBytes += s.DiskUsedBytes
+		cur.sum.DiskTotalBytes += s.DiskTotalBytes
+		for _, g := range s.GPUs {
+			sum, ok := cur.gpus[g.Index]
+			if !ok {
+				sum = &types.GPUMetrics{Index: g.Index}
+				cur.gpus[g.Index] = sum
+				cur.order = append(cur.order, g.Index)
+			}
+			cur.gpuN[g.Index]++
+			sum.UtilizationPercent += g.UtilizationPercent
+			sum.MemoryUsedBytes += g.MemoryUsedBytes
+			sum.MemoryTotalBytes += g.MemoryTotalBytes
+		}
+	}
+	flush()
+	return res
+}
+
+// GPUMetricsV1 is the compact form GPU samples are stored in.
+type GPUMetricsV1 struct {
+	Index       int     `json:"i"`
+	Utilization float64 `json:"u"`
+	MemoryUsed  int64   `json:"mu"`
+	MemoryTotal int64   `json:"mt"`
+}
+
+func dbSessionMetricToAPI(m db.UnweaveSessionMetric) (types.MetricsSample, error) {
+	var gpus []GPUMetricsV1
+	if err := json.Unmarshal(m.Gpus, &gpus); err != nil {
+		return types.MetricsSample{}, fmt.Errorf("failed to unmarshal gpu metrics: %w", err)
+	}
+	sample := types.MetricsSample{
+		Time:             m.SampledAt,
+		CPUPercent:       float64(m.CpuPercent),
+		MemoryUsedBytes:  m.MemUsedBytes,
+		MemoryTotalBytes: m.MemTotalBytes,
+		DiskUsedBytes:    m.DiskUsedBytes,
+		DiskTotalBytes:   m.DiskTotalBytes,
+		GPUs:             make([]types.GPUMetrics, len(gpus)),
+	}
+	for i, g := range gpus {
+		sample.GPUs[i] = types.GPUMetrics{
+			Index:              g.Index,
+			UtilizationPercent: g.Utilization,
+			MemoryUsedBytes:    g.MemoryUsed,
+			MemoryTotalBytes:   g.MemoryTotal,
+		}
+	}
+	return sample, nil
+}
+
+// collectMetrics samples the resource utilization of the session's node every
+// metricsInterval until ctx is done.
+func collectMetrics(ctx context.Context, sessionID string) {
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := collectMetricsSample(ctx, sessionID); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to collect session metrics")
+		}
+	}
+}
+
+func collectMetricsSample(ctx context.Context, sessionID string) error {
+	sess, err := db.Q.SessionGet(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session from db: %w", err)
+	}
+	if sess.Status != db.UnweaveSessionStatusRunning {
+		return nil
+	}
+	connInfo, err := parseConnectionInfo(sess.ConnectionInfo)
+	if err != nil {
+		return err
+	}
+
+	at := time.Now().UTC()
+	code, out, err := runNodeCommand(ctx, connInfo.toAPI(), metricsCommand)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("metrics command exited with code %d: %s", code, out)
+	}
+	sample, err := parseMetricsOutput(out, at)
+	if err != nil {
+		return err
+	}
+
+	gpus := make([]GPUMetricsV1, len(sample.GPUs))
+	for i, g := range sample.GPUs {
+		gpus[i] = GPUMetricsV1{
+			Index:       g.Index,
+			Utilization: g.UtilizationPercent,
+			MemoryUsed:  g.MemoryUsedBytes,
+			MemoryTotal: g.MemoryTotalBytes,
+		}
+	}
+	gpusJSON, err := json.Marshal(gpus)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gpu metrics: %w", err)
+	}
+
+	params := db.SessionMetricAddParams{
+		SessionID:      sessionID,
+		SampledAt:      sample.Time,
+		CpuPercent:     float32(sample.CPUPercent),
+		MemUsedBytes:   sample.MemoryUsedBytes,
+		MemTotalBytes:  sample.MemoryTotalBytes,
+		DiskUsedBytes:  sample.DiskUsedBytes,
+		DiskTotalBytes: sample.DiskTotalBytes,
+		Gpus:           gpusJSON,
+	}
+	if err = db.Q.SessionMetricAdd(ctx, params); err != nil {
+		return fmt.Errorf("failed to add session metrics to db: %w", err)
+	}
+	return nil
+}
+
+// Metrics returns the resource utilization samples of a session between from and to,
+// averaged over buckets of step.
+func (s *SessionService) Metrics(ctx context.Context, sessionID string, from, to time.Time, step time.Duration) (*types.SessionMetricsResponse, error) {
+	params := db.SessionMetricsGetParams{
+		SessionID: sessionID,
+		FromTime:  from,
+		ToTime:    to,
+	}
+	rows, err := db.Q.SessionMetricsGet(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session metrics from db: %w", err)
+	}
+
+	samples := make([]types.MetricsSample, len(rows))
+	for i, r := range rows {
+		if samples[i], err = dbSessionMetricToAPI(r); err != nil {
+			return nil, err
+		}
+	}
+	samples = downsampleMetrics(samples, from, step)
+	if samples == nil {
+		samples = []types.MetricsSample{}
+	}
+
+	return &types.SessionMetricsResponse{
+		From:    from,
+		To:      to,
+		Step:    int(step / time.Second),
+		Samples: samples,
+	}, nil
+}
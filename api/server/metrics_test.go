@@ -0,0 +1,144 @@
+package server
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/unweave/unweave/api/types"
+)
+
+func readTestdata(t *testing.T, name string) string {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal("Error reading test data", err)
+	}
+	return string(data)
+}
+
+func Test_parseMetricsOutput_GPU_Node(t *testing.T) {
+	at := time.Date(2023, 3, 30, 12, 0, 0, 0, time.UTC)
+	sample, err := parseMetricsOutput(readTestdata(t, "metrics_gpu.txt"), at)
+	if err != nil {
+		t.Fatal("parseMetricsOutput failed", err)
+	}
+
+	if !sample.Time.Equal(at) {
+		t.Errorf("Time = %v, want %v", sample.Time, at)
+	}
+	// 300 busy jiffies out of 1000.
+	if sample.CPUPercent != 30 {
+		t.Errorf("CPUPercent = %v, want 30", sample.CPUPercent)
+	}
+	if want := int64(263857324-253129876) * 1024; sample.MemoryUsedBytes != want {
+		t.Errorf("MemoryUsedBytes = %d, want %d", sample.MemoryUsedBytes, want)
+	}
+	if want := int64(263857324) * 1024; sample.MemoryTotalBytes != want {
+		t.Errorf("MemoryTotalBytes = %d, want %d", sample.MemoryTotalBytes, want)
+	}
+	if sample.DiskUsedBytes != 167893573632 || sample.DiskTotalBytes != 1476118810624 {
+		t.Errorf("Disk = %d/%d, want 167893573632/1476118810624", sample.DiskUsedBytes, sample.DiskTotalBytes)
+	}
+
+	want := []types.GPUMetrics{
+		{Index: 0, UtilizationPercent: 97, MemoryUsedBytes: 40215 * bytesPerMiB, MemoryTotalBytes: 81559 * bytesPerMiB},
+		{Index: 1, UtilizationPercent: 0, MemoryUsedBytes: 3 * bytesPerMiB, MemoryTotalBytes: 81559 * bytesPerMiB},
+		{Index: 2, UtilizationPercent: 0, MemoryUsedBytes: 3 * bytesPerMiB, MemoryTotalBytes: 81559 * bytesPerMiB},
+	}
+	if len(sample.GPUs) != len(want) {
+		t.Fatalf("got %d GPUs, want %d", len(sample.GPUs), len(want))
+	}
+	for i := range want {
+		if sample.GPUs[i] != want[i] {
+			t.Errorf("GPUs[%d] = %+v, want %+v", i, sample.GPUs[i], want[i])
+		}
+	}
+}
+
+func Test_parseMetricsOutput_CPU_Node(t *testing.T) {
+	sample, err := parseMetricsOutput(readTestdata(t, "metrics_cpu.txt"), time.Now())
+	if err != nil {
+		t.Fatal("parseMetricsOutput failed", err)
+	}
+	// No jiffies passed between the two readings.
+	if sample.CPUPercent != 0 {
+		t.Errorf("CPUPercent = %v, want 0", sample.CPUPercent)
+	}
+	if sample.GPUs == nil || len(sample.GPUs) != 0 {
+		t.Errorf("GPUs = %v, want an empty slice", sample.GPUs)
+	}
+	if sample.DiskUsedBytes != 3371110400 {
+		t.Errorf("DiskUsedBytes = %d, want 3371110400", sample.DiskUsedBytes)
+	}
+}
+
+func Test_parseMetricsOutput_Missing_Stat_Should_Fail(t *testing.T) {
+	out := "--- stat\ncpu  1 2 3 4 5 6 7 8 0 0\n--- meminfo\nMemTotal: 10 kB\nMemAvailable: 5 kB\n"
+	if _, err := parseMetricsOutput(out, time.Now()); err == nil {
+		t.Error("parseMetricsOutput should fail with a single /proc/stat reading")
+	}
+}
+
+func Test_parseCPUStat(t *testing.T) {
+	got, err := parseCPUStat("cpu  10 1 5 80 4 0 0 0 3 0")
+	if err != nil {
+		t.Fatal("parseCPUStat failed", err)
+	}
+	// guest time is part of user time and must not be counted twice.
+	if got.total != 100 || got.idle != 84 {
+		t.Errorf("parseCPUStat = %+v, want total 100 and idle 84", got)
+	}
+
+	for _, line := range []string{"", "cpu0 1 2 3 4", "cpu a b c d"} {
+		if _, err := parseCPUStat(line); err == nil {
+			t.Errorf("parseCPUStat(%q) should fail", line)
+		}
+	}
+}
+
+func Test_parseMeminfo_Missing_Fields_Should_Fail(t *testing.T) {
+	if _, _, err := parseMeminfo([]string{"MemTotal:       2035568 kB"}); err == nil {
+		t.Error("parseMeminfo should fail without MemAvailable")
+	}
+}
+
+func Test_parseDF_Invalid_Output_Should_Fail(t *testing.T) {
+	inputs := [][]string{
+		{"Filesystem 1-blocks Used Available Capacity Mounted on"},
+		{"Filesystem 1-blocks Used Available Capacity Mounted on", "/dev/root x y z 17% /"},
+	}
+	for _, lines := range inputs {
+		if _, _, err := parseDF(lines); err == nil {
+			t.Errorf("parseDF(%q) should fail", lines)
+		}
+	}
+}
+
+func Test_parseNvidiaSMI_Invalid_Line_Should_Fail(t *testing.T) {
+	if _, err := parseNvidiaSMI([]string{"NVIDIA-SMI has failed because it couldn't communicate with the NVIDIA driver."}); err == nil {
+		t.Error("parseNvidiaSMI should fail on nvidia-smi errors")
+	}
+}
+
+func Test_downsampleMetrics(t *testing.T) {
+	from := time.Date(2023, 3, 30, 12, 0, 0, 0, time.UTC)
+	samples := []types.MetricsSample{
+		{Time: from.Add(10 * time.Second), CPUPercent: 10, GPUs: []types.GPUMetrics{{Index: 0, UtilizationPercent: 50}}},
+		{Time: from.Add(40 * time.Second), CPUPercent: 30, GPUs: []types.GPUMetrics{{Index: 0, UtilizationPercent: 100}}},
+		{Time: from.Add(130 * time.Second), CPUPercent: 5, MemoryUsedBytes: 3},
+	}
+
+	got := downsampleMetrics(samples, from, time.Minute)
+	if len(got) != 2 {
+		t.Fatalf("got %d samples, want 2", len(got))
+	}
+	if !got[0].Time.Equal(from) || got[0].CPUPercent != 20 {
+		t.Errorf("got[0] = %+v, want an average CPU of 20 at %v", got[0], from)
+	}
+	if len(got[0].GPUs) != 1 || got[0].GPUs[0].UtilizationPercent != 75 {
+		t.Errorf("got[0].GPUs = %+v, want an average utilization of 75", got[0].GPUs)
+	}
+	if want := from.Add(2 * time.Minute); !got[1].Time.Equal(want) || got[1].MemoryUsedBytes != 3 {
+		t.Errorf("got[1] = %+v, want the sample at %v", got[1], want)
+	}
+}
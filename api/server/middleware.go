@@ -2,14 +2,18 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/agent/protocol"
 	"github.com/unweave/unweave/api/types"
 	"github.com/unweave/unweave/db"
 )
@@ -20,9 +24,12 @@ import (
 const (
 	AccountIDCtxKey     = "accountID"
 	BuildIDCtxKey       = "buildID"
+	ClusterIDCtxKey     = "cluster"
+	PipelineIDCtxKey    = "pipeline"
 	ProjectIDCtxKey     = "project"
 	SessionIDCtxKey     = "session"
 	SessionStatusCtxKey = "sessionStatus"
+	SweepIDCtxKey       = "sweep"
 )
 
 func SetAccountIDInContext(ctx context.Context, uid uuid.UUID) context.Context {
@@ -103,7 +110,7 @@ func withProjectCtx(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx = context.WithValue(ctx, ProjectIDCtxKey, project)
+		ctx = SetProjectIDInContext(ctx, project.ID)
 		ctx = log.With().Str(ProjectIDCtxKey, project.ID).Logger().WithContext(ctx)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -111,7 +118,7 @@ func withProjectCtx(next http.Handler) http.Handler {
 }
 
 // withSessionCtx is a helper middleware that parsed the session id from the url and
-// verifies it exists in the db.
+// verifies it exists in the db and belongs to the project in the context.
 func withSessionCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -133,8 +140,96 @@ func withSessionCtx(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx = context.WithValue(ctx, SessionIDCtxKey, session)
+		// Sessions are only accessible through the project they belong to.
+		if session.ProjectID != GetProjectIDFromContext(ctx) {
+			render.Render(w, r.WithContext(ctx), &types.Error{
+				Code:       http.StatusNotFound,
+				Message:    "Session not found",
+				Suggestion: "Make sure the session id is valid",
+			})
+			return
+		}
+
+		ctx = SetSessionIDInContext(ctx, session.ID)
 		ctx = log.With().Str(SessionIDCtxKey, session.ID).Logger().WithContext(ctx)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// withAgentCtx is a helper middleware that authenticates node agents by their token and
+// rejects agents that speak a different protocol version.
+func withAgentCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if v := r.Header.Get(protocol.HeaderVersion); v != strconv.Itoa(protocol.Version) {
+			render.Render(w, r.WithContext(ctx), &types.Error{
+				Code:       http.StatusUpgradeRequired,
+				Message:    fmt.Sprintf("Unsupported agent protocol version %q", v),
+				Suggestion: fmt.Sprintf("The API speaks version %d", protocol.Version),
+			})
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		agent, err := db.Q.SessionAgentGetByToken(ctx, hashAgentToken(token))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				render.Render(w, r.WithContext(ctx), &types.Error{
+					Code:    http.StatusUnauthorized,
+					Message: "Invalid agent token",
+				})
+				return
+			}
+
+			err = fmt.Errorf("failed to fetch session agent from db: %w", err)
+			render.Render(w, r.WithContext(ctx), ErrInternalServer(err, "Failed to authenticate agent"))
+			return
+		}
+		session, err := db.Q.SessionGet(ctx, agent.SessionID)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch session from db %q: %w", agent.SessionID, err)
+			render.Render(w, r.WithContext(ctx), ErrInternalServer(err, "Failed to authenticate agent"))
+			return
+		}
+
+		ctx = SetAccountIDInContext(ctx, session.CreatedBy)
+		ctx = SetProjectIDInContext(ctx, session.ProjectID)
+		ctx = SetSessionIDInContext(ctx, session.ID)
+		ctx = log.With().
+			Stringer(AccountIDCtxKey, session.CreatedBy).
+			Str(SessionIDCtxKey, session.ID).
+			Logger().WithContext(ctx)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// adminToken is the bearer token that grants access to the admin API. The admin API is
+// disabled if it is empty. It is initialized when the API starts.
+var adminToken string
+
+// withAdminCtx is a helper middleware that only lets through requests that carry the
+// admin token.
+func withAdminCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if adminToken == "" {
+			render.Render(w, r.WithContext(ctx), &types.Error{
+				Code:       http.StatusForbidden,
+				Message:    "The admin API is disabled",
+				Suggestion: "Set UNWEAVE_ADMIN_TOKEN to enable it",
+			})
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			render.Render(w, r.WithContext(ctx), &types.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "Invalid admin token",
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
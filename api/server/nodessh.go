@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// platformSSHKeyName prefixes the name the platform SSH key is registered under with
+// providers. The name ends in a fingerprint of the key so that a new key is registered
+// if the key changes.
+const platformSSHKeyName = "uw:platform"
+
+// nodeSSHRetries and nodeSSHRetryInterval control how long the API waits for a node's
+// sshd to accept connections once the node is running.
+var (
+	nodeSSHRetries       = 12
+	nodeSSHRetryInterval = 10 * time.Second
+)
+
+// platformSigner is the SSH identity the API uses to reach the nodes it launches, e.g. to
+// run pipeline steps or to proxy gateway connections. It is initialized when the API
+// starts.
+var platformSigner ssh.Signer
+
+// loadSigner reads an SSH private key from path. If no path is configured, an ephemeral
+// key is generated. Nodes launched with an ephemeral platform key can't be reached after
+// the API restarts so this should only be used in development.
+func loadSigner(path, name string) (ssh.Signer, error) {
+	if path == "" {
+		log.Warn().Msgf("No %s SSH key configured, generating an ephemeral key", name)
+		prv, _, err := createSSHKeyPair()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s ssh key: %w", name, err)
+		}
+		return ssh.ParsePrivateKey([]byte(prv))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s ssh key: %w", name, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s ssh key: %w", name, err)
+	}
+	return signer, nil
+}
+
+// platformSSHKey returns the platform key as a types.SSHKey that can be registered with
+// providers.
+func platformSSHKey() types.SSHKey {
+	pub := string(ssh.MarshalAuthorizedKey(platformSigner.PublicKey()))
+	sum := sha256.Sum256(platformSigner.PublicKey().Marshal())
+	return types.SSHKey{
+		Name:      platformSSHKeyName + "-" + hex.EncodeToString(sum[:4]),
+		PublicKey: &pub,
+	}
+}
+
+// shellQuote joins args into a single string that is safe to pass to a POSIX shell.
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'"'"'`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// dialNode opens an SSH connection to a node with the platform key. The node's host key
+// is verified if it is known.
+func dialNode(ctx context.Context, conn types.ConnectionInfo) (*ssh.Client, error) {
+	if platformSigner == nil {
+		return nil, errors.New("platform ssh key not initialized")
+	}
+
+	// The host key of a node is only known once it has been captured after the node
+	// started running. Until then the node is trusted on first use.
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if conn.HostKey != "" {
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(conn.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key: %w", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(hostKey)
+	}
+
+	cfg := &ssh.ClientConfig{
+		User:            conn.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(platformSigner)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	}
+	addr := net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port))
+
+	d := net.Dialer{Timeout: cfg.Timeout}
+	c, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial node %q: %w", addr, err)
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(c, addr, cfg)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to establish ssh connection to %q: %w", addr, err)
+	}
+	return ssh.NewClient(sc, chans, reqs), nil
+}
+
+// runNodeCommand runs cmd on a node over SSH and returns its exit code and combined
+// output. A non-zero exit code is not an error.
+func runNodeCommand(ctx context.Context, conn types.ConnectionInfo, cmd string) (int, string, error) {
+	client, err := dialNode(ctx, conn)
+	if err != nil {
+		return -1, "", err
+	}
+	defer client.Close()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to create ssh session: %w", err)
+	}
+	defer sess.Close()
+
+	var out bytes.Buffer
+	sess.Stdout = &out
+	sess.Stderr = &out
+
+	done := make(chan error, 1)
+	go func() { done <- sess.Run(cmd) }()
+
+	select {
+	case <-ctx.Done():
+		_ = sess.Signal(ssh.SIGKILL)
+		return -1, out.String(), ctx.Err()
+	case err = <-done:
+	}
+
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitStatus(), out.String(), nil
+		}
+		return -1, out.String(), fmt.Errorf("failed to run command: %w", err)
+	}
+	return 0, out.String(), nil
+}
+
+// authorizeNodeKey adds publicKey to the authorized keys of the node's user if it isn't
+// there yet. sshd might not accept connections right after the provider reports the
+// node as running so connection failures are retried.
+func authorizeNodeKey(ctx context.Context, conn types.ConnectionInfo, publicKey string) error {
+	key := shellQuote([]string{strings.TrimSpace(publicKey)})
+	cmd := "mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys && " +
+		"(grep -qxF " + key + " ~/.ssh/authorized_keys || echo " + key + " >> ~/.ssh/authorized_keys)"
+
+	for attempt := 1; ; attempt++ {
+		code, out, err := runNodeCommand(ctx, conn, cmd)
+		if err == nil {
+			if code != 0 {
+				return fmt.Errorf("failed to authorize ssh key, exit code %d: %s", code, out)
+			}
+			return nil
+		}
+		if attempt == nodeSSHRetries {
+			return fmt.Errorf("failed to authorize ssh key: %w", err)
+		}
+		log.Ctx(ctx).Warn().Err(err).Msgf("Failed to reach node, retrying (attempt %d/%d)", attempt, nodeSSHRetries)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(nodeSSHRetryInterval):
+		}
+	}
+}
+
+// pushNodeFile writes the contents of r to path on the node. The file is written to a
+// temporary path first so that it is replaced atomically.
+func pushNodeFile(ctx context.Context, conn types.ConnectionInfo, path string, mode os.FileMode, r io.Reader) error {
+	client, err := dialNode(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create ssh session: %w", err)
+	}
+	defer sess.Close()
+
+	var out bytes.Buffer
+	sess.Stdin = r
+	sess.Stdout = &out
+	sess.Stderr = &out
+
+	p := shellQuote([]string{path})
+	tmp := shellQuote([]string{path + ".uwtmp"})
+	cmd := fmt.Sprintf("mkdir -p \"$(dirname %s)\" && cat > %s && chmod %o %s && mv %s %s",
+		p, tmp, mode.Perm(), tmp, tmp, p)
+
+	done := make(chan error, 1)
+	go func() { done <- sess.Run(cmd) }()
+
+	select {
+	case <-ctx.Done():
+		_ = sess.Signal(ssh.SIGKILL)
+		return ctx.Err()
+	case err = <-done:
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w: %s", path, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
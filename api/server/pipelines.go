@@ -0,0 +1,490 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// pipelineCancelPollInterval is how often a running pipeline checks if it was canceled.
+var pipelineCancelPollInterval = 10 * time.Second
+
+// PipelineStepSpecV1 versions the step definition stored in the DB.
+type PipelineStepSpecV1 struct {
+	Version int                      `json:"version"`
+	Params  types.PipelineStepParams `json:"params"`
+}
+
+func dbPipelineStepToAPI(s db.UnweavePipelineStep) (types.PipelineStep, error) {
+	spec := PipelineStepSpecV1{}
+	if err := json.Unmarshal(s.Spec, &spec); err != nil {
+		return types.PipelineStep{}, fmt.Errorf("failed to unmarshal step spec: %w", err)
+	}
+	step := types.PipelineStep{
+		ID:     s.ID,
+		Name:   s.Name,
+		Status: types.PipelineStatus(s.Status),
+		Params: spec.Params,
+	}
+	if s.SessionID.Valid {
+		step.SessionID = &s.SessionID.String
+	}
+	if s.ExitCode.Valid {
+		code := int(s.ExitCode.Int32)
+		step.ExitCode = &code
+	}
+	if s.StartedAt.Valid {
+		step.StartedAt = &s.StartedAt.Time
+	}
+	if s.FinishedAt.Valid {
+		step.FinishedAt = &s.FinishedAt.Time
+	}
+	if s.Error.Valid {
+		step.Error = &s.Error.String
+	}
+	return step, nil
+}
+
+type PipelineService struct {
+	srv *Service
+}
+
+func (p *PipelineService) Create(ctx context.Context, projectID string, params types.PipelineCreateParams) (*types.Pipeline, error) {
+	project, err := db.Q.ProjectGet(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project from db: %w", err)
+	}
+
+	// Fail early if any of the build images can't be used
+	for i, step := range params.Steps {
+		if step.BuildID == nil && project.DefaultBuild.Valid {
+			params.Steps[i].BuildID = &project.DefaultBuild.String
+		}
+		if params.Steps[i].BuildID == nil {
+			continue
+		}
+		if _, err = p.srv.Builder.GetImageURI(ctx, projectID, *params.Steps[i].BuildID); err != nil {
+			return nil, err
+		}
+	}
+
+	sshKey, err := fetchCredentials(ctx, p.srv.cid, params.SSHKeyName, params.SSHPublicKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pipeline and its steps are created together so that a pipeline is never
+	// resumed with only some of its steps.
+	var pipelineID string
+	err = db.Tx(ctx, func(q db.Querier) error {
+		pipelineID, err = q.PipelineCreate(ctx, db.PipelineCreateParams{
+			Name:       params.Name,
+			ProjectID:  projectID,
+			CreatedBy:  p.srv.cid,
+			SshKeyName: sshKey.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create pipeline in db: %w", err)
+		}
+		for _, step := range params.Steps {
+			spec, err := json.Marshal(PipelineStepSpecV1{Version: 1, Params: step})
+			if err != nil {
+				return fmt.Errorf("failed to marshal step spec: %w", err)
+			}
+			sp := db.PipelineStepCreateParams{
+				PipelineID: pipelineID,
+				Name:       step.Name,
+				Spec:       spec,
+			}
+			if _, err = q.PipelineStepCreate(ctx, sp); err != nil {
+				return fmt.Errorf("failed to create pipeline step in db: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Get(ctx, projectID, pipelineID)
+}
+
+func (p *PipelineService) Get(ctx context.Context, projectID, pipelineID string) (*types.Pipeline, error) {
+	pl, err := db.Q.PipelineGet(ctx, pipelineID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &types.Error{
+				Code:    http.StatusNotFound,
+				Message: "Pipeline not found",
+			}
+		}
+		return nil, fmt.Errorf("failed to get pipeline from db: %w", err)
+	}
+	if pl.ProjectID != projectID {
+		return nil, &types.Error{
+			Code:    http.StatusNotFound,
+			Message: "Pipeline not found",
+		}
+	}
+
+	steps, err := db.Q.PipelineStepsGet(ctx, pipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipeline steps from db: %w", err)
+	}
+
+	pipeline := &types.Pipeline{
+		ID:        pl.ID,
+		Name:      pl.Name,
+		Status:    types.PipelineStatus(pl.Status),
+		CreatedAt: pl.CreatedAt,
+		Steps:     make([]types.PipelineStep, len(steps)),
+	}
+	if pl.Error.Valid {
+		pipeline.Error = &pl.Error.String
+	}
+	for i, s := range steps {
+		step, err := dbPipelineStepToAPI(s)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Steps[i] = step
+	}
+	return pipeline, nil
+}
+
+func (p *PipelineService) List(ctx context.Context, projectID string) ([]types.Pipeline, error) {
+	pipelines, err := db.Q.PipelinesGet(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipelines from db: %w", err)
+	}
+
+	res := make([]types.Pipeline, len(pipelines))
+	for i, pl := range pipelines {
+		pipeline, err := p.Get(ctx, projectID, pl.ID)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = *pipeline
+	}
+	return res, nil
+}
+
+// Cancel marks a pipeline as canceled. The executor running the pipeline picks this up,
+// stops its running steps and cancels the ones that haven't started yet.
+func (p *PipelineService) Cancel(ctx context.Context, projectID, pipelineID string) error {
+	pipeline, err := p.Get(ctx, projectID, pipelineID)
+	if err != nil {
+		return err
+	}
+	if pipeline.Status.IsTerminal() {
+		return &types.Error{
+			Code:    http.StatusConflict,
+			Message: fmt.Sprintf("Pipeline already %s", pipeline.Status),
+		}
+	}
+
+	params := db.PipelineStatusUpdateParams{
+		ID:     pipelineID,
+		Status: db.UnweavePipelineStatusCanceled,
+		Error:  sql.NullString{String: "Canceled by user", Valid: true},
+	}
+	if err = db.Q.PipelineStatusUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update pipeline status: %w", err)
+	}
+	publishPipelineStatus(ctx, projectID, pipelineID, params.Status, params.Error.String)
+	return nil
+}
+
+// publishPipelineStatus publishes the outcome of a pipeline.
+func publishPipelineStatus(ctx context.Context, projectID, pipelineID string, status db.UnweavePipelineStatus, errMsg string) {
+	var event types.EventType
+	switch status {
+	case db.UnweavePipelineStatusSucceeded:
+		event = types.EventPipelineSucceeded
+	case db.UnweavePipelineStatusFailed:
+		event = types.EventPipelineFailed
+	case db.UnweavePipelineStatusCanceled:
+		event = types.EventPipelineCanceled
+	default:
+		return
+	}
+	data := types.PipelineEventData{PipelineID: pipelineID, Status: types.PipelineStatus(status)}
+	if errMsg != "" {
+		data.Error = &errMsg
+	}
+	publishEvent(ctx, projectID, event, data)
+}
+
+type stepResult struct {
+	name   string
+	status types.PipelineStatus
+}
+
+// Execute runs a pipeline in the background. Steps are launched as soon as all the steps
+// they depend on have succeeded. If a step fails, all steps downstream of it are
+// canceled and the remaining steps run to completion. Only one replica of the API runs
+// a pipeline at a time.
+func (p *PipelineService) Execute(ctx context.Context, pipelineID string) error {
+	pl, err := db.Q.PipelineGet(ctx, pipelineID)
+	if err != nil {
+		return fmt.Errorf("failed to get pipeline from db: %w", err)
+	}
+
+	// The steps are read under the lease so that they aren't changed by another replica
+	// in the meantime.
+	leaseKey := pipelineLeaseKey(pipelineID)
+	leaseCtx, ok, err := leases.acquire(ctx, leaseKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Ctx(ctx).Info().Msgf("Pipeline %s is already executing", pipelineID)
+		return nil
+	}
+
+	pipeline, err := p.Get(ctx, pl.ProjectID, pipelineID)
+	if err != nil {
+		leases.release(ctx, leaseKey)
+		return err
+	}
+	params := db.PipelineStatusUpdateParams{ID: pipelineID, Status: db.UnweavePipelineStatusRunning}
+	if err = db.Q.PipelineStatusUpdate(ctx, params); err != nil {
+		leases.release(ctx, leaseKey)
+		return fmt.Errorf("failed to update pipeline status: %w", err)
+	}
+
+	log.Ctx(ctx).Info().Msgf("Starting to execute pipeline %s", pipelineID)
+
+	go func() {
+		defer leases.release(ctx, leaseKey)
+		p.execute(leaseCtx, pl.ProjectID, pl.SshKeyName, pipeline)
+	}()
+	return nil
+}
+
+func (p *PipelineService) execute(ctx context.Context, projectID, sshKeyName string, pipeline *types.Pipeline) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	steps := make(map[string]types.PipelineStep, len(pipeline.Steps))
+	for _, s := range pipeline.Steps {
+		if s.Status == types.PipelineRunning {
+			// The API restarted while this step was running so there's no way to
+			// recover its result. Its node is terminated as nothing watches it anymore.
+			if s.SessionID != nil {
+				NewCtxService(p.srv.rti, p.srv.cid).Session.terminateInterruptedJob(ctx, *s.SessionID)
+			}
+			p.finishStep(ctx, s, types.PipelineFailed, nil, "Interrupted by an API restart")
+			s.Status = types.PipelineFailed
+		}
+		steps[s.Name] = s
+	}
+
+	// Buffered so that steps can finish after the executor stopped on losing the lease.
+	done := make(chan stepResult, len(steps))
+	running := 0
+	canceled := false
+
+	ticker := time.NewTicker(pipelineCancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if errors.Is(leaseCause(ctx), errLeaseLost) {
+			// Leave the steps as they are to the replica that adopts the pipeline.
+			log.Ctx(ctx).Warn().Msgf("Lost the lease of pipeline %s, stopping", pipeline.ID)
+			return
+		}
+
+		// Cancel steps that can never run. Canceling a step can make its dependents
+		// cancelable so repeat until nothing changes.
+		for changed := true; changed; {
+			changed = false
+			for name, s := range steps {
+				if s.Status != types.PipelinePending {
+					continue
+				}
+				msg := ""
+				if canceled {
+					msg = "Pipeline canceled"
+				}
+				for _, dep := range s.Params.DependsOn {
+					depStatus := steps[dep].Status
+					if depStatus == types.PipelineFailed || depStatus == types.PipelineCanceled {
+						msg = fmt.Sprintf("Upstream step %q did not succeed", dep)
+					}
+				}
+				if msg != "" {
+					p.finishStep(ctx, s, types.PipelineCanceled, nil, msg)
+					s.Status = types.PipelineCanceled
+					steps[name] = s
+					changed = true
+				}
+			}
+		}
+
+		for name, s := range steps {
+			if s.Status != types.PipelinePending || !depsSucceeded(steps, s) {
+				continue
+			}
+			if err := db.Q.PipelineStepStart(ctx, s.ID); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msgf("Failed to start step %q", name)
+				continue
+			}
+			s.Status = types.PipelineRunning
+			steps[name] = s
+			running++
+			go func(s types.PipelineStep) {
+				done <- stepResult{name: s.Name, status: p.runStep(ctx, projectID, sshKeyName, s)}
+			}(s)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		select {
+		case res := <-done:
+			running--
+			s := steps[res.name]
+			s.Status = res.status
+			steps[res.name] = s
+		case <-ticker.C:
+			pl, err := db.Q.PipelineGet(ctx, pipeline.ID)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("Failed to check pipeline status")
+				continue
+			}
+			if pl.Status == db.UnweavePipelineStatusCanceled && !canceled {
+				log.Ctx(ctx).Info().Msgf("Pipeline %s canceled, stopping running steps", pipeline.ID)
+				canceled = true
+				cancel()
+			}
+		}
+	}
+
+	// Record the pipeline-level outcome. A pipeline fails if any of its steps failed.
+	status, errMsg := db.UnweavePipelineStatusSucceeded, ""
+	for name, s := range steps {
+		if s.Status == types.PipelineFailed {
+			status, errMsg = db.UnweavePipelineStatusFailed, fmt.Sprintf("Step %q failed", name)
+			break
+		}
+		if s.Status == types.PipelineCanceled {
+			status = db.UnweavePipelineStatusCanceled
+		}
+	}
+	if canceled {
+		// Keep the status and reason set by Cancel
+		return
+	}
+	if errors.Is(leaseCause(ctx), errLeaseLost) {
+		log.Ctx(ctx).Warn().Msgf("Lost the lease of pipeline %s, stopping", pipeline.ID)
+		return
+	}
+
+	// The pipeline can be canceled after its last step finished. Cancel already recorded
+	// and published the outcome in that case.
+	params := db.PipelineFinishParams{
+		ID:     pipeline.ID,
+		Status: status,
+		Error:  sql.NullString{String: errMsg, Valid: errMsg != ""},
+	}
+	if _, err := db.Q.PipelineFinish(context.Background(), params); err != nil {
+		if err == sql.ErrNoRows {
+			log.Ctx(ctx).Info().Msgf("Pipeline %s was canceled", pipeline.ID)
+			return
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to update pipeline status")
+	}
+	publishPipelineStatus(context.Background(), projectID, pipeline.ID, status, errMsg)
+	log.Ctx(ctx).Info().Msgf("Pipeline %s finished with status %q", pipeline.ID, status)
+}
+
+// depsSucceeded returns true if all the steps s depends on have succeeded.
+func depsSucceeded(steps map[string]types.PipelineStep, s types.PipelineStep) bool {
+	for _, dep := range s.Params.DependsOn {
+		if steps[dep].Status != types.PipelineSucceeded {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *PipelineService) runStep(ctx context.Context, projectID, sshKeyName string, step types.PipelineStep) types.PipelineStatus {
+	ctx = log.With().Str("pipelineStep", step.Name).Logger().WithContext(ctx)
+
+	spec := jobSpec{
+		Provider:   step.Params.Provider,
+		NodeTypeID: step.Params.NodeTypeID,
+		Region:     step.Params.Region,
+		BuildID:    step.Params.BuildID,
+		Command:    step.Params.Command,
+		Artifacts:  step.Params.Artifacts,
+		SSHKeyName: sshKeyName,
+	}
+	onSession := func(sessionID string) {
+		params := db.PipelineStepSetSessionParams{
+			ID:        step.ID,
+			SessionID: sql.NullString{String: sessionID, Valid: true},
+		}
+		if err := db.Q.PipelineStepSetSession(ctx, params); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to set step session")
+		}
+	}
+
+	// Steps run concurrently and can use different providers so each gets its own
+	// service instead of sharing the pipeline's cached runtime.
+	srv := NewCtxService(p.srv.rti, p.srv.cid)
+	res, err := srv.Session.runJob(ctx, projectID, spec, onSession)
+	if errors.Is(leaseCause(ctx), errLeaseLost) {
+		// The step is left running for the replica that adopts the pipeline.
+		return types.PipelineRunning
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			p.finishStep(ctx, step, types.PipelineCanceled, nil, "Pipeline canceled")
+			return types.PipelineCanceled
+		}
+		msg := err.Error()
+		var e *types.Error
+		if errors.As(err, &e) {
+			msg = e.Message
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Pipeline step failed")
+		p.finishStep(ctx, step, types.PipelineFailed, nil, msg)
+		return types.PipelineFailed
+	}
+
+	log.Ctx(ctx).Info().Str("output", res.Output).Msgf("Step exited with code %d", res.ExitCode)
+
+	if res.ExitCode != 0 {
+		msg := fmt.Sprintf("Command exited with code %d", res.ExitCode)
+		p.finishStep(ctx, step, types.PipelineFailed, &res.ExitCode, msg)
+		return types.PipelineFailed
+	}
+	p.finishStep(ctx, step, types.PipelineSucceeded, &res.ExitCode, "")
+	return types.PipelineSucceeded
+}
+
+func (p *PipelineService) finishStep(ctx context.Context, step types.PipelineStep, status types.PipelineStatus, exitCode *int, msg string) {
+	params := db.PipelineStepFinishParams{
+		ID:     step.ID,
+		Status: db.UnweavePipelineStatus(status),
+		Error:  sql.NullString{String: msg, Valid: msg != ""},
+	}
+	if exitCode != nil {
+		params.ExitCode = sql.NullInt32{Int32: int32(*exitCode), Valid: true}
+	}
+	// The step context might already be canceled but the result still needs recording.
+	if err := db.Q.PipelineStepFinish(context.Background(), params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to finish step %q", step.Name)
+	}
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"golang.org/x/crypto/ssh"
+)
+
+// apiPublicURL is the externally reachable URL of the API. It is used to build the URLs
+// of exposed ports and is initialized when the API starts.
+var apiPublicURL string
+
+// tunnelPool keeps one SSH connection open per session to forward exposed port traffic
+// over.
+type tunnelPool struct {
+	mu      sync.Mutex
+	tunnels map[string]*tunnel
+}
+
+// tunnel is the SSH connection of a session. ready is closed once the connection is
+// dialed, after which client or err is set. Requests for the same session wait for the
+// same dial while other sessions aren't blocked by it.
+type tunnel struct {
+	ready  chan struct{}
+	client *ssh.Client
+	err    error
+}
+
+var nodeTunnels = &tunnelPool{tunnels: make(map[string]*tunnel)}
+
+func (p *tunnelPool) client(sessionID string) (*ssh.Client, error) {
+	p.mu.Lock()
+	t, ok := p.tunnels[sessionID]
+	if !ok {
+		t = &tunnel{ready: make(chan struct{})}
+		p.tunnels[sessionID] = t
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		p.connect(sessionID, t)
+	}
+	<-t.ready
+	return t.client, t.err
+}
+
+// connect dials the session's node and publishes the result on t. Failed tunnels are
+// removed so that the next request dials again.
+func (p *tunnelPool) connect(sessionID string, t *tunnel) {
+	// The connection outlives the request that opened it.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := dialSession(ctx, sessionID)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// ready is closed under the lock so that close either sees the client or leaves
+	// closing it to here.
+	defer close(t.ready)
+
+	if p.tunnels[sessionID] != t {
+		// The tunnel was closed while it was being dialed.
+		if err == nil {
+			_ = c.Close()
+		}
+		t.err = fmt.Errorf("tunnel to session %s was closed", sessionID)
+		return
+	}
+	if err != nil {
+		delete(p.tunnels, sessionID)
+		t.err = err
+		return
+	}
+	t.client = c
+
+	go func() {
+		_ = c.Wait()
+		p.mu.Lock()
+		if p.tunnels[sessionID] == t {
+			delete(p.tunnels, sessionID)
+		}
+		p.mu.Unlock()
+	}()
+}
+
+// dial opens a connection to port on the session's node. The port is dialed on the
+// node's loopback interface so it doesn't need to be reachable from outside the node.
+func (p *tunnelPool) dial(sessionID, port string) (net.Conn, error) {
+	c, err := p.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return c.Dial("tcp", net.JoinHostPort("localhost", port))
+}
+
+// close closes the session's SSH connection if there is one. A connection that is still
+// being dialed is closed as soon as it is established.
+func (p *tunnelPool) close(sessionID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t, ok := p.tunnels[sessionID]
+	if !ok {
+		return
+	}
+	delete(p.tunnels, sessionID)
+	select {
+	case <-t.ready:
+		if t.client != nil {
+			_ = t.client.Close()
+		}
+	default:
+	}
+}
+
+// tunnelTransport sends requests through the tunnel of the session whose ID is the host
+// of the request URL, e.g. http://se_xxx:8888/.
+var tunnelTransport = &http.Transport{
+	DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		sessionID, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return nodeTunnels.dial(sessionID, port)
+	},
+	MaxIdleConnsPerHost: 8,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+func portBasePath(projectID, sessionID string, port int) string {
+	return fmt.Sprintf("/projects/%s/sessions/%s/ports/%d/", projectID, sessionID, port)
+}
+
+func dbExposedPortToAPI(projectID string, p db.UnweaveSessionExposedPort) types.ExposedPort {
+	basePath := portBasePath(projectID, p.SessionID, int(p.Port))
+	return types.ExposedPort{
+		Port:      int(p.Port),
+		URL:       strings.TrimSuffix(apiPublicURL, "/") + basePath,
+		BasePath:  basePath,
+		CreatedAt: p.CreatedAt,
+	}
+}
+
+func sessionExposedPorts(ctx context.Context, projectID, sessionID string) ([]types.ExposedPort, error) {
+	ports, err := db.Q.SessionExposedPortsGet(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exposed ports from db: %w", err)
+	}
+	res := make([]types.ExposedPort, len(ports))
+	for i, p := range ports {
+		res[i] = dbExposedPortToAPI(projectID, p)
+	}
+	return res, nil
+}
+
+// ExposePort serves a port of the session's node over HTTP through the API. Exposing a
+// port that is already exposed is a no-op.
+func (s *SessionService) ExposePort(ctx context.Context, sessionID string, port int) (*types.ExposedPort, error) {
+	sess, err := db.Q.SessionGet(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session from db: %w", err)
+	}
+	if sess.Status == db.UnweaveSessionStatusTerminated || sess.Status == db.UnweaveSessionStatusError {
+		return nil, &types.Error{
+			Code:    http.StatusConflict,
+			Message: fmt.Sprintf("Can't expose ports of a session that is %s", sess.Status),
+		}
+	}
+
+	params := db.SessionExposedPortAddParams{SessionID: sessionID, Port: int32(port)}
+	if err = db.Q.SessionExposedPortAdd(ctx, params); err != nil {
+		return nil, fmt.Errorf("failed to add exposed port to db: %w", err)
+	}
+
+	ports, err := sessionExposedPorts(ctx, sess.ProjectID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ports {
+		if p.Port == port {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("exposed port %d not found after adding it", port)
+}
+
+func (s *SessionService) UnexposePort(ctx context.Context, sessionID string, port int) error {
+	if err := s.checkPortExposed(ctx, sessionID, port); err != nil {
+		return err
+	}
+	params := db.SessionExposedPortRemoveParams{SessionID: sessionID, Port: int32(port)}
+	if err := db.Q.SessionExposedPortRemove(ctx, params); err != nil {
+		return fmt.Errorf("failed to remove exposed port from db: %w", err)
+	}
+	return nil
+}
+
+func (s *SessionService) checkPortExposed(ctx context.Context, sessionID string, port int) error {
+	ports, err := db.Q.SessionExposedPortsGet(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get exposed ports from db: %w", err)
+	}
+	for _, p := range ports {
+		if int(p.Port) == port {
+			return nil
+		}
+	}
+	return &types.Error{
+		Code:       http.StatusNotFound,
+		Message:    fmt.Sprintf("Port %d is not exposed", port),
+		Suggestion: "Expose the port on the session first",
+	}
+}
+
+// stripAPICredentials removes the caller's Unweave credentials from a request before it
+// is forwarded to an application on a node. Cookies set by the application itself are
+// kept.
+func stripAPICredentials(r *http.Request) {
+	r.Header.Del("Authorization")
+	r.Header.Del("Proxy-Authorization")
+
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if strings.HasPrefix(strings.ToLower(c.Name), "unweave") {
+			continue
+		}
+		r.AddCookie(c)
+	}
+}
+
+// ProxyPort returns a handler that forwards requests to an exposed port of the session's
+// node through an SSH tunnel. Websocket upgrades are supported.
+func (s *SessionService) ProxyPort(ctx context.Context, sessionID string, port int) (http.Handler, error) {
+	if err := s.checkPortExposed(ctx, sessionID, port); err != nil {
+		return nil, err
+	}
+
+	host := net.JoinHostPort(sessionID, strconv.Itoa(port))
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = "http"
+			r.URL.Host = host
+			// The Host header is kept so that applications that check the origin of
+			// requests, such as Jupyter, see the URL the user is browsing.
+			stripAPICredentials(r)
+		},
+		Transport: tunnelTransport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Ctx(r.Context()).Warn().Err(err).Msgf("Failed to proxy request to port %d", port)
+			render.Render(w, r, &types.Error{
+				Code:       http.StatusBadGateway,
+				Message:    fmt.Sprintf("Failed to reach port %d on the session", port),
+				Suggestion: "Make sure the session is running and an application is listening on the port",
+				Err:        err,
+			})
+		},
+	}
+	return proxy, nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+type ProjectService struct {
+	srv *Service
+}
+
+func (p *ProjectService) get(ctx context.Context, projectID string) (db.UnweaveProject, error) {
+	project, err := db.Q.ProjectGet(ctx, projectID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.UnweaveProject{}, &types.Error{
+				Code:       http.StatusNotFound,
+				Message:    "Project not found",
+				Suggestion: "Make sure the project id is valid",
+			}
+		}
+		return db.UnweaveProject{}, fmt.Errorf("failed to get project from db: %w", err)
+	}
+	return project, nil
+}
+
+// WatchPolicy returns the watch policy of the project. Projects that haven't set one use
+// the default policy.
+func (p *ProjectService) WatchPolicy(ctx context.Context, projectID string) (types.WatchPolicy, error) {
+	project, err := p.get(ctx, projectID)
+	if err != nil {
+		return types.WatchPolicy{}, err
+	}
+	return parseWatchPolicy(project.WatchPolicy)
+}
+
+func (p *ProjectService) SetWatchPolicy(ctx context.Context, projectID string, policy types.WatchPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(WatchPolicyV1{Version: 1, Policy: policy})
+	if err != nil {
+		return fmt.Errorf("failed to marshal watch policy: %w", err)
+	}
+	params := db.ProjectWatchPolicyUpdateParams{ID: projectID, WatchPolicy: data}
+	if err = db.Q.ProjectWatchPolicyUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update watch policy: %w", err)
+	}
+	return nil
+}
+
+// ProjectDefaultsV1 versions the project defaults stored in the DB.
+type ProjectDefaultsV1 struct {
+	Version  int                   `json:"version"`
+	Defaults types.ProjectDefaults `json:"defaults"`
+}
+
+func parseProjectDefaults(data json.RawMessage) (types.ProjectDefaults, error) {
+	d := ProjectDefaultsV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &d); err != nil {
+			return types.ProjectDefaults{}, fmt.Errorf("failed to unmarshal project defaults: %w", err)
+		}
+	}
+	return d.Defaults, nil
+}
+
+func (p *ProjectService) Defaults(ctx context.Context, projectID string) (types.ProjectDefaults, error) {
+	project, err := p.get(ctx, projectID)
+	if err != nil {
+		return types.ProjectDefaults{}, err
+	}
+	return parseProjectDefaults(project.Defaults)
+}
+
+func (p *ProjectService) SetDefaults(ctx context.Context, projectID string, defaults types.ProjectDefaults) error {
+	data, err := json.Marshal(ProjectDefaultsV1{Version: 1, Defaults: defaults})
+	if err != nil {
+		return fmt.Errorf("failed to marshal project defaults: %w", err)
+	}
+	params := db.ProjectDefaultsUpdateParams{ID: projectID, Defaults: data}
+	if err = db.Q.ProjectDefaultsUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update project defaults: %w", err)
+	}
+	return nil
+}
+
+// ApplyDefaults sets the fields of params that are missing to the project's defaults.
+func (p *ProjectService) ApplyDefaults(ctx context.Context, projectID string, params *types.SessionCreateParams) error {
+	defaults, err := p.Defaults(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if params.SetupScript == nil {
+		params.SetupScript = defaults.SetupScript
+	}
+	if params.TerminateOnSetupFailure == nil {
+		params.TerminateOnSetupFailure = defaults.TerminateOnSetupFailure
+	}
+	return nil
+}
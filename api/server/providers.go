@@ -2,10 +2,12 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/runtime"
 )
 
 type ProviderService struct {
@@ -33,3 +35,49 @@ func (p *ProviderService) ListNodeTypes(ctx context.Context, provider types.Runt
 
 	return nodeTypes, nil
 }
+
+// NodeSpecsV1 versions the node specs stored in the DB.
+type NodeSpecsV1 struct {
+	Version int             `json:"version"`
+	Specs   types.NodeSpecs `json:"specs"`
+}
+
+// parseNodeSpecs decodes the node specs of a session. Sessions launched before specs
+// were recorded have none.
+func parseNodeSpecs(data json.RawMessage) (*types.NodeSpecs, error) {
+	s := NodeSpecsV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal node specs: %w", err)
+		}
+	}
+	if s.Version == 0 {
+		return nil, nil
+	}
+	return &s.Specs, nil
+}
+
+// nodeTypeGPUs returns the number of GPUs of a node type. Node types that aren't in the
+// provider's catalog count as having none.
+func nodeTypeGPUs(nodeType *types.NodeType) int {
+	if nodeType == nil {
+		return 0
+	}
+	return nodeType.Specs.GPUs
+}
+
+// findNodeType returns the node type from the provider's catalog or nil if the provider
+// doesn't list it.
+func findNodeType(ctx context.Context, rt runtime.Session, nodeTypeID string) (*types.NodeType, error) {
+	nodeTypes, err := rt.ListNodeTypes(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node types: %w", err)
+	}
+	for _, nt := range nodeTypes {
+		if nt.ID == nodeTypeID {
+			nt := nt
+			return &nt, nil
+		}
+	}
+	return nil, nil
+}
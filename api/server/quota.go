@@ -0,0 +1,370 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+var (
+	// quotaLockTimeout is how long a session create waits for other creates of the same
+	// project or account to finish.
+	quotaLockTimeout = time.Minute
+	// quotaLockRetryInterval is how often a held quota lock is retried.
+	quotaLockRetryInterval = 100 * time.Millisecond
+)
+
+func projectQuotaLeaseKey(projectID string) string {
+	return "quota/project/" + projectID
+}
+
+func accountQuotaLeaseKey(accountID uuid.UUID) string {
+	return "quota/account/" + accountID.String()
+}
+
+// QuotaV1 versions the quotas stored in the DB.
+type QuotaV1 struct {
+	Version int         `json:"version"`
+	Quota   types.Quota `json:"quota"`
+}
+
+// parseQuota decodes the quota of a project or account. Projects and accounts that
+// don't have one are unlimited.
+func parseQuota(data json.RawMessage) (types.Quota, error) {
+	q := QuotaV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &q); err != nil {
+			return types.Quota{}, fmt.Errorf("failed to unmarshal quota: %w", err)
+		}
+	}
+	return q.Quota, nil
+}
+
+func marshalQuota(quota types.Quota) (json.RawMessage, error) {
+	data, err := json.Marshal(QuotaV1{Version: 1, Quota: quota})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal quota: %w", err)
+	}
+	return data, nil
+}
+
+// quotaUsage sums the sessions and GPUs of the records that are still open and the GPU
+// hours used since the start of the month.
+func quotaUsage(records []db.UnweaveSessionUsage, now time.Time) types.QuotaUsage {
+	usage := types.QuotaUsage{}
+	month := monthStart(now)
+	for _, u := range records {
+		if !u.EndedAt.Valid {
+			usage.Sessions++
+			usage.GPUs += int(u.Gpus)
+		}
+		for _, s := range usageSegments(u, month, now, now, false) {
+			usage.GPUHours += s.end.Sub(s.start).Hours() * float64(u.Gpus)
+		}
+	}
+	return usage
+}
+
+func projectQuotaUsage(ctx context.Context, projectID string, now time.Time) (types.QuotaUsage, error) {
+	records, err := db.Q.SessionUsageListByProject(ctx, db.SessionUsageListByProjectParams{
+		ProjectID: projectID,
+		Until:     now,
+		Since:     monthStart(now),
+	})
+	if err != nil {
+		return types.QuotaUsage{}, fmt.Errorf("failed to get usage from db: %w", err)
+	}
+	return quotaUsage(records, now), nil
+}
+
+func accountQuotaUsage(ctx context.Context, accountID uuid.UUID, now time.Time) (types.QuotaUsage, error) {
+	records, err := db.Q.SessionUsageListByAccount(ctx, db.SessionUsageListByAccountParams{
+		AccountID: accountID,
+		Until:     now,
+		Since:     monthStart(now),
+	})
+	if err != nil {
+		return types.QuotaUsage{}, fmt.Errorf("failed to get usage from db: %w", err)
+	}
+	return quotaUsage(records, now), nil
+}
+
+func accountQuota(ctx context.Context, accountID uuid.UUID) (types.Quota, error) {
+	data, err := db.Q.AccountQuotaGet(ctx, accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.Quota{}, nil
+		}
+		return types.Quota{}, fmt.Errorf("failed to get account quota from db: %w", err)
+	}
+	return parseQuota(data)
+}
+
+// checkQuota returns an error if launching a node of the node type with the given number
+// of GPUs would exceed the quota. scope names the owner of the quota in the error.
+func checkQuota(scope string, quota types.Quota, usage types.QuotaUsage, nodeTypeID string, gpus int) error {
+	if len(quota.AllowedNodeTypes) > 0 {
+		allowed := false
+		for _, nt := range quota.AllowedNodeTypes {
+			if nt == nodeTypeID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &types.Error{
+				Code:       http.StatusTooManyRequests,
+				Message:    fmt.Sprintf("Node type %q isn't allowed by the %s quota", nodeTypeID, scope),
+				Suggestion: fmt.Sprintf("Use one of the allowed node types: %s", strings.Join(quota.AllowedNodeTypes, ", ")),
+			}
+		}
+	}
+	if quota.MaxSessions != nil && usage.Sessions+1 > *quota.MaxSessions {
+		return &types.Error{
+			Code:       http.StatusTooManyRequests,
+			Message:    fmt.Sprintf("The %s quota allows %d sessions at once and %d are running", scope, *quota.MaxSessions, usage.Sessions),
+			Suggestion: "Terminate a running session or ask your platform team to raise the quota",
+		}
+	}
+	if quota.MaxGPUs != nil && usage.GPUs+gpus > *quota.MaxGPUs {
+		return &types.Error{
+			Code: http.StatusTooManyRequests,
+			Message: fmt.Sprintf("The %s quota allows %d GPUs at once, %d are in use and the node has %d",
+				scope, *quota.MaxGPUs, usage.GPUs, gpus),
+			Suggestion: "Terminate a running session, pick a node type with fewer GPUs or ask your platform team to raise the quota",
+		}
+	}
+	if quota.MonthlyGPUHours != nil && gpus > 0 && usage.GPUHours >= *quota.MonthlyGPUHours {
+		return &types.Error{
+			Code: http.StatusTooManyRequests,
+			Message: fmt.Sprintf("The %s quota of %.1f GPU hours this month is used up",
+				scope, *quota.MonthlyGPUHours),
+			Suggestion: "Use a node type without GPUs, wait until the quota resets next month or ask your platform team to raise it",
+		}
+	}
+	return nil
+}
+
+type QuotaService struct {
+	srv *Service
+}
+
+// Get returns the quota and usage of the caller's account and, if projectID is set, of
+// the project.
+func (q *QuotaService) Get(ctx context.Context, projectID *string) (*types.QuotaResponse, error) {
+	now := time.Now().UTC()
+	res := &types.QuotaResponse{Month: now.Format("2006-01")}
+
+	account, err := q.AccountStatus(ctx, q.srv.cid, now)
+	if err != nil {
+		return nil, err
+	}
+	res.Account = account
+
+	if projectID != nil {
+		project, err := q.ProjectStatus(ctx, *projectID, now)
+		if err != nil {
+			return nil, err
+		}
+		res.Project = project
+	}
+	return res, nil
+}
+
+func (q *QuotaService) AccountStatus(ctx context.Context, accountID uuid.UUID, now time.Time) (*types.QuotaStatus, error) {
+	quota, err := accountQuota(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := accountQuotaUsage(ctx, accountID, now)
+	if err != nil {
+		return nil, err
+	}
+	return &types.QuotaStatus{Quota: quota, Usage: usage}, nil
+}
+
+func (q *QuotaService) ProjectStatus(ctx context.Context, projectID string, now time.Time) (*types.QuotaStatus, error) {
+	project, err := q.srv.Project.get(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	quota, err := parseQuota(project.Quota)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := projectQuotaUsage(ctx, projectID, now)
+	if err != nil {
+		return nil, err
+	}
+	return &types.QuotaStatus{Quota: quota, Usage: usage}, nil
+}
+
+func (q *QuotaService) SetAccountQuota(ctx context.Context, accountID uuid.UUID, quota types.Quota) error {
+	if err := quota.Validate(); err != nil {
+		return err
+	}
+	if _, err := db.Q.AccountQuotaGet(ctx, accountID); err != nil {
+		if err == sql.ErrNoRows {
+			return &types.Error{
+				Code:    http.StatusNotFound,
+				Message: "Account not found",
+			}
+		}
+		return fmt.Errorf("failed to get account from db: %w", err)
+	}
+	data, err := marshalQuota(quota)
+	if err != nil {
+		return err
+	}
+	params := db.AccountQuotaUpdateParams{ID: accountID, Quota: data}
+	if err = db.Q.AccountQuotaUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update account quota: %w", err)
+	}
+	return nil
+}
+
+func (q *QuotaService) SetProjectQuota(ctx context.Context, projectID string, quota types.Quota) error {
+	if err := quota.Validate(); err != nil {
+		return err
+	}
+	if _, err := q.srv.Project.get(ctx, projectID); err != nil {
+		return err
+	}
+	data, err := marshalQuota(quota)
+	if err != nil {
+		return err
+	}
+	params := db.ProjectQuotaUpdateParams{ID: projectID, Quota: data}
+	if err = db.Q.ProjectQuotaUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update project quota: %w", err)
+	}
+	return nil
+}
+
+// Check returns an error if launching a node of the node type would exceed the quota of
+// the project or of the caller's account.
+func (q *QuotaService) Check(ctx context.Context, projectID, nodeTypeID string, gpus int) error {
+	projectQuota, accQuota, err := q.quotas(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return q.check(ctx, projectID, projectQuota, accQuota, nodeTypeID, gpus)
+}
+
+// Acquire checks the quotas like Check and holds them until release is called. Other
+// creates in the project or account, on any replica, wait until then, so release must
+// only be called once the session's usage is recorded.
+func (q *QuotaService) Acquire(ctx context.Context, projectID, nodeTypeID string, gpus int) (release func(), err error) {
+	projectQuota, accQuota, err := q.quotas(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if projectQuota.IsZero() && accQuota.IsZero() {
+		return func() {}, nil
+	}
+
+	release, err = lockQuotas(ctx, projectQuotaLeaseKey(projectID), accountQuotaLeaseKey(q.srv.cid))
+	if err != nil {
+		return nil, err
+	}
+	if err = q.check(ctx, projectID, projectQuota, accQuota, nodeTypeID, gpus); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func (q *QuotaService) quotas(ctx context.Context, projectID string) (project, account types.Quota, err error) {
+	p, err := q.srv.Project.get(ctx, projectID)
+	if err != nil {
+		return types.Quota{}, types.Quota{}, err
+	}
+	if project, err = parseQuota(p.Quota); err != nil {
+		return types.Quota{}, types.Quota{}, err
+	}
+	if account, err = accountQuota(ctx, q.srv.cid); err != nil {
+		return types.Quota{}, types.Quota{}, err
+	}
+	return project, account, nil
+}
+
+func (q *QuotaService) check(
+	ctx context.Context,
+	projectID string,
+	projectQuota, accQuota types.Quota,
+	nodeTypeID string,
+	gpus int,
+) error {
+	now := time.Now().UTC()
+	if !projectQuota.IsZero() {
+		usage, err := projectQuotaUsage(ctx, projectID, now)
+		if err != nil {
+			return err
+		}
+		if err = checkQuota("project", projectQuota, usage, nodeTypeID, gpus); err != nil {
+			return err
+		}
+	}
+	if !accQuota.IsZero() {
+		usage, err := accountQuotaUsage(ctx, q.srv.cid, now)
+		if err != nil {
+			return err
+		}
+		if err = checkQuota("account", accQuota, usage, nodeTypeID, gpus); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockQuotas takes the leases with the given keys in order, waiting for other holders to
+// release them. The returned func releases all of them.
+func lockQuotas(ctx context.Context, keys ...string) (func(), error) {
+	var held []string
+	release := func() {
+		// The request might be done by the time the locks are released.
+		c := log.Ctx(ctx).WithContext(context.Background())
+		for _, key := range held {
+			leases.release(c, key)
+		}
+	}
+
+	deadline := time.Now().Add(quotaLockTimeout)
+	for _, key := range keys {
+		for {
+			_, ok, err := leases.acquire(ctx, key)
+			if err != nil {
+				release()
+				return nil, err
+			}
+			if ok {
+				held = append(held, key)
+				break
+			}
+			if time.Now().After(deadline) {
+				release()
+				return nil, &types.Error{
+					Code:       http.StatusTooManyRequests,
+					Message:    "Too many sessions are being created at once",
+					Suggestion: "Try again in a moment",
+				}
+			}
+			select {
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			case <-time.After(quotaLockRetryInterval):
+			}
+		}
+	}
+	return release, nil
+}
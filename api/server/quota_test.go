@@ -0,0 +1,74 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+func TestQuotaUsage(t *testing.T) {
+	now := time.Date(2023, 4, 2, 12, 0, 0, 0, time.UTC)
+	records := []db.UnweaveSessionUsage{
+		{
+			// Ended, only the part in April counts.
+			SessionID: "ss_1",
+			Gpus:      8,
+			StartedAt: time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC),
+			EndedAt:   sql.NullTime{Time: time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC), Valid: true},
+		},
+		{
+			// Running.
+			SessionID: "ss_2",
+			Gpus:      1,
+			StartedAt: time.Date(2023, 4, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// Running without GPUs.
+			SessionID: "ss_3",
+			StartedAt: time.Date(2023, 4, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	usage := quotaUsage(records, now)
+	want := types.QuotaUsage{Sessions: 2, GPUs: 1, GPUHours: 8*12 + 12}
+	if usage != want {
+		t.Fatalf("expected %+v, got %+v", want, usage)
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	two, hours := 2, 10.0
+	usage := types.QuotaUsage{Sessions: 1, GPUs: 1, GPUHours: 10}
+
+	tests := []struct {
+		name    string
+		quota   types.Quota
+		gpus    int
+		allowed bool
+	}{
+		{"unlimited", types.Quota{}, 8, true},
+		{"node type allowed", types.Quota{AllowedNodeTypes: []string{"gpu_1x_a10"}}, 1, true},
+		{"node type not allowed", types.Quota{AllowedNodeTypes: []string{"gpu_1x_a100"}}, 1, false},
+		{"sessions", types.Quota{MaxSessions: &two}, 0, true},
+		{"gpus", types.Quota{MaxGPUs: &two}, 2, false},
+		{"gpu hours used up", types.Quota{MonthlyGPUHours: &hours}, 1, false},
+		{"gpu hours without gpus", types.Quota{MonthlyGPUHours: &hours}, 0, true},
+	}
+	for _, tt := range tests {
+		err := checkQuota("project", tt.quota, usage, "gpu_1x_a10", tt.gpus)
+		if tt.allowed {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		e, ok := err.(*types.Error)
+		if !ok || e.Code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected a 429 error, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/runtime"
+)
+
+var (
+	defaultReadinessTimeout  = 10 * time.Minute
+	defaultReadinessInterval = 5 * time.Second
+)
+
+// ReadinessV1 versions the readiness probes stored in the DB.
+type ReadinessV1 struct {
+	Version int                   `json:"version"`
+	Probes  types.ReadinessProbes `json:"probes"`
+}
+
+// parseReadiness decodes the readiness probes of a session. Sessions created before
+// probes were configurable only get the default SSH probes.
+func parseReadiness(data json.RawMessage) (types.ReadinessProbes, error) {
+	r := ReadinessV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &r); err != nil {
+			return types.ReadinessProbes{}, fmt.Errorf("failed to unmarshal readiness probes: %w", err)
+		}
+	}
+	return r.Probes, nil
+}
+
+// errProbeTimeout is returned when a node doesn't pass a readiness probe in time.
+func errProbeTimeout(probe string, timeout time.Duration, err error) error {
+	return &types.Error{
+		Code:       http.StatusGatewayTimeout,
+		Message:    fmt.Sprintf("Node didn't pass the %s readiness probe within %s", probe, timeout),
+		Suggestion: "Check the node with your provider or increase the readiness timeout",
+		Err:        err,
+	}
+}
+
+// retryProbe runs probe until it succeeds or ctx is done.
+func retryProbe(ctx context.Context, name string, interval time.Duration, probe func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := probe(ctx)
+		if err == nil {
+			log.Ctx(ctx).Info().Msgf("Readiness probe %s passed", name)
+			return nil
+		}
+		log.Ctx(ctx).Debug().Err(err).Msgf("Readiness probe %s failed (attempt %d)", name, attempt)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
+
+// provision runs the readiness probes of a session once the provider reports its node
+// as running. The session is provisioning while the probes run. Along the way, the
+// node's connection info and host key are stored. The session's setup script runs once
+// all probes pass.
+func (s *SessionService) provision(ctx context.Context, rt runtime.Session, sess db.UnweaveSession) error {
+	probes, err := parseReadiness(sess.Readiness)
+	if err != nil {
+		return err
+	}
+	timeout := defaultReadinessTimeout
+	if probes.TimeoutSeconds > 0 {
+		timeout = time.Duration(probes.TimeoutSeconds) * time.Second
+	}
+	interval := defaultReadinessInterval
+	if probes.IntervalSeconds > 0 {
+		interval = time.Duration(probes.IntervalSeconds) * time.Second
+	}
+
+	params := db.SessionStatusUpdateParams{
+		ID:     sess.ID,
+		Status: db.UnweaveSessionStatusProvisioning,
+	}
+	if err = setSessionStatus(ctx, params); err != nil {
+		return fmt.Errorf("failed to update session status: %w", err)
+	}
+
+	// The setup script isn't bound by the readiness timeout.
+	setupCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The provider might not have assigned an address yet so it is fetched again on
+	// every attempt.
+	var conn types.ConnectionInfo
+	err = retryProbe(ctx, "tcp", interval, func(ctx context.Context) error {
+		c, err := rt.GetConnectionInfo(ctx, sess.NodeID)
+		if err != nil {
+			return fmt.Errorf("failed to get connection info: %w", err)
+		}
+		if c.Host == "" {
+			return fmt.Errorf("node has no address yet")
+		}
+		d := net.Dialer{Timeout: 10 * time.Second}
+		nc, err := d.DialContext(ctx, "tcp", net.JoinHostPort(c.Host, strconv.Itoa(c.Port)))
+		if err != nil {
+			return err
+		}
+		nc.Close()
+		conn = c
+		return nil
+	})
+	if err != nil {
+		return errProbeTimeout("tcp", timeout, err)
+	}
+
+	if conn, err = updateConnectionInfo(ctx, sess.ID, conn); err != nil {
+		return err
+	}
+
+	err = retryProbe(ctx, "ssh", interval, func(ctx context.Context) error {
+		code, out, err := runNodeCommand(ctx, conn, "true")
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return fmt.Errorf("login exited with code %d: %s", code, out)
+		}
+		return nil
+	})
+	if err != nil {
+		return errProbeTimeout("ssh", timeout, err)
+	}
+
+	for _, cmd := range probes.Commands {
+		err = retryProbe(ctx, strconv.Quote(cmd), interval, func(ctx context.Context) error {
+			code, out, err := runNodeCommand(ctx, conn, cmd)
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				return fmt.Errorf("command exited with code %d: %s", code, out)
+			}
+			return nil
+		})
+		if err != nil {
+			return errProbeTimeout(strconv.Quote(cmd), timeout, err)
+		}
+	}
+	return s.setup(setupCtx, rt, sess, conn)
+}
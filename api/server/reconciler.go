@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/runtime"
+)
+
+type ReconcileMode string
+
+const (
+	// ReconcileOff disables the periodic reconciler. It can still be run manually
+	// through the admin API.
+	ReconcileOff ReconcileMode = "off"
+	// ReconcileDryRun only reports findings without acting on them.
+	ReconcileDryRun ReconcileMode = "dry-run"
+	// ReconcileEnforce terminates orphaned nodes and fixes the status of sessions.
+	ReconcileEnforce ReconcileMode = "enforce"
+)
+
+var (
+	// reconcileInterval is how often the reconciler runs by default.
+	reconcileInterval = 5 * time.Minute
+	// reconcileGracePeriod is how long a node has to be unknown before it is treated as
+	// orphaned. It is also how old a session has to be before its node is considered
+	// vanished. This leaves launches that are in flight alone.
+	reconcileGracePeriod = 10 * time.Minute
+)
+
+// nodeReconciler is initialized when the API starts.
+var nodeReconciler *Reconciler
+
+// Reconciler compares the nodes running on each provider with the sessions in the db.
+// It finds nodes that no session knows about, nodes that outlived their session and
+// sessions whose status doesn't match their node's.
+type Reconciler struct {
+	rti      runtime.Initializer
+	mode     ReconcileMode
+	interval time.Duration
+
+	// mu serializes runs.
+	mu sync.Mutex
+	// firstSeen is when each orphaned node was first found, keyed by provider and node ID.
+	firstSeen map[string]time.Time
+	last      *types.ReconcileReport
+}
+
+func NewReconciler(rti runtime.Initializer, mode ReconcileMode, interval time.Duration) *Reconciler {
+	if interval <= 0 {
+		interval = reconcileInterval
+	}
+	return &Reconciler{
+		rti:       rti,
+		mode:      mode,
+		interval:  interval,
+		firstSeen: map[string]time.Time{},
+	}
+}
+
+// Mode returns the mode the reconciler periodically runs in.
+func (r *Reconciler) Mode() ReconcileMode {
+	return r.mode
+}
+
+// Start runs the reconciler periodically until the context is cancelled. When running
+// several replicas of the API, only the one holding the reconciler lease runs it.
+func (r *Reconciler) Start(ctx context.Context) {
+	if r.mode == ReconcileOff {
+		log.Ctx(ctx).Info().Msg("Reconciler is disabled")
+		return
+	}
+
+	leases.every(ctx, reconcilerLeaseKey, r.interval, func(leaseCtx context.Context) {
+		report := r.Run(leaseCtx, r.mode != ReconcileEnforce)
+		log.Ctx(ctx).Info().
+			Int("findings", len(report.Findings)).
+			Int("errors", len(report.Errors)).
+			Bool("dryRun", report.DryRun).
+			Msg("Reconciled provider nodes")
+	})
+}
+
+// LastReport returns the report of the last run or nil if the reconciler hasn't run yet.
+func (r *Reconciler) LastReport() *types.ReconcileReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+type providerAccount struct {
+	accountID uuid.UUID
+	provider  types.RuntimeProvider
+}
+
+func nodeKey(provider types.RuntimeProvider, nodeID string) string {
+	return string(provider) + "/" + nodeID
+}
+
+func isActiveSessionStatus(status db.UnweaveSessionStatus) bool {
+	switch status {
+	case db.UnweaveSessionStatusInitializing,
+		db.UnweaveSessionStatusProvisioning,
+		db.UnweaveSessionStatusRunning,
+		db.UnweaveSessionStatusDegraded:
+		return true
+	}
+	return false
+}
+
+// Run reconciles the nodes of every provider account sessions have been launched on. In
+// dry run mode the findings are only reported.
+func (r *Reconciler) Run(ctx context.Context, dryRun bool) types.ReconcileReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := types.ReconcileReport{
+		StartedAt: time.Now(),
+		DryRun:    dryRun,
+		Findings:  []types.ReconcileFinding{},
+		Errors:    []string{},
+	}
+	fail := func(err error) {
+		log.Ctx(ctx).Error().Err(err).Msg("Reconciler error")
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	accounts, err := db.Q.SessionProviderAccountsGet(ctx)
+	if err != nil {
+		fail(fmt.Errorf("failed to get provider accounts from db: %w", err))
+		report.FinishedAt = time.Now()
+		r.last = &report
+		return report
+	}
+
+	// Self-hosted deployments might share a provider account between Unweave accounts
+	// so the same node can be listed more than once.
+	runtimes := map[string]*runtime.Runtime{}
+	listed := map[providerAccount]map[string]bool{}
+	var nodes []types.Node
+
+	for _, a := range accounts {
+		pa := providerAccount{accountID: a.CreatedBy, provider: types.RuntimeProvider(a.Provider)}
+		rt, err := r.rti.InitializeRuntime(ctx, pa.accountID, pa.provider)
+		if err != nil {
+			fail(fmt.Errorf("failed to create runtime %q for account %s: %w", pa.provider, pa.accountID, err))
+			continue
+		}
+		list, err := rt.ListNodes(ctx)
+		if err != nil {
+			fail(fmt.Errorf("failed to list %q nodes for account %s: %w", pa.provider, pa.accountID, err))
+			continue
+		}
+
+		listed[pa] = map[string]bool{}
+		for _, n := range list {
+			n.Provider = pa.provider
+			listed[pa][n.ID] = true
+
+			key := nodeKey(n.Provider, n.ID)
+			if _, ok := runtimes[key]; ok {
+				continue
+			}
+			runtimes[key] = rt
+			nodes = append(nodes, n)
+		}
+	}
+
+	firstSeen := map[string]time.Time{}
+	for _, n := range nodes {
+		// Only nodes launched with the platform key are ours to clean up.
+		if !strings.HasPrefix(n.KeyPair.Name, platformSSHKeyName) {
+			continue
+		}
+		finding, ok, err := r.reconcileNode(ctx, runtimes[nodeKey(n.Provider, n.ID)], n, dryRun, firstSeen)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		if ok {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	r.firstSeen = firstSeen
+
+	sessions, err := db.Q.SessionGetAllActive(ctx)
+	if err != nil {
+		fail(fmt.Errorf("failed to get active sessions from db: %w", err))
+	}
+	for _, s := range sessions {
+		pa := providerAccount{accountID: s.CreatedBy, provider: types.RuntimeProvider(s.Provider)}
+		ids, ok := listed[pa]
+		if !ok || ids[s.NodeID] {
+			// Either the provider couldn't be listed or the node is still there.
+			continue
+		}
+		if time.Since(s.CreatedAt) < reconcileGracePeriod {
+			continue
+		}
+		report.Findings = append(report.Findings, r.reconcileVanished(ctx, s, dryRun))
+	}
+
+	report.FinishedAt = time.Now()
+	r.last = &report
+	return report
+}
+
+func (r *Reconciler) reconcileNode(
+	ctx context.Context,
+	rt *runtime.Runtime,
+	node types.Node,
+	dryRun bool,
+	firstSeen map[string]time.Time,
+) (types.ReconcileFinding, bool, error) {
+	nodeStatus := node.Status
+	finding := types.ReconcileFinding{
+		Provider:       node.Provider,
+		NodeID:         node.ID,
+		ProviderStatus: &nodeStatus,
+		Action:         "reported",
+	}
+	nodeActive := node.Status == types.StatusInitializing || node.Status == types.StatusRunning
+
+	sess, err := db.Q.SessionGetByNodeID(ctx, db.SessionGetByNodeIDParams{
+		NodeID:   node.ID,
+		Provider: string(node.Provider),
+	})
+	if err != nil && err != sql.ErrNoRows {
+		return finding, false, fmt.Errorf("failed to get session for node %q from db: %w", node.ID, err)
+	}
+
+	if err == sql.ErrNoRows {
+		if !nodeActive {
+			return finding, false, nil
+		}
+		finding.Kind = types.ReconcileOrphan
+
+		key := nodeKey(node.Provider, node.ID)
+		seen, ok := r.firstSeen[key]
+		if !ok {
+			seen = time.Now()
+		}
+		firstSeen[key] = seen
+
+		if time.Since(seen) < reconcileGracePeriod {
+			finding.Action = "awaiting grace period"
+			return finding, true, nil
+		}
+		if dryRun {
+			return finding, true, nil
+		}
+		r.terminateNode(ctx, rt, &finding)
+		if finding.Error == nil {
+			delete(firstSeen, key)
+		}
+		return finding, true, nil
+	}
+
+	sessionStatus := types.SessionStatus(sess.Status)
+	finding.SessionID = &sess.ID
+	finding.SessionStatus = &sessionStatus
+
+	switch {
+	case sess.Status == db.UnweaveSessionStatusTerminated && nodeActive:
+		// Sessions in the error state are left running on purpose so that they can be
+		// debugged, only terminated ones are cleaned up.
+		finding.Kind = types.ReconcileZombie
+		if !dryRun {
+			r.terminateNode(ctx, rt, &finding)
+		}
+		return finding, true, nil
+	case isActiveSessionStatus(sess.Status) &&
+		(node.Status == types.StatusTerminated || node.Status == types.StatusError):
+		finding.Kind = types.ReconcileDrift
+		if !dryRun {
+			r.updateSessionStatus(ctx, sess.ID, node.Status, &finding)
+		}
+		return finding, true, nil
+	}
+	return finding, false, nil
+}
+
+func (r *Reconciler) reconcileVanished(ctx context.Context, sess db.UnweaveSession, dryRun bool) types.ReconcileFinding {
+	sessionStatus := types.SessionStatus(sess.Status)
+	finding := types.ReconcileFinding{
+		Kind:          types.ReconcileVanished,
+		Provider:      types.RuntimeProvider(sess.Provider),
+		NodeID:        sess.NodeID,
+		SessionID:     &sess.ID,
+		SessionStatus: &sessionStatus,
+		Action:        "reported",
+	}
+	if !dryRun {
+		r.updateSessionStatus(ctx, sess.ID, types.StatusTerminated, &finding)
+	}
+	return finding
+}
+
+func (r *Reconciler) terminateNode(ctx context.Context, rt *runtime.Runtime, finding *types.ReconcileFinding) {
+	if err := rt.TerminateNode(ctx, finding.NodeID); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to terminate %s node %q", finding.Kind, finding.NodeID)
+		msg := err.Error()
+		finding.Error = &msg
+		return
+	}
+	log.Ctx(ctx).Info().Msgf("Terminated %s node %q", finding.Kind, finding.NodeID)
+	finding.Action = "terminated node"
+}
+
+func (r *Reconciler) updateSessionStatus(
+	ctx context.Context,
+	sessionID string,
+	status types.SessionStatus,
+	finding *types.ReconcileFinding,
+) {
+	params := db.SessionStatusUpdateParams{
+		ID:     sessionID,
+		Status: db.UnweaveSessionStatus(status),
+	}
+	if err := setSessionStatus(ctx, params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to set session %q as %s", sessionID, status)
+		msg := err.Error()
+		finding.Error = &msg
+		return
+	}
+	if status == types.StatusTerminated || status == types.StatusError {
+		nodeTunnels.close(sessionID)
+	}
+	log.Ctx(ctx).Info().Msgf("Set %s session %q as %s", finding.Kind, sessionID, status)
+	finding.Action = "marked " + string(status)
+}
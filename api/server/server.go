@@ -2,20 +2,127 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/agent/protocol"
 	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/events/postgres"
 	"github.com/unweave/unweave/runtime"
 )
 
+// shutdownTimeout is how long the API waits for requests and session watchers to finish
+// when shutting down.
+const shutdownTimeout = 30 * time.Second
+
 type Config struct {
 	APIPort string    `json:"port" env:"UNWEAVE_API_PORT"`
 	DB      db.Config `json:"db"`
+	// PublicURL is the externally reachable URL of the API, e.g. https://api.unweave.io.
+	// It is used to build the URLs of ports exposed on sessions and by node agents to
+	// reach the API.
+	PublicURL string `json:"publicURL" env:"UNWEAVE_PUBLIC_URL"`
+	// NodeSSHKeyPath is the path to the private key the API uses to run commands on the
+	// nodes it launches.
+	NodeSSHKeyPath string `json:"nodeSSHKeyPath" env:"UNWEAVE_NODE_SSH_KEY_PATH"`
+	// GatewayAddr is the address the SSH gateway listens on. The gateway is disabled if
+	// it is empty.
+	GatewayAddr string `json:"gatewayAddr" env:"UNWEAVE_GATEWAY_ADDR"`
+	// GatewayHostKeyPath is the path to the SSH gateway's host key. An ephemeral key is
+	// generated if it is empty.
+	GatewayHostKeyPath string `json:"gatewayHostKeyPath" env:"UNWEAVE_GATEWAY_HOST_KEY_PATH"`
+	// AgentBinaryPath is the path to the linux/amd64 build of the node agent. The agent
+	// is only installed on nodes if both this and PublicURL are set.
+	AgentBinaryPath string `json:"agentBinaryPath" env:"UNWEAVE_AGENT_BINARY_PATH"`
+	// AdminToken is the bearer token of the admin API. The admin API is disabled if it is
+	// empty.
+	AdminToken string `json:"adminToken" env:"UNWEAVE_ADMIN_TOKEN"`
+	// ReconcileMode is one of off, dry-run or enforce. Defaults to dry-run.
+	ReconcileMode ReconcileMode `json:"reconcileMode" env:"UNWEAVE_RECONCILE_MODE"`
+	// ReconcileIntervalSeconds is how often the reconciler runs. Defaults to 5 minutes.
+	ReconcileIntervalSeconds int `json:"reconcileIntervalSeconds" env:"UNWEAVE_RECONCILE_INTERVAL_SECONDS"`
+	// ReplicaID identifies this API process when running several replicas. It must be
+	// unique across replicas. A random ID is generated if it is empty.
+	ReplicaID string `json:"replicaID" env:"UNWEAVE_REPLICA_ID"`
+	// Artifacts configures where the artifacts collected from sessions are stored.
+	Artifacts ArtifactStoreConfig `json:"artifacts"`
+	// CreditUnenforced lets accounts create sessions even if their credit doesn't cover
+	// the minimum runtime of the node. Usage is debited from credit either way.
+	CreditUnenforced bool `json:"creditUnenforced" env:"UNWEAVE_CREDIT_UNENFORCED"`
+	// CreditMinRuntimeMinutes is the minimum runtime credit must cover. Defaults to 60.
+	CreditMinRuntimeMinutes int `json:"creditMinRuntimeMinutes" env:"UNWEAVE_CREDIT_MIN_RUNTIME_MINUTES"`
+}
+
+// watchSession starts watching a session in the background. Sessions watched by another
+// replica are skipped.
+func watchSession(rti runtime.Initializer, sess db.UnweaveSession) {
+	go func() {
+		c := context.Background()
+		c = log.With().
+			Stringer(AccountIDCtxKey, sess.CreatedBy).
+			Str(ProjectIDCtxKey, sess.ProjectID).
+			Str(SessionIDCtxKey, sess.ID).
+			Logger().WithContext(c)
+
+		srv := NewCtxService(rti, sess.CreatedBy)
+		if e := srv.Session.Watch(c, sess.ID); e != nil {
+			log.Ctx(c).Error().Err(e).Msgf("Failed to watch session")
+		}
+	}()
+}
+
+// resumeCluster resumes monitoring a cluster. Clusters monitored by another replica are
+// skipped.
+func resumeCluster(ctx context.Context, rti runtime.Initializer, cl db.UnweaveCluster) {
+	c := log.With().
+		Stringer(AccountIDCtxKey, cl.CreatedBy).
+		Str(ProjectIDCtxKey, cl.ProjectID).
+		Str(ClusterIDCtxKey, cl.ID).
+		Logger().WithContext(context.Background())
+
+	srv := NewCtxService(rti, cl.CreatedBy)
+	if e := srv.Cluster.Execute(c, cl.ID); e != nil {
+		log.Ctx(ctx).Error().Err(e).Msgf("Failed to resume cluster %s", cl.ID)
+	}
+}
+
+// resumePipeline resumes executing a pipeline. Pipelines executed by another replica are
+// skipped.
+func resumePipeline(ctx context.Context, rti runtime.Initializer, pl db.UnweavePipeline) {
+	c := log.With().
+		Stringer(AccountIDCtxKey, pl.CreatedBy).
+		Str(ProjectIDCtxKey, pl.ProjectID).
+		Str(PipelineIDCtxKey, pl.ID).
+		Logger().WithContext(context.Background())
+
+	srv := NewCtxService(rti, pl.CreatedBy)
+	if e := srv.Pipeline.Execute(c, pl.ID); e != nil {
+		log.Ctx(ctx).Error().Err(e).Msgf("Failed to resume pipeline %s", pl.ID)
+	}
+}
+
+// resumeSweep resumes executing a sweep. Sweeps executed by another replica are skipped.
+func resumeSweep(ctx context.Context, rti runtime.Initializer, sw db.UnweaveSweep) {
+	c := log.With().
+		Stringer(AccountIDCtxKey, sw.CreatedBy).
+		Str(ProjectIDCtxKey, sw.ProjectID).
+		Str(SweepIDCtxKey, sw.ID).
+		Logger().WithContext(context.Background())
+
+	srv := NewCtxService(rti, sw.CreatedBy)
+	if e := srv.Sweep.Execute(c, sw.ID); e != nil {
+		log.Ctx(ctx).Error().Err(e).Msgf("Failed to resume sweep %s", sw.ID)
+	}
 }
 
 func HandleRestart(ctx context.Context, rti runtime.Initializer) error {
@@ -28,20 +135,43 @@ func HandleRestart(ctx context.Context, rti runtime.Initializer) error {
 	log.Ctx(ctx).Info().Msgf("🔄 Restarting watching %d sessions", len(sessions))
 
 	for _, s := range sessions {
-		sess := s
-		go func() {
-			c := context.Background()
-			c = log.With().
-				Stringer(AccountIDCtxKey, sess.CreatedBy).
-				Str(ProjectIDCtxKey, sess.ProjectID).
-				Str(SessionIDCtxKey, sess.ID).
-				Logger().WithContext(c)
-
-			srv := NewCtxService(rti, sess.CreatedBy)
-			if e := srv.Session.Watch(c, sess.ID); e != nil {
-				log.Ctx(ctx).Error().Err(e).Msgf("Failed to watch session")
-			}
-		}()
+		watchSession(rti, s)
+	}
+
+	// Resume monitoring all clusters
+	clusters, err := db.Q.ClusterGetAllActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Msgf("🔄 Resuming monitoring %d clusters", len(clusters))
+
+	for _, cl := range clusters {
+		resumeCluster(ctx, rti, cl)
+	}
+
+	// Resume executing all pipelines
+	pipelines, err := db.Q.PipelineGetAllActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Msgf("🔄 Resuming %d pipelines", len(pipelines))
+
+	for _, pl := range pipelines {
+		resumePipeline(ctx, rti, pl)
+	}
+
+	// Resume executing all sweeps
+	sweeps, err := db.Q.SweepGetAllActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Msgf("🔄 Resuming %d sweeps", len(sweeps))
+
+	for _, sw := range sweeps {
+		resumeSweep(ctx, rti, sw)
 	}
 	return nil
 }
@@ -77,6 +207,15 @@ func API(cfg Config, rti runtime.Initializer) {
 	r.Route("/projects/{projectID}", func(r chi.Router) {
 		r.Use(withProjectCtx)
 
+		r.Get("/watch-policy", ProjectsWatchPolicyGet(rti))
+		r.Put("/watch-policy", ProjectsWatchPolicyUpdate(rti))
+		r.Get("/defaults", ProjectsDefaultsGet(rti))
+		r.Put("/defaults", ProjectsDefaultsUpdate(rti))
+		r.Get("/budget", ProjectsBudgetGet(rti))
+		r.Put("/budget", ProjectsBudgetUpdate(rti))
+		r.Get("/quota", ProjectsQuotaGet(rti))
+		r.Get("/events", ProjectsEventsStream(rti))
+
 		r.Route("/sessions", func(r chi.Router) {
 			r.Post("/", SessionsCreate(rti))
 			r.Get("/", SessionsList(rti))
@@ -85,13 +224,66 @@ func API(cfg Config, rti runtime.Initializer) {
 				r.Use(withSessionCtx)
 				r.Get("/{sessionID}", SessionsGet(rti))
 				r.Put("/{sessionID}/terminate", SessionsTerminate(rti))
+				r.Get("/{sessionID}/known_hosts", SessionsKnownHosts(rti))
+				r.Get("/{sessionID}/ssh-config", SessionsSSHConfig(rti))
+				r.Get("/{sessionID}/metrics", SessionsMetrics(rti))
+				r.Get("/{sessionID}/watch-decisions", SessionsWatchDecisions(rti))
+				r.Get("/{sessionID}/setup/logs", SessionsSetupLogs(rti))
+				r.Get("/{sessionID}/artifacts", SessionsArtifactsList(rti))
+				r.Get("/{sessionID}/artifacts/{artifactID}", SessionsArtifactDownload(rti))
+				r.Post("/{sessionID}/files", SessionsFilesUpload(rti))
+				r.Get("/{sessionID}/files/manifest", SessionsFilesManifest(rti))
+				r.Post("/{sessionID}/agent/commands", SessionsAgentCommand(rti))
+				r.Post("/{sessionID}/ports", SessionsExposePort(rti))
+				r.Delete("/{sessionID}/ports/{port}", SessionsUnexposePort(rti))
+				r.Get("/{sessionID}/ports/{port}", SessionsProxyPort(rti))
+				r.HandleFunc("/{sessionID}/ports/{port}/*", SessionsProxyPort(rti))
 			})
 		})
 
+		r.Route("/clusters", func(r chi.Router) {
+			r.Post("/", ClustersCreate(rti))
+			r.Get("/", ClustersList(rti))
+			r.Get("/{clusterID}", ClustersGet(rti))
+			r.Get("/{clusterID}/hostfile", ClustersHostfile(rti))
+			r.Put("/{clusterID}/terminate", ClustersTerminate(rti))
+		})
+
+		r.Route("/session-templates", func(r chi.Router) {
+			r.Post("/", SessionTemplatesCreate(rti))
+			r.Get("/", SessionTemplatesList(rti))
+			r.Get("/{templateID}", SessionTemplatesGet(rti))
+			r.Put("/{templateID}", SessionTemplatesUpdate(rti))
+			r.Get("/{templateID}/versions", SessionTemplateVersionsList(rti))
+		})
+
+		r.Route("/pipelines", func(r chi.Router) {
+			r.Post("/", PipelinesCreate(rti))
+			r.Get("/", PipelinesList(rti))
+			r.Get("/{pipelineID}", PipelinesGet(rti))
+			r.Put("/{pipelineID}/cancel", PipelinesCancel(rti))
+		})
+
+		r.Route("/sweeps", func(r chi.Router) {
+			r.Post("/", SweepsCreate(rti))
+			r.Get("/", SweepsList(rti))
+			r.Get("/{sweepID}", SweepsGet(rti))
+			r.Put("/{sweepID}/cancel", SweepsCancel(rti))
+		})
+
 		r.Route("/builds", func(r chi.Router) {
 			r.Post("/", BuildsCreate(rti))
 			r.Get("/{buildID}/", BuildsGet(rti))
 		})
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/", WebhooksCreate(rti))
+			r.Get("/", WebhooksList(rti))
+			r.Get("/{webhookID}", WebhooksGet(rti))
+			r.Delete("/{webhookID}", WebhooksDelete(rti))
+			r.Get("/{webhookID}/deliveries", WebhookDeliveriesList(rti))
+			r.Post("/{webhookID}/deliveries/{deliveryID}/redeliver", WebhookDeliveryRedeliver(rti))
+		})
 	})
 
 	r.Route("/ssh-keys", func(r chi.Router) {
@@ -100,15 +292,125 @@ func API(cfg Config, rti runtime.Initializer) {
 		r.Post("/generate", SSHKeyGenerate(rti))
 	})
 	r.Get("/providers/{provider}/node-types", NodeTypesList(rti))
+	r.Get("/usage", UsageGet(rti))
+	r.Get("/account/credit", CreditBalanceGet(rti))
+	r.Get("/account/credit/transactions", CreditTransactionsList(rti))
+	r.Get("/account/quota", QuotasGet(rti))
+
+	r.Group(func(r chi.Router) {
+		r.Use(withAgentCtx)
+		r.Post(protocol.PathHeartbeat, AgentHeartbeat(rti))
+		r.Get(protocol.PathCommands, AgentPoll(rti))
+		r.Post(protocol.PathCommandResult, AgentCommandResult(rti))
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(withAdminCtx)
+		r.Get("/reconciler", AdminReconcilerGet(rti))
+		r.Post("/reconciler/run", AdminReconcilerRun(rti))
+		r.Get("/supervisor", AdminSupervisorGet(rti))
+		r.Get("/accounts/{accountID}/credit", AdminCreditGet(rti))
+		r.Post("/accounts/{accountID}/credit", AdminCreditAdd(rti))
+		r.Get("/accounts/{accountID}/quota", AdminAccountQuotaGet(rti))
+		r.Put("/accounts/{accountID}/quota", AdminAccountQuotaUpdate(rti))
+		r.Get("/projects/{projectID}/quota", AdminProjectQuotaGet(rti))
+		r.Put("/projects/{projectID}/quota", AdminProjectQuotaUpdate(rti))
+	})
+
+	// Nodes reached over the gateway or running the agent outlive API restarts so they
+	// can't be launched with an ephemeral platform key.
+	if cfg.NodeSSHKeyPath == "" && (cfg.GatewayAddr != "" || cfg.AgentBinaryPath != "") {
+		panic("UNWEAVE_NODE_SSH_KEY_PATH must be set when the SSH gateway or node agent is enabled")
+	}
+	signer, err := loadSigner(cfg.NodeSSHKeyPath, "platform")
+	if err != nil {
+		panic(err)
+	}
+	platformSigner = signer
+	apiPublicURL = cfg.PublicURL
+	agentBinaryPath = cfg.AgentBinaryPath
+	adminToken = cfg.AdminToken
+	creditEnforced = !cfg.CreditUnenforced
+	if cfg.CreditMinRuntimeMinutes > 0 {
+		creditMinRuntime = time.Duration(cfg.CreditMinRuntimeMinutes) * time.Minute
+	}
+
+	mode := cfg.ReconcileMode
+	if mode == "" {
+		mode = ReconcileDryRun
+	}
+	if mode != ReconcileOff && mode != ReconcileDryRun && mode != ReconcileEnforce {
+		panic(fmt.Sprintf("invalid reconcile mode %q", mode))
+	}
+	interval := time.Duration(cfg.ReconcileIntervalSeconds) * time.Second
+	nodeReconciler = NewReconciler(rti, mode, interval)
+
+	store, err := newArtifactStore(cfg.Artifacts)
+	if err != nil {
+		panic(err)
+	}
+	artifactStore = store
+
+	replicaID := cfg.ReplicaID
+	if replicaID == "" {
+		replicaID = uuid.NewString()
+	}
+	leases = newLeaseManager(replicaID)
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx := log.With().Str("replica", replicaID).Logger().WithContext(sigCtx)
+
+	bus := postgres.NewBus(db.Q, cfg.DB.URL())
+	eventBus = bus
+	go bus.Listen(ctx)
 
-	ctx := context.Background()
-	ctx = log.With().Logger().WithContext(ctx)
 	if err := HandleRestart(ctx, rti); err != nil {
 		panic(err)
 	}
+	go leases.run(ctx, rti)
+
+	go nodeReconciler.Start(ctx)
+	go webhooks.run(ctx)
+	go NewBudgetMonitor(rti, budgetCheckInterval).Start(ctx)
+	go NewCreditDebiter(creditDebitInterval).Start(ctx)
+
+	if cfg.GatewayAddr != "" {
+		hostKey, err := loadSigner(cfg.GatewayHostKeyPath, "gateway host")
+		if err != nil {
+			panic(err)
+		}
+		go func() {
+			if err := NewGateway(hostKey).ListenAndServe(ctx, cfg.GatewayAddr); err != nil {
+				panic(err)
+			}
+		}()
+	}
+
+	srv := &http.Server{Addr: ":" + cfg.APIPort, Handler: r}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+
+		<-sigCtx.Done()
+		log.Info().Msg("Shutting down API")
+
+		c, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(c); err != nil {
+			log.Error().Err(err).Msg("Failed to shut down HTTP server")
+		}
+		// Watchers release their leases when they return so that other replicas can
+		// take the sessions over straight away.
+		if err := supervisor.drain(c); err != nil {
+			log.Error().Err(err).Msg("Failed to drain session watchers")
+		}
+	}()
 
 	log.Info().Msgf("🚀 API listening on %s", cfg.APIPort)
-	if err := http.ListenAndServe(":"+cfg.APIPort, r); err != nil {
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		panic(err)
 	}
+	<-shutdown
 }
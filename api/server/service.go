@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/unweave/unweave/api/types"
@@ -10,19 +11,32 @@ import (
 )
 
 type Service struct {
+	mu      sync.Mutex // guards runtime and builder
 	rti     runtime.Initializer
 	cid     uuid.UUID // caller ID
 	runtime runtime.Session
 	builder builder.Builder
 
-	Builder  *BuilderService
-	Provider *ProviderService
-	Session  *SessionService
-	SSHKey   *SSHKeyService
+	Builder         *BuilderService
+	Cluster         *ClusterService
+	Credit          *CreditService
+	Pipeline        *PipelineService
+	Project         *ProjectService
+	Provider        *ProviderService
+	Quota           *QuotaService
+	Session         *SessionService
+	SessionTemplate *SessionTemplateService
+	SSHKey          *SSHKeyService
+	Sweep           *SweepService
+	Usage           *UsageService
+	Webhook         *WebhookService
 }
 
 // InitializeRuntime initializes the runtime a caches it in memory.
 func (s *Service) InitializeRuntime(ctx context.Context, provider types.RuntimeProvider) (runtime.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.runtime != nil {
 		return s.runtime, nil
 	}
@@ -35,6 +49,9 @@ func (s *Service) InitializeRuntime(ctx context.Context, provider types.RuntimeP
 }
 
 func (s *Service) InitializeBuilder(ctx context.Context, builder string) (builder.Builder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.builder != nil {
 		return s.builder, nil
 	}
@@ -55,9 +72,18 @@ func NewCtxService(rti runtime.Initializer, callerID uuid.UUID) *Service {
 		SSHKey:   nil,
 	}
 	srv.Builder = &BuilderService{srv: srv}
+	srv.Cluster = &ClusterService{srv: srv}
+	srv.Pipeline = &PipelineService{srv: srv}
+	srv.Project = &ProjectService{srv: srv}
 	srv.Provider = &ProviderService{srv: srv}
 	srv.Session = &SessionService{srv: srv}
+	srv.SessionTemplate = &SessionTemplateService{srv: srv}
 	srv.SSHKey = &SSHKeyService{srv: srv}
+	srv.Sweep = &SweepService{srv: srv}
+	srv.Credit = &CreditService{srv: srv}
+	srv.Quota = &QuotaService{srv: srv}
+	srv.Usage = &UsageService{srv: srv}
+	srv.Webhook = &WebhookService{srv: srv}
 
 	return srv
 }
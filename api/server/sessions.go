@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,13 +21,6 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
-type ConnectionInfoV1 struct {
-	Version int    `json:"version"`
-	Host    string `json:"host"`
-	Port    int    `json:"port"`
-	User    string `json:"user"`
-}
-
 func handleSessionError(ctx context.Context, sessionID string, err error, msg string) {
 	log.Ctx(ctx).Error().Err(err).Msg(msg)
 
@@ -38,6 +33,10 @@ func handleSessionError(ctx context.Context, sessionID string, err error, msg st
 	}
 	if err = db.Q.SessionSetError(ctx, params); err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Failed to set session error")
+		return
+	}
+	if sess, err := db.Q.SessionGet(ctx, sessionID); err == nil {
+		publishSessionStatus(ctx, sess.ProjectID, sessionID, db.UnweaveSessionStatusError, &msg)
 	}
 }
 
@@ -58,7 +57,9 @@ func registerCredentials(ctx context.Context, rt runtime.Session, key types.SSHK
 	return nil
 }
 
-func fetchCredentials(ctx context.Context, accountID uuid.UUID, sshKeyName, sshPublicKey *string) (types.SSHKey, error) {
+// fetchCredentials resolves the SSH key of a request. Public keys that aren't in the
+// account yet are saved unless dryRun is set.
+func fetchCredentials(ctx context.Context, accountID uuid.UUID, sshKeyName, sshPublicKey *string, dryRun bool) (types.SSHKey, error) {
 	if sshKeyName == nil && sshPublicKey == nil {
 		return types.SSHKey{}, &types.Error{
 			Code:    http.StatusBadRequest,
@@ -125,6 +126,10 @@ func fetchCredentials(ctx context.Context, accountID uuid.UUID, sshKeyName, sshP
 		sshKeyName = tools.Stringy("uw:" + random.GenerateRandomPhrase(4, "-"))
 	}
 
+	if dryRun {
+		return types.SSHKey{Name: *sshKeyName, PublicKey: sshPublicKey}, nil
+	}
+
 	// Key doesn't exist in db, but the user provided a public key, so add it to the db
 	if err := saveSSHKey(ctx, accountID, *sshKeyName, *sshPublicKey); err != nil {
 		return types.SSHKey{}, &types.Error{
@@ -138,31 +143,73 @@ func fetchCredentials(ctx context.Context, accountID uuid.UUID, sshKeyName, sshP
 	}, nil
 }
 
-func updateConnectionInfo(ctx context.Context, rt runtime.Session, nodeID string, sessionID string) error {
-	connInfo, err := rt.GetConnectionInfo(ctx, nodeID)
+// updateConnectionInfo captures the node's SSH host key and stores it along with the
+// connection info of the node.
+func updateConnectionInfo(ctx context.Context, sessionID string, conn types.ConnectionInfo) (types.ConnectionInfo, error) {
+	hostKey, err := fetchHostKey(ctx, conn)
 	if err != nil {
-		return fmt.Errorf("failed to get connection info: %w", err)
+		return types.ConnectionInfo{}, err
+	}
+	connInfo := ConnectionInfoV2{
+		Version: 2,
+		Host:    conn.Host,
+		Port:    conn.Port,
+		User:    conn.User,
+		HostKey: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(hostKey))),
 	}
 
 	connInfoJSON, err := json.Marshal(connInfo)
 	if err != nil {
-		return fmt.Errorf("failed to marshal connection info: %w", err)
+		return types.ConnectionInfo{}, fmt.Errorf("failed to marshal connection info: %w", err)
 	}
 	params := db.SessionUpdateConnectionInfoParams{
 		ID:             sessionID,
 		ConnectionInfo: connInfoJSON,
 	}
 	if e := db.Q.SessionUpdateConnectionInfo(ctx, params); e != nil {
-		return fmt.Errorf("failed to update connection info: %w", e)
+		return types.ConnectionInfo{}, fmt.Errorf("failed to update connection info: %w", e)
 	}
-	return nil
+	return connInfo.toAPI(), nil
+}
+
+func sessionTemplateRef(id sql.NullString, version sql.NullInt32) *types.SessionTemplateRef {
+	if !id.Valid || !version.Valid {
+		return nil
+	}
+	return &types.SessionTemplateRef{ID: id.String, Version: int(version.Int32)}
+}
+
+func sessionPrice(price sql.NullInt32) *int {
+	if !price.Valid {
+		return nil
+	}
+	p := int(price.Int32)
+	return &p
 }
 
 type SessionService struct {
 	srv *Service
 }
 
+// applyParams fills in the fields of params set by the session's template and the
+// project's defaults.
+func (s *SessionService) applyParams(ctx context.Context, projectID string, params *types.SessionCreateParams) error {
+	if params.TemplateID != nil {
+		if err := s.srv.SessionTemplate.Apply(ctx, projectID, params); err != nil {
+			return err
+		}
+		if err := params.Validate(); err != nil {
+			return err
+		}
+	}
+	return s.srv.Project.ApplyDefaults(ctx, projectID, params)
+}
+
 func (s *SessionService) Create(ctx context.Context, projectID string, params types.SessionCreateParams) (*types.Session, error) {
+	if err := s.applyParams(ctx, projectID, &params); err != nil {
+		return nil, err
+	}
+
 	rt, err := s.srv.InitializeRuntime(ctx, params.Provider)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create runtime: %w", err)
@@ -173,23 +220,218 @@ func (s *SessionService) Create(ctx context.Context, projectID string, params ty
 		Logger().
 		WithContext(ctx)
 
-	sshKey, err := fetchCredentials(ctx, s.srv.cid, params.SSHKeyName, params.SSHPublicKey)
+	sshKey, err := fetchCredentials(ctx, s.srv.cid, params.SSHKeyName, params.SSHPublicKey, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup credentials: %w", err)
 	}
-	if err = registerCredentials(ctx, rt, sshKey); err != nil {
+	return s.launch(ctx, rt, projectID, params, sshKey)
+}
+
+// DryRun checks that a session could be created without launching anything. It checks
+// the provider's credentials, the SSH key, the capacity of the node type, the project's
+// budget and the account's credit. The cost is estimated for duration if it's set.
+func (s *SessionService) DryRun(
+	ctx context.Context,
+	projectID string,
+	params types.SessionCreateParams,
+	duration time.Duration,
+) (*types.SessionCreateDryRunResponse, error) {
+	if err := s.applyParams(ctx, projectID, &params); err != nil {
+		return nil, err
+	}
+
+	rt, err := s.srv.InitializeRuntime(ctx, params.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create runtime: %w", err)
+	}
+
+	ctx = log.With().
+		Stringer(types.RuntimeProviderKey, rt.GetProvider()).
+		Logger().
+		WithContext(ctx)
+
+	if err = rt.HealthCheck(ctx); err != nil {
+		return nil, &types.Error{
+			Code:       http.StatusBadGateway,
+			Message:    fmt.Sprintf("Failed to connect to %s", rt.GetProvider()),
+			Suggestion: "Check that the provider's credentials are valid",
+			Err:        err,
+		}
+	}
+	sshKey, err := fetchCredentials(ctx, s.srv.cid, params.SSHKeyName, params.SSHPublicKey, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup credentials: %w", err)
+	}
+
+	available, err := rt.ListNodeTypes(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node types: %w", err)
+	}
+	var nodeType *types.NodeType
+	for _, nt := range available {
+		if nt.ID == params.NodeTypeID {
+			nt := nt
+			nodeType = &nt
+			break
+		}
+	}
+	if nodeType == nil {
+		return nil, &types.Error{
+			Code:       http.StatusServiceUnavailable,
+			Message:    fmt.Sprintf("No capacity for node type %q on %s", params.NodeTypeID, rt.GetProvider()),
+			Suggestion: "Pick another node type or try again later",
+		}
+	}
+	region, err := dryRunRegion(nodeType, params.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkBudget(ctx, projectID); err != nil {
+		return nil, err
+	}
+	if err = checkCredit(ctx, s.srv.cid, nodeType); err != nil {
+		return nil, err
+	}
+	if err = s.srv.Quota.Check(ctx, projectID, nodeType.ID, nodeTypeGPUs(nodeType)); err != nil {
+		return nil, err
+	}
+
+	res := &types.SessionCreateDryRunResponse{
+		DryRun:            true,
+		Provider:          rt.GetProvider(),
+		NodeTypeID:        nodeType.ID,
+		Region:            region,
+		SSHKeyName:        sshKey.Name,
+		Specs:             nodeType.Specs,
+		PricePerHourCents: nodeType.Price,
+	}
+	if duration > 0 {
+		secs := int64(duration / time.Second)
+		res.DurationSeconds = &secs
+		if nodeType.Price != nil {
+			cost := int64(math.Ceil(float64(*nodeType.Price) * duration.Hours()))
+			res.EstimatedCostCents = &cost
+		}
+	}
+	return res, nil
+}
+
+// dryRunRegion returns the region a node of the node type would be launched in. It's
+// the requested region if it has capacity and otherwise the first region that does.
+func dryRunRegion(nodeType *types.NodeType, requested *string) (string, error) {
+	if requested == nil || *requested == "" {
+		if len(nodeType.Regions) == 0 {
+			return "", nil
+		}
+		return nodeType.Regions[0], nil
+	}
+	for _, r := range nodeType.Regions {
+		if r == *requested {
+			return r, nil
+		}
+	}
+	return "", &types.Error{
+		Code:       http.StatusServiceUnavailable,
+		Message:    fmt.Sprintf("No capacity for node type %q in region %q", nodeType.ID, *requested),
+		Suggestion: fmt.Sprintf("Try one of the regions with capacity: %s", strings.Join(nodeType.Regions, ", ")),
+	}
+}
+
+// launch initializes a node and records the session in the db. The key must already
+// exist in the caller's account.
+//
+// Nodes are launched with sshKey and the platform key so that the API can reach them
+// too, e.g. to run readiness probes or to proxy gateway connections.
+func (s *SessionService) launch(ctx context.Context, rt runtime.Session, projectID string, params types.SessionCreateParams, sshKey types.SSHKey) (*types.Session, error) {
+	if err := checkBudget(ctx, projectID); err != nil {
+		return nil, err
+	}
+	if err := registerCredentials(ctx, rt, sshKey); err != nil {
 		return nil, fmt.Errorf("failed to register credentials: %w", err)
 	}
+	keys := []types.SSHKey{sshKey}
+	if platformSigner != nil {
+		platformKey := platformSSHKey()
+		if err := registerCredentials(ctx, rt, platformKey); err != nil {
+			return nil, fmt.Errorf("failed to register platform credentials: %w", err)
+		}
+		keys = append(keys, platformKey)
+	}
 
-	node, err := rt.InitNode(ctx, sshKey, params.NodeTypeID, params.Region)
+	// The node type is looked up before the node is launched so that the session keeps
+	// its specs and price even if the provider's catalog changes later on.
+	nodeType, err := findNodeType(ctx, rt, params.NodeTypeID)
+	if err != nil {
+		return nil, err
+	}
+	if nodeType == nil {
+		log.Ctx(ctx).Warn().Msgf("Node type %q isn't in the provider's catalog", params.NodeTypeID)
+	}
+	if err = checkCredit(ctx, s.srv.cid, nodeType); err != nil {
+		return nil, err
+	}
+	// The quotas are held until the session's usage is recorded so that concurrent
+	// creates can't exceed them.
+	release, err := s.srv.Quota.Acquire(ctx, projectID, params.NodeTypeID, nodeTypeGPUs(nodeType))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	node, err := rt.InitNode(ctx, keys, params.NodeTypeID, params.Region)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init node: %w", err)
 	}
 
-	connInfo, err := json.Marshal(ConnectionInfoV1{Version: 1})
+	connInfo, err := json.Marshal(ConnectionInfoV2{Version: 2})
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal connection info: %w", err)
 	}
+	labels := params.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	probes := types.ReadinessProbes{}
+	if params.Readiness != nil {
+		probes = *params.Readiness
+	}
+	readiness, err := json.Marshal(ReadinessV1{Version: 1, Probes: probes})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal readiness probes: %w", err)
+	}
+	spec := types.ArtifactSpec{}
+	if params.Artifacts != nil {
+		spec = *params.Artifacts
+	}
+	artifactsJSON, err := json.Marshal(ArtifactsV1{Version: 1, Spec: spec})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal artifact spec: %w", err)
+	}
+	setup := SetupV1{Version: 1}
+	if params.SetupScript != nil {
+		setup.Script = *params.SetupScript
+	}
+	if params.TerminateOnSetupFailure != nil {
+		setup.TerminateOnFailure = *params.TerminateOnSetupFailure
+	}
+	setupJSON, err := json.Marshal(setup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal setup script: %w", err)
+	}
+
+	specs := NodeSpecsV1{}
+	if nodeType != nil {
+		specs = NodeSpecsV1{Version: 1, Specs: nodeType.Specs}
+	}
+	specsJSON, err := json.Marshal(specs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal node specs: %w", err)
+	}
 
 	dbp := db.SessionCreateParams{
 		NodeID:         node.ID,
@@ -199,23 +441,58 @@ func (s *SessionService) Create(ctx context.Context, projectID string, params ty
 		Region:         node.Region,
 		Name:           random.GenerateRandomPhrase(4, "-"),
 		ConnectionInfo: connInfo,
+		Labels:         labelsJSON,
+		Readiness:      readiness,
+		Artifacts:      artifactsJSON,
+		Setup:          setupJSON,
+		NodeTypeID:     sql.NullString{String: node.TypeID, Valid: true},
+		NodeSpecs:      specsJSON,
 		SshKeyName:     sshKey.Name,
 	}
+	if nodeType != nil && nodeType.Price != nil {
+		dbp.Price = sql.NullInt32{Int32: int32(*nodeType.Price), Valid: true}
+	}
+	if params.TemplateID != nil && params.TemplateVersion != nil {
+		dbp.TemplateID = sql.NullString{String: *params.TemplateID, Valid: true}
+		dbp.TemplateVersion = sql.NullInt32{Int32: int32(*params.TemplateVersion), Valid: true}
+	}
 	sessionID, err := db.Q.SessionCreate(ctx, dbp)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session in db: %w", err)
 	}
+	if err = startSessionUsage(ctx, sessionID, dbp); err != nil {
+		// Sessions without a usage record don't count against quotas, budgets or credit
+		// so they can't be allowed to run. This happens before the quotas are released
+		// so that no other create relies on the missing record.
+		if e := rt.TerminateNode(ctx, node.ID); e != nil {
+			log.Ctx(ctx).Error().Err(e).Msgf("Failed to terminate node %q without usage", node.ID)
+		}
+		handleSessionError(ctx, sessionID, err, "Failed to record session usage")
+		return nil, err
+	}
 
 	createdAt := time.Now()
 	session := &types.Session{
-		ID:         sessionID,
-		SSHKey:     node.KeyPair,
-		Connection: nil,
-		Status:     types.StatusInitializing,
-		CreatedAt:  &createdAt,
-		NodeTypeID: node.TypeID,
-		Region:     node.Region,
-		Provider:   node.Provider,
+		ID:           sessionID,
+		SSHKey:       sshKey,
+		Connection:   nil,
+		Status:       types.StatusInitializing,
+		CreatedAt:    &createdAt,
+		NodeTypeID:   node.TypeID,
+		Region:       node.Region,
+		Provider:     node.Provider,
+		Labels:       params.Labels,
+		ExposedPorts: []types.ExposedPort{},
+	}
+	if nodeType != nil {
+		session.NodeSpecs = &nodeType.Specs
+		session.Price = nodeType.Price
+	}
+	if dbp.TemplateID.Valid {
+		session.Template = &types.SessionTemplateRef{
+			ID:      *params.TemplateID,
+			Version: *params.TemplateVersion,
+		}
 	}
 
 	return session, nil
@@ -233,9 +510,18 @@ func (s *SessionService) Get(ctx context.Context, sessionID string) (*types.Sess
 		return nil, fmt.Errorf("failed to get session from db: %w", err)
 	}
 
-	connInfo := &ConnectionInfoV1{}
-	if err := json.Unmarshal(dbs.ConnectionInfo, connInfo); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal connection info: %w", err)
+	connInfo, err := parseConnectionInfo(dbs.ConnectionInfo)
+	if err != nil {
+		return nil, err
+	}
+	conn := connInfo.toAPI()
+	specs, err := parseNodeSpecs(dbs.NodeSpecs)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{}
+	if err := json.Unmarshal(dbs.Labels, &labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
 	}
 
 	session := &types.Session{
@@ -245,16 +531,19 @@ func (s *SessionService) Get(ctx context.Context, sessionID string) (*types.Sess
 			PublicKey: &dbs.PublicKey,
 			CreatedAt: &dbs.SshKeyCreatedAt,
 		},
-		Connection: &types.ConnectionInfo{
-			Host: connInfo.Host,
-			Port: connInfo.Port,
-			User: connInfo.User,
-		},
+		Connection: &conn,
 		Status:     types.SessionStatus(dbs.Status),
 		CreatedAt:  &dbs.CreatedAt,
-		NodeTypeID: dbs.NodeID,
+		NodeTypeID: dbs.NodeTypeID.String,
 		Region:     dbs.Region,
 		Provider:   types.RuntimeProvider(dbs.Provider),
+		Labels:     labels,
+		Template:   sessionTemplateRef(dbs.TemplateID, dbs.TemplateVersion),
+		NodeSpecs:  specs,
+		Price:      sessionPrice(dbs.Price),
+	}
+	if session.ExposedPorts, err = sessionExposedPorts(ctx, dbs.ProjectID, sessionID); err != nil {
+		return nil, err
 	}
 	return session, nil
 }
@@ -273,9 +562,18 @@ func (s *SessionService) List(ctx context.Context, projectID string, listTermina
 		if !listTerminated && s.Status == db.UnweaveSessionStatusTerminated {
 			continue
 		}
-		connInfo := &ConnectionInfoV1{}
-		if err := json.Unmarshal(s.ConnectionInfo, connInfo); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal connection info: %w", err)
+		connInfo, err := parseConnectionInfo(s.ConnectionInfo)
+		if err != nil {
+			return nil, err
+		}
+		conn := connInfo.toAPI()
+		specs, err := parseNodeSpecs(s.NodeSpecs)
+		if err != nil {
+			return nil, err
+		}
+		labels := map[string]string{}
+		if err := json.Unmarshal(s.Labels, &labels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
 		}
 		session := types.Session{
 			ID: s.ID,
@@ -284,22 +582,27 @@ func (s *SessionService) List(ctx context.Context, projectID string, listTermina
 				PublicKey: &s.PublicKey,
 				CreatedAt: &s.SshKeyCreatedAt,
 			},
-			Connection: &types.ConnectionInfo{
-				Host: connInfo.Host,
-				Port: connInfo.Port,
-				User: connInfo.User,
-			},
+			Connection: &conn,
 			Status:     types.SessionStatus(s.Status),
 			CreatedAt:  &s.CreatedAt,
-			NodeTypeID: s.NodeID,
+			NodeTypeID: s.NodeTypeID.String,
 			Region:     s.Region,
 			Provider:   types.RuntimeProvider(s.Provider),
+			Labels:     labels,
+			Template:   sessionTemplateRef(s.TemplateID, s.TemplateVersion),
+			NodeSpecs:  specs,
+			Price:      sessionPrice(s.Price),
+		}
+		if session.ExposedPorts, err = sessionExposedPorts(ctx, projectID, s.ID); err != nil {
+			return nil, err
 		}
 		res = append(res, session)
 	}
 	return res, nil
 }
 
+// Watch watches the session until it terminates. Only one replica of the API watches a
+// session at a time. Watch is a no-op if the session is already watched.
 func (s *SessionService) Watch(ctx context.Context, sessionID string) error {
 	session, err := db.Q.SessionGet(ctx, sessionID)
 	if err != nil {
@@ -312,44 +615,88 @@ func (s *SessionService) Watch(ctx context.Context, sessionID string) error {
 		return fmt.Errorf("failed to get session from db: %w", err)
 	}
 
-	rt, err := s.srv.InitializeRuntime(ctx, types.RuntimeProvider(session.Provider))
+	leaseKey := sessionLeaseKey(sessionID)
+	leaseCtx, ok, err := leases.acquire(ctx, leaseKey)
 	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Ctx(ctx).Info().Msgf("Session %s is already watched", sessionID)
+		return nil
+	}
+	parent := ctx
+	release := func() { leases.release(parent, leaseKey) }
+
+	rt, err := s.srv.InitializeRuntime(leaseCtx, types.RuntimeProvider(session.Provider))
+	if err != nil {
+		release()
 		return fmt.Errorf("failed to initialize runtime: %w", err)
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
-	statusch, errch := rt.Watch(ctx, session.NodeID)
+	// Sessions that were already running before the API restarted have passed their
+	// readiness probes.
+	ready := session.Status == db.UnweaveSessionStatusRunning ||
+		session.Status == db.UnweaveSessionStatusDegraded
+
+	started := supervisor.start(leaseCtx, session, func(ctx context.Context) {
+		defer release()
+
+		statusch, errch := rt.Watch(ctx, session.NodeID)
 
-	log.Ctx(ctx).Info().Msgf("Starting to watch session %s", sessionID)
+		log.Ctx(ctx).Info().Msgf("Starting to watch session %s", sessionID)
+
+		if ready {
+			go collectMetrics(ctx, sessionID)
+		}
+
+		heartbeats := time.NewTicker(agentHeartbeatInterval)
+		defer heartbeats.Stop()
+
+		// degradedByPolicy is set while the session is degraded because its node status
+		// can't be fetched.
+		degradedByPolicy := false
 
-	go func() {
-		defer cancel()
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-heartbeats.C:
+				if !ready || degradedByPolicy {
+					continue
+				}
+				if e := checkAgentHeartbeat(ctx, sessionID); e != nil {
+					log.Ctx(ctx).Error().Err(e).Msg("Failed to check agent heartbeat")
+				}
 			case status := <-statusch:
 				log.Ctx(ctx).
 					Info().
 					Str(SessionStatusCtxKey, string(status)).
 					Msg("session status changed")
 
-				if status == types.StatusRunning {
-					if e := updateConnectionInfo(ctx, rt, session.NodeID, sessionID); e != nil {
+				if status == types.StatusRunning && !ready {
+					if e := s.provision(ctx, rt, session); e != nil {
 						// We mark the error in the DB but don't terminate the node. This
 						// is left to the user to do manually. Perhaps this should be
 						// changed in the future but for now, it might help debugging.
-						handleSessionError(ctx, sessionID, e, "Failed to update connection info")
-						// TODO: we should perhaps do some retries here
+						// Sessions can opt into terminating on setup script failures.
+						msg := "Failed to provision node"
+						var perr *types.Error
+						if errors.As(e, &perr) {
+							msg = perr.Message
+						}
+						handleSessionError(ctx, sessionID, e, msg)
 						return
 					}
+					ready = true
+					go collectMetrics(ctx, sessionID)
+					go s.startAgent(ctx, sessionID)
 				}
 
 				params := db.SessionStatusUpdateParams{
 					ID:     sessionID,
 					Status: db.UnweaveSessionStatus(status),
 				}
-				if e := db.Q.SessionStatusUpdate(ctx, params); e != nil {
+				if e := setSessionStatus(ctx, params); e != nil {
 					log.Ctx(ctx).Error().Err(e).Msg("failed to update session status")
 					return
 				}
@@ -359,26 +706,63 @@ func (s *SessionService) Watch(ctx context.Context, sessionID string) error {
 			case e := <-errch:
 				log.Ctx(ctx).Error().Err(e).Msg("Error while watching session")
 
-				// This means we failed to watch the session. This should ideally never
-				// happen. Since we don't know the cause of this error, let's play it safe
-				// and terminate the node. This will mean the user will lose their work
-				// but the alternative is to have a runaway node that drains all their credit.
-				var err *types.Error
-				if errors.As(e, &err) {
-					handleSessionError(ctx, sessionID, e, err.Message)
-				}
-				if err := s.Terminate(ctx, sessionID); err != nil {
-					log.Ctx(ctx).Error().Err(err).Msg("failed to terminate session on failure to watch")
+				// The project's watch policy decides whether to keep retrying, degrade the
+				// session or terminate the node.
+				status, outcome := s.handleWatchError(ctx, rt, session, e, degradedByPolicy)
+				switch outcome {
+				case watchStop:
+					return
+				case watchDegraded:
+					degradedByPolicy = true
+				case watchRecovered:
+					if degradedByPolicy && status == types.StatusRunning {
+						params := db.SessionStatusUpdateParams{
+							ID:     sessionID,
+							Status: db.UnweaveSessionStatusRunning,
+						}
+						if e := setSessionStatus(ctx, params); e != nil {
+							log.Ctx(ctx).Error().Err(e).Msg("failed to update session status")
+						}
+					}
+					degradedByPolicy = false
 				}
-				return
 			}
 		}
-	}()
-
+	})
+	if !started {
+		release()
+		log.Ctx(ctx).Info().Msgf("Not watching session %s, the supervisor is shutting down", sessionID)
+	}
 	return nil
 }
 
-func (s *SessionService) Terminate(ctx context.Context, sessionID string) error {
+// startAgent installs the node agent on a session that just became ready. Failing to
+// install it doesn't fail the session since it stays usable over SSH. It degrades once
+// heartbeats fail to arrive instead.
+func (s *SessionService) startAgent(ctx context.Context, sessionID string) {
+	sess, err := db.Q.SessionGet(ctx, sessionID)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get session from db")
+		return
+	}
+	connInfo, err := parseConnectionInfo(sess.ConnectionInfo)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to parse connection info")
+		return
+	}
+	if err = installAgent(ctx, sessionID, connInfo.toAPI()); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to install node agent")
+	}
+}
+
+type TerminateOptions struct {
+	// SkipArtifacts terminates the node without collecting the session's artifacts.
+	SkipArtifacts bool
+}
+
+// Terminate terminates the session's node. The session's artifacts are collected first
+// unless skipped. Failing to collect them doesn't stop the node from being terminated.
+func (s *SessionService) Terminate(ctx context.Context, sessionID string, opts TerminateOptions) error {
 	sess, err := db.Q.SessionGet(ctx, sessionID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -402,14 +786,22 @@ func (s *SessionService) Terminate(ctx context.Context, sessionID string) error
 		Logger().
 		WithContext(ctx)
 
+	if !opts.SkipArtifacts {
+		if err = collectArtifacts(ctx, sess); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to collect artifacts")
+		}
+	}
+
 	if err = rt.TerminateNode(ctx, sess.NodeID); err != nil {
 		return fmt.Errorf("failed to terminate node: %w", err)
 	}
+	nodeTunnels.close(sessionID)
+	supervisor.stop(sessionID)
 	params := db.SessionStatusUpdateParams{
 		ID:     sessionID,
 		Status: db.UnweaveSessionStatusTerminated,
 	}
-	if err = db.Q.SessionStatusUpdate(ctx, params); err != nil {
+	if err = setSessionStatus(ctx, params); err != nil {
 		log.Ctx(ctx).
 			Error().
 			Err(err).
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/runtime"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultSetupTimeout is how long setup scripts can run before they are killed.
+var defaultSetupTimeout = 30 * time.Minute
+
+// setupLogSource is the source of the logs of setup scripts in the session_log table.
+const setupLogSource = "setup"
+
+// SetupV1 versions the setup script stored in the DB.
+type SetupV1 struct {
+	Version            int    `json:"version"`
+	Script             string `json:"script,omitempty"`
+	TerminateOnFailure bool   `json:"terminateOnFailure,omitempty"`
+}
+
+func parseSetup(data json.RawMessage) (SetupV1, error) {
+	s := SetupV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return SetupV1{}, fmt.Errorf("failed to unmarshal setup script: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// logWriter stores every line written to it as a log entry of the session.
+type logWriter struct {
+	ctx       context.Context
+	sessionID string
+	stream    string
+	// mu serializes inserts so that lines of both streams keep their order.
+	mu *sync.Mutex
+}
+
+func (l logWriter) consume(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		l.mu.Lock()
+		err := db.Q.SessionLogAdd(l.ctx, db.SessionLogAddParams{
+			SessionID: l.sessionID,
+			Source:    setupLogSource,
+			Stream:    l.stream,
+			Timestamp: time.Now(),
+			Message:   scanner.Text(),
+		})
+		l.mu.Unlock()
+		if err != nil {
+			log.Ctx(l.ctx).Error().Err(err).Msg("Failed to store setup log")
+		}
+	}
+	// Drain the rest of the output so that the command doesn't block on a full pipe.
+	_, _ = io.Copy(io.Discard, r)
+}
+
+// runSetupScript runs the script with bash on the node and stores its output line by
+// line. It returns the exit code of the script.
+func runSetupScript(ctx context.Context, conn types.ConnectionInfo, sessionID, script string) (int, error) {
+	client, err := dialNode(ctx, conn)
+	if err != nil {
+		return -1, err
+	}
+	defer client.Close()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return -1, fmt.Errorf("failed to create ssh session: %w", err)
+	}
+	defer sess.Close()
+
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return -1, fmt.Errorf("failed to open stdout: %w", err)
+	}
+	stderr, err := sess.StderrPipe()
+	if err != nil {
+		return -1, fmt.Errorf("failed to open stderr: %w", err)
+	}
+
+	mu := &sync.Mutex{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		logWriter{ctx: ctx, sessionID: sessionID, stream: types.LogStreamStdout, mu: mu}.consume(stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		logWriter{ctx: ctx, sessionID: sessionID, stream: types.LogStreamStderr, mu: mu}.consume(stderr)
+	}()
+
+	if err = sess.Start(shellQuote([]string{"bash", "-c", script})); err != nil {
+		return -1, fmt.Errorf("failed to start setup script: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		// Wait only returns once stdout and stderr are closed.
+		wg.Wait()
+		done <- sess.Wait()
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = sess.Signal(ssh.SIGKILL)
+		return -1, ctx.Err()
+	case err = <-done:
+	}
+
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitStatus(), nil
+		}
+		return -1, fmt.Errorf("failed to run setup script: %w", err)
+	}
+	return 0, nil
+}
+
+// setup runs the session's setup script once its node is reachable. If the script fails
+// and the session is configured to, the node is terminated. The caller is responsible
+// for marking the session as errored.
+//
+// The script runs at most once per session: it is claimed in the DB before it starts, so
+// it is skipped when provisioning is restarted, even if the earlier run was interrupted.
+func (s *SessionService) setup(ctx context.Context, rt runtime.Session, sess db.UnweaveSession, conn types.ConnectionInfo) error {
+	setup, err := parseSetup(sess.Setup)
+	if err != nil {
+		return err
+	}
+	if setup.Script == "" {
+		return nil
+	}
+
+	if _, err = db.Q.SessionSetupStart(ctx, sess.ID); err != nil {
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to mark setup as started: %w", err)
+		}
+		if sess.SetupFinishedAt.Valid {
+			log.Ctx(ctx).Info().Msg("Setup script already ran, skipping")
+		} else {
+			log.Ctx(ctx).Warn().Msg("Setup script was interrupted by a restart, not running it again")
+		}
+		return nil
+	}
+
+	log.Ctx(ctx).Info().Msg("Running setup script")
+
+	c, cancel := context.WithTimeout(ctx, defaultSetupTimeout)
+	code, err := runSetupScript(c, conn, sess.ID, setup.Script)
+	cancel()
+
+	if err == nil {
+		if ferr := db.Q.SessionSetupFinish(ctx, sess.ID); ferr != nil {
+			log.Ctx(ctx).Error().Err(ferr).Msg("Failed to mark setup as finished")
+		}
+	}
+
+	if err == nil && code == 0 {
+		log.Ctx(ctx).Info().Msg("Setup script succeeded")
+		return nil
+	}
+
+	var setupErr error
+	if err != nil {
+		setupErr = &types.Error{
+			Code:       http.StatusInternalServerError,
+			Message:    "Failed to run setup script",
+			Suggestion: "Check the setup logs of the session",
+			Err:        err,
+		}
+	} else {
+		setupErr = &types.Error{
+			Code:       http.StatusUnprocessableEntity,
+			Message:    fmt.Sprintf("Setup script exited with code %d", code),
+			Suggestion: "Check the setup logs of the session",
+		}
+	}
+
+	if setup.TerminateOnFailure {
+		if err = rt.TerminateNode(ctx, sess.NodeID); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to terminate node after setup failure")
+		} else {
+			nodeTunnels.close(sess.ID)
+			endSessionUsage(ctx, sess.ID)
+			log.Ctx(ctx).Info().Msg("Terminated node after setup failure")
+		}
+	}
+	return setupErr
+}
+
+func (s *SessionService) SetupLogs(ctx context.Context, sessionID string) ([]types.LogEntry, error) {
+	rows, err := db.Q.SessionLogsGet(ctx, db.SessionLogsGetParams{SessionID: sessionID, Source: setupLogSource})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get setup logs from db: %w", err)
+	}
+	logs := make([]types.LogEntry, 0, len(rows))
+	for _, l := range rows {
+		logs = append(logs, types.LogEntry{TimeStamp: l.Timestamp, Message: l.Message, Stream: l.Stream})
+	}
+	return logs, nil
+}
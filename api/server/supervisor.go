@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// supervisor owns the goroutines watching sessions on this replica.
+var supervisor = newSupervisor()
+
+type sessionWatcher struct {
+	sessionID string
+	projectID string
+	accountID uuid.UUID
+	startedAt time.Time
+	cancel    context.CancelFunc
+}
+
+// Supervisor tracks the watcher of each session so that watchers can be listed, aren't
+// started twice and can be cancelled when their session terminates or the API shuts down.
+type Supervisor struct {
+	mu       sync.Mutex
+	watchers map[string]*sessionWatcher
+	draining bool
+	wg       sync.WaitGroup
+}
+
+func newSupervisor() *Supervisor {
+	return &Supervisor{watchers: map[string]*sessionWatcher{}}
+}
+
+// start runs fn in the background as the watcher of the session. It returns false without
+// running fn if the session already has a watcher or the supervisor is draining.
+func (s *Supervisor) start(ctx context.Context, sess db.UnweaveSession, fn func(ctx context.Context)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		return false
+	}
+	if _, ok := s.watchers[sess.ID]; ok {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &sessionWatcher{
+		sessionID: sess.ID,
+		projectID: sess.ProjectID,
+		accountID: sess.CreatedBy,
+		startedAt: time.Now(),
+		cancel:    cancel,
+	}
+	s.watchers[sess.ID] = w
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			if s.watchers[sess.ID] == w {
+				delete(s.watchers, sess.ID)
+			}
+			s.mu.Unlock()
+			cancel()
+		}()
+		fn(ctx)
+	}()
+	return true
+}
+
+// stop cancels the watcher of the session, if any. It doesn't wait for the watcher to
+// return so that it is safe to call from the watcher itself.
+func (s *Supervisor) stop(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w, ok := s.watchers[sessionID]; ok {
+		w.cancel()
+		delete(s.watchers, sessionID)
+	}
+}
+
+// drain cancels all watchers and waits for them to return or for the context to be done.
+// No new watchers are started once the supervisor is draining.
+func (s *Supervisor) drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	for id, w := range s.watchers {
+		w.cancel()
+		delete(s.watchers, id)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Supervisor) state() types.SupervisorState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := types.SupervisorState{
+		Replica:  leases.owner,
+		Draining: s.draining,
+		Watchers: make([]types.SessionWatcher, 0, len(s.watchers)),
+	}
+	for _, w := range s.watchers {
+		state.Watchers = append(state.Watchers, types.SessionWatcher{
+			SessionID: w.sessionID,
+			ProjectID: w.projectID,
+			AccountID: w.accountID,
+			StartedAt: w.startedAt,
+		})
+	}
+	sort.Slice(state.Watchers, func(i, j int) bool {
+		return state.Watchers[i].StartedAt.Before(state.Watchers[j].StartedAt)
+	})
+	return state
+}
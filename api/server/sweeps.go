@@ -0,0 +1,598 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// sweepSchedulePollInterval is how often a running sweep checks if it was canceled and
+// retries launching trials that are waiting for capacity.
+var sweepSchedulePollInterval = 30 * time.Second
+
+// SweepSpecV1 versions the sweep definition stored in the DB.
+type SweepSpecV1 struct {
+	Version int                     `json:"version"`
+	Params  types.SweepCreateParams `json:"params"`
+}
+
+// sweepTrialParams expands the parameter space of a sweep into the parameters of each
+// trial.
+func sweepTrialParams(params types.SweepCreateParams, rng *rand.Rand) []map[string]interface{} {
+	if params.Method == types.SweepRandom {
+		trials := make([]map[string]interface{}, params.Samples)
+		for i := range trials {
+			trial := make(map[string]interface{}, len(params.Parameters))
+			for _, p := range params.Parameters {
+				trial[p.Name] = sampleParameter(p, rng)
+			}
+			trials[i] = trial
+		}
+		return trials
+	}
+
+	// Grid: cartesian product of all the values
+	trials := []map[string]interface{}{{}}
+	for _, p := range params.Parameters {
+		next := make([]map[string]interface{}, 0, len(trials)*len(p.Values))
+		for _, t := range trials {
+			for _, v := range p.Values {
+				trial := make(map[string]interface{}, len(t)+1)
+				for k, tv := range t {
+					trial[k] = tv
+				}
+				trial[p.Name] = v
+				next = append(next, trial)
+			}
+		}
+		trials = next
+	}
+	return trials
+}
+
+func sampleParameter(p types.SweepParameter, rng *rand.Rand) interface{} {
+	if len(p.Values) > 0 {
+		return p.Values[rng.Intn(len(p.Values))]
+	}
+
+	var v float64
+	if p.LogScale {
+		lmin, lmax := math.Log(*p.Min), math.Log(*p.Max)
+		v = math.Exp(lmin + rng.Float64()*(lmax-lmin))
+	} else {
+		v = *p.Min + rng.Float64()*(*p.Max-*p.Min)
+	}
+	if p.Integer {
+		return int64(math.Round(v))
+	}
+	return v
+}
+
+// renderSweepCommand renders the command template with shell quoted parameter values.
+func renderSweepCommand(command string, params map[string]interface{}) (string, error) {
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(command)
+	if err != nil {
+		return "", err
+	}
+	quoted := make(map[string]string, len(params))
+	for k, v := range params {
+		quoted[k] = shellQuote([]string{fmt.Sprint(v)})
+	}
+	var sb strings.Builder
+	if err = tmpl.Execute(&sb, quoted); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func dbSweepTrialToAPI(t db.UnweaveSweepTrial) (types.SweepTrial, error) {
+	trial := types.SweepTrial{
+		ID:      t.ID,
+		Index:   int(t.Index),
+		Command: t.Command,
+		Status:  types.PipelineStatus(t.Status),
+	}
+	if err := json.Unmarshal(t.Params, &trial.Params); err != nil {
+		return types.SweepTrial{}, fmt.Errorf("failed to unmarshal trial params: %w", err)
+	}
+	if t.NodeTypeID.Valid {
+		trial.NodeTypeID = &t.NodeTypeID.String
+	}
+	if t.SessionID.Valid {
+		trial.SessionID = &t.SessionID.String
+	}
+	if t.ExitCode.Valid {
+		code := int(t.ExitCode.Int32)
+		trial.ExitCode = &code
+	}
+	if t.StartedAt.Valid {
+		trial.StartedAt = &t.StartedAt.Time
+	}
+	if t.FinishedAt.Valid {
+		trial.FinishedAt = &t.FinishedAt.Time
+	}
+	if t.Error.Valid {
+		trial.Error = &t.Error.String
+	}
+	return trial, nil
+}
+
+type SweepService struct {
+	srv *Service
+}
+
+func (s *SweepService) Create(ctx context.Context, projectID string, params types.SweepCreateParams) (*types.Sweep, error) {
+	// Resolve the SSH key and build image the same way sessions do so that users can
+	// SSH into their trials.
+	sshKey, err := fetchCredentials(ctx, s.srv.cid, params.SSHKeyName, params.SSHPublicKey, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup credentials: %w", err)
+	}
+	params.SSHKeyName, params.SSHPublicKey = &sshKey.Name, nil
+
+	if params.BuildID == nil {
+		project, err := db.Q.ProjectGet(ctx, projectID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get project from db: %w", err)
+		}
+		if project.DefaultBuild.Valid {
+			params.BuildID = &project.DefaultBuild.String
+		}
+	}
+	if params.BuildID != nil {
+		if _, err = s.srv.Builder.GetImageURI(ctx, projectID, *params.BuildID); err != nil {
+			return nil, err
+		}
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	trials := sweepTrialParams(params, rng)
+	commands := make([]string, len(trials))
+	for i, t := range trials {
+		cmd, err := renderSweepCommand(params.Command, t)
+		if err != nil {
+			return nil, &types.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Failed to render sweep command",
+				Err:     err,
+			}
+		}
+		commands[i] = cmd
+	}
+
+	spec, err := json.Marshal(SweepSpecV1{Version: 1, Params: params})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sweep spec: %w", err)
+	}
+
+	// The sweep and its trials are created together so that a sweep is never resumed
+	// with only some of its trials.
+	var sweepID string
+	err = db.Tx(ctx, func(q db.Querier) error {
+		sweepID, err = q.SweepCreate(ctx, db.SweepCreateParams{
+			Name:           params.Name,
+			ProjectID:      projectID,
+			CreatedBy:      s.srv.cid,
+			Spec:           spec,
+			MaxConcurrency: int32(params.MaxConcurrency),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create sweep in db: %w", err)
+		}
+		for i, t := range trials {
+			tp, err := json.Marshal(t)
+			if err != nil {
+				return fmt.Errorf("failed to marshal trial params: %w", err)
+			}
+			p := db.SweepTrialCreateParams{
+				SweepID: sweepID,
+				Index:   int32(i),
+				Params:  tp,
+				Command: commands[i],
+			}
+			if _, err = q.SweepTrialCreate(ctx, p); err != nil {
+				return fmt.Errorf("failed to create sweep trial in db: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, projectID, sweepID)
+}
+
+func (s *SweepService) get(ctx context.Context, projectID, sweepID string) (db.UnweaveSweep, SweepSpecV1, error) {
+	sw, err := db.Q.SweepGet(ctx, sweepID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.UnweaveSweep{}, SweepSpecV1{}, &types.Error{
+				Code:    http.StatusNotFound,
+				Message: "Sweep not found",
+			}
+		}
+		return db.UnweaveSweep{}, SweepSpecV1{}, fmt.Errorf("failed to get sweep from db: %w", err)
+	}
+	if sw.ProjectID != projectID {
+		return db.UnweaveSweep{}, SweepSpecV1{}, &types.Error{
+			Code:    http.StatusNotFound,
+			Message: "Sweep not found",
+		}
+	}
+	spec := SweepSpecV1{}
+	if err = json.Unmarshal(sw.Spec, &spec); err != nil {
+		return db.UnweaveSweep{}, SweepSpecV1{}, fmt.Errorf("failed to unmarshal sweep spec: %w", err)
+	}
+	return sw, spec, nil
+}
+
+func (s *SweepService) Get(ctx context.Context, projectID, sweepID string) (*types.Sweep, error) {
+	sw, spec, err := s.get(ctx, projectID, sweepID)
+	if err != nil {
+		return nil, err
+	}
+	trials, err := db.Q.SweepTrialsGet(ctx, sweepID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sweep trials from db: %w", err)
+	}
+
+	sweep := &types.Sweep{
+		ID:             sw.ID,
+		Name:           sw.Name,
+		Status:         types.PipelineStatus(sw.Status),
+		Command:        spec.Params.Command,
+		MaxConcurrency: int(sw.MaxConcurrency),
+		NodeTypeIDs:    spec.Params.NodeTypeIDs,
+		CreatedAt:      sw.CreatedAt,
+		Trials:         make([]types.SweepTrial, len(trials)),
+	}
+	if sw.Error.Valid {
+		sweep.Error = &sw.Error.String
+	}
+	for i, t := range trials {
+		trial, err := dbSweepTrialToAPI(t)
+		if err != nil {
+			return nil, err
+		}
+		sweep.Trials[i] = trial
+	}
+	return sweep, nil
+}
+
+func (s *SweepService) List(ctx context.Context, projectID string) ([]types.Sweep, error) {
+	sweeps, err := db.Q.SweepsGet(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sweeps from db: %w", err)
+	}
+
+	res := make([]types.Sweep, len(sweeps))
+	for i, sw := range sweeps {
+		sweep, err := s.Get(ctx, projectID, sw.ID)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = *sweep
+	}
+	return res, nil
+}
+
+// publishSweepStatus publishes the outcome of a sweep.
+func publishSweepStatus(ctx context.Context, projectID, sweepID string, status db.UnweavePipelineStatus, errMsg string) {
+	var event types.EventType
+	switch status {
+	case db.UnweavePipelineStatusSucceeded:
+		event = types.EventSweepSucceeded
+	case db.UnweavePipelineStatusFailed:
+		event = types.EventSweepFailed
+	case db.UnweavePipelineStatusCanceled:
+		event = types.EventSweepCanceled
+	default:
+		return
+	}
+	data := types.SweepEventData{SweepID: sweepID, Status: types.PipelineStatus(status)}
+	if errMsg != "" {
+		data.Error = &errMsg
+	}
+	publishEvent(ctx, projectID, event, data)
+}
+
+// Cancel marks a sweep as canceled. The executor running the sweep picks this up, stops
+// its running trials and cancels the ones that haven't started yet.
+func (s *SweepService) Cancel(ctx context.Context, projectID, sweepID string) error {
+	sw, _, err := s.get(ctx, projectID, sweepID)
+	if err != nil {
+		return err
+	}
+	if types.PipelineStatus(sw.Status).IsTerminal() {
+		return &types.Error{
+			Code:    http.StatusConflict,
+			Message: fmt.Sprintf("Sweep already %s", sw.Status),
+		}
+	}
+
+	params := db.SweepStatusUpdateParams{
+		ID:     sweepID,
+		Status: db.UnweavePipelineStatusCanceled,
+		Error:  sql.NullString{String: "Canceled by user", Valid: true},
+	}
+	if err = db.Q.SweepStatusUpdate(ctx, params); err != nil {
+		return fmt.Errorf("failed to update sweep status: %w", err)
+	}
+	publishSweepStatus(ctx, projectID, sweepID, params.Status, params.Error.String)
+	return nil
+}
+
+// pickNodeType returns one of the sweep's node types that currently has capacity. The
+// preference order is rotated by the trial index so that trials are spread across node
+// types. It returns false if none of the node types are available.
+func pickNodeType(ctx context.Context, srv *Service, params types.SweepCreateParams, index int) (string, bool) {
+	nodeTypes, err := srv.Provider.ListNodeTypes(ctx, params.Provider, true)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to list available node types")
+		return "", false
+	}
+
+	available := make(map[string]bool, len(nodeTypes))
+	for _, nt := range nodeTypes {
+		if params.Region == nil {
+			available[nt.ID] = true
+			continue
+		}
+		for _, r := range nt.Regions {
+			if r == *params.Region {
+				available[nt.ID] = true
+			}
+		}
+	}
+
+	n := len(params.NodeTypeIDs)
+	for i := 0; i < n; i++ {
+		id := params.NodeTypeIDs[(index+i)%n]
+		if available[id] {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// Execute runs a sweep in the background, keeping at most MaxConcurrency trials running
+// at the same time. Trials wait for capacity if none of the sweep's node types are
+// available. Only one replica of the API runs a sweep at a time.
+func (s *SweepService) Execute(ctx context.Context, sweepID string) error {
+	sw, err := db.Q.SweepGet(ctx, sweepID)
+	if err != nil {
+		return fmt.Errorf("failed to get sweep from db: %w", err)
+	}
+	_, spec, err := s.get(ctx, sw.ProjectID, sweepID)
+	if err != nil {
+		return err
+	}
+
+	// The trials are read under the lease so that they aren't changed by another replica
+	// in the meantime.
+	leaseKey := sweepLeaseKey(sweepID)
+	leaseCtx, ok, err := leases.acquire(ctx, leaseKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Ctx(ctx).Info().Msgf("Sweep %s is already executing", sweepID)
+		return nil
+	}
+
+	trials, err := db.Q.SweepTrialsGet(ctx, sweepID)
+	if err != nil {
+		leases.release(ctx, leaseKey)
+		return fmt.Errorf("failed to get sweep trials from db: %w", err)
+	}
+	params := db.SweepStatusUpdateParams{ID: sweepID, Status: db.UnweavePipelineStatusRunning}
+	if err = db.Q.SweepStatusUpdate(ctx, params); err != nil {
+		leases.release(ctx, leaseKey)
+		return fmt.Errorf("failed to update sweep status: %w", err)
+	}
+
+	log.Ctx(ctx).Info().Msgf("Starting to execute sweep %s with %d trials", sweepID, len(trials))
+
+	go func() {
+		defer leases.release(ctx, leaseKey)
+		s.execute(leaseCtx, sw, spec.Params, trials)
+	}()
+	return nil
+}
+
+func (s *SweepService) execute(ctx context.Context, sw db.UnweaveSweep, params types.SweepCreateParams, trials []db.UnweaveSweepTrial) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The executor outlives the request that started it so it doesn't share the
+	// request's service. Trials get their own for the same reason as pipeline steps.
+	srv := NewCtxService(s.srv.rti, s.srv.cid)
+
+	var pending []db.UnweaveSweepTrial
+	for _, t := range trials {
+		switch t.Status {
+		case db.UnweavePipelineStatusPending:
+			pending = append(pending, t)
+		case db.UnweavePipelineStatusRunning:
+			// The API restarted while this trial was running so there's no way to
+			// recover its result. Its node is terminated as nothing watches it anymore.
+			if t.SessionID.Valid {
+				srv.Session.terminateInterruptedJob(ctx, t.SessionID.String)
+			}
+			s.finishTrial(ctx, t, types.PipelineFailed, nil, "Interrupted by an API restart")
+		}
+	}
+
+	done := make(chan types.PipelineStatus)
+	running, failed, succeeded := 0, 0, 0
+	canceled := false
+
+	ticker := time.NewTicker(sweepSchedulePollInterval)
+	defer ticker.Stop()
+
+	for {
+		for !canceled && running < int(sw.MaxConcurrency) && len(pending) > 0 {
+			trial := pending[0]
+			nodeTypeID, ok := pickNodeType(ctx, srv, params, int(trial.Index))
+			if !ok {
+				log.Ctx(ctx).Info().Msg("No capacity for any of the sweep's node types, waiting")
+				break
+			}
+			p := db.SweepTrialStartParams{
+				ID:         trial.ID,
+				NodeTypeID: sql.NullString{String: nodeTypeID, Valid: true},
+			}
+			if err := db.Q.SweepTrialStart(ctx, p); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msgf("Failed to start trial %d", trial.Index)
+				break
+			}
+			pending = pending[1:]
+			running++
+
+			spec := jobSpec{
+				Provider:   params.Provider,
+				NodeTypeID: nodeTypeID,
+				Region:     params.Region,
+				BuildID:    params.BuildID,
+				Command:    []string{"sh", "-c", trial.Command},
+				// Create resolved the key to one that exists in the account
+				SSHKeyName: *params.SSHKeyName,
+			}
+			go func(t db.UnweaveSweepTrial) {
+				done <- s.runTrial(ctx, sw.ProjectID, t, spec)
+			}(trial)
+		}
+
+		if errors.Is(leaseCause(ctx), errLeaseLost) {
+			// The lease was lost. The pending trials are left to the replica that
+			// adopts the sweep.
+			pending = nil
+		}
+		if canceled {
+			for _, t := range pending {
+				s.finishTrial(ctx, t, types.PipelineCanceled, nil, "Sweep canceled")
+			}
+			pending = nil
+		}
+		if running == 0 && len(pending) == 0 {
+			break
+		}
+
+		select {
+		case status := <-done:
+			running--
+			switch status {
+			case types.PipelineSucceeded:
+				succeeded++
+			case types.PipelineFailed:
+				failed++
+			}
+		case <-ticker.C:
+			sweep, err := db.Q.SweepGet(ctx, sw.ID)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("Failed to check sweep status")
+				continue
+			}
+			if sweep.Status == db.UnweavePipelineStatusCanceled && !canceled {
+				log.Ctx(ctx).Info().Msgf("Sweep %s canceled, stopping running trials", sw.ID)
+				canceled = true
+				cancel()
+			}
+		}
+	}
+
+	if canceled {
+		// Keep the status and reason set by Cancel
+		return
+	}
+	if errors.Is(leaseCause(ctx), errLeaseLost) {
+		log.Ctx(ctx).Warn().Msgf("Lost the lease of sweep %s, stopping", sw.ID)
+		return
+	}
+
+	// Individual trials failing is expected in a sweep. Only fail the sweep if none
+	// of them succeeded.
+	p := db.SweepStatusUpdateParams{ID: sw.ID, Status: db.UnweavePipelineStatusSucceeded}
+	if succeeded == 0 && failed > 0 {
+		p.Status = db.UnweavePipelineStatusFailed
+		p.Error = sql.NullString{String: "All trials failed", Valid: true}
+	}
+	if err := db.Q.SweepStatusUpdate(context.Background(), p); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to update sweep status")
+	}
+	publishSweepStatus(context.Background(), sw.ProjectID, sw.ID, p.Status, p.Error.String)
+	log.Ctx(ctx).Info().Msgf("Sweep %s finished: %d succeeded, %d failed", sw.ID, succeeded, failed)
+}
+
+func (s *SweepService) runTrial(ctx context.Context, projectID string, trial db.UnweaveSweepTrial, spec jobSpec) types.PipelineStatus {
+	ctx = log.With().Int32("sweepTrial", trial.Index).Logger().WithContext(ctx)
+
+	onSession := func(sessionID string) {
+		params := db.SweepTrialSetSessionParams{
+			ID:        trial.ID,
+			SessionID: sql.NullString{String: sessionID, Valid: true},
+		}
+		if err := db.Q.SweepTrialSetSession(ctx, params); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to set trial session")
+		}
+	}
+
+	srv := NewCtxService(s.srv.rti, s.srv.cid)
+	res, err := srv.Session.runJob(ctx, projectID, spec, onSession)
+	if errors.Is(leaseCause(ctx), errLeaseLost) {
+		// The trial is left running for the replica that adopts the sweep.
+		return types.PipelineRunning
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			s.finishTrial(ctx, trial, types.PipelineCanceled, nil, "Sweep canceled")
+			return types.PipelineCanceled
+		}
+		msg := err.Error()
+		var e *types.Error
+		if errors.As(err, &e) {
+			msg = e.Message
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Sweep trial failed")
+		s.finishTrial(ctx, trial, types.PipelineFailed, nil, msg)
+		return types.PipelineFailed
+	}
+
+	log.Ctx(ctx).Info().Str("output", res.Output).Msgf("Trial exited with code %d", res.ExitCode)
+
+	if res.ExitCode != 0 {
+		msg := fmt.Sprintf("Command exited with code %d", res.ExitCode)
+		s.finishTrial(ctx, trial, types.PipelineFailed, &res.ExitCode, msg)
+		return types.PipelineFailed
+	}
+	s.finishTrial(ctx, trial, types.PipelineSucceeded, &res.ExitCode, "")
+	return types.PipelineSucceeded
+}
+
+func (s *SweepService) finishTrial(ctx context.Context, trial db.UnweaveSweepTrial, status types.PipelineStatus, exitCode *int, msg string) {
+	params := db.SweepTrialFinishParams{
+		ID:     trial.ID,
+		Status: db.UnweavePipelineStatus(status),
+		Error:  sql.NullString{String: msg, Valid: msg != ""},
+	}
+	if exitCode != nil {
+		params.ExitCode = sql.NullInt32{Int32: int32(*exitCode), Valid: true}
+	}
+	// The trial context might already be canceled but the result still needs recording.
+	if err := db.Q.SweepTrialFinish(context.Background(), params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to finish trial %d", trial.Index)
+	}
+}
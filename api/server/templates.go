@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgconn"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// pgUniqueViolation is the Postgres error code returned when a unique constraint fails.
+const pgUniqueViolation = "23505"
+
+// SessionTemplateSpecV1 versions the template spec stored in the DB.
+type SessionTemplateSpecV1 struct {
+	Version int                       `json:"version"`
+	Spec    types.SessionTemplateSpec `json:"spec"`
+}
+
+func dbSessionTemplateToAPI(t db.UnweaveSessionTemplate, v db.UnweaveSessionTemplateVersion) (types.SessionTemplate, error) {
+	spec := SessionTemplateSpecV1{}
+	if err := json.Unmarshal(v.Spec, &spec); err != nil {
+		return types.SessionTemplate{}, fmt.Errorf("failed to unmarshal template spec: %w", err)
+	}
+	return types.SessionTemplate{
+		ID:        t.ID,
+		Name:      t.Name,
+		Version:   int(v.Version),
+		Spec:      spec.Spec,
+		CreatedAt: v.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+	}, nil
+}
+
+func errTemplateNameTaken(name string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return &types.Error{
+			Code:       http.StatusConflict,
+			Message:    fmt.Sprintf("A session template named %q already exists", name),
+			Suggestion: "Update the existing template to create a new version of it",
+		}
+	}
+	return nil
+}
+
+type SessionTemplateService struct {
+	srv *Service
+}
+
+func (t *SessionTemplateService) Create(ctx context.Context, projectID string, params types.SessionTemplateCreateParams) (*types.SessionTemplate, error) {
+	// A template is never visible without its first version.
+	var templateID string
+	err := db.Tx(ctx, func(q db.Querier) error {
+		id, err := q.SessionTemplateCreate(ctx, db.SessionTemplateCreateParams{
+			Name:      params.Name,
+			ProjectID: projectID,
+			CreatedBy: t.srv.cid,
+		})
+		if err != nil {
+			if e := errTemplateNameTaken(params.Name, err); e != nil {
+				return e
+			}
+			return fmt.Errorf("failed to create session template in db: %w", err)
+		}
+		templateID = id
+		return t.createVersion(ctx, q, templateID, 1, params.Spec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.Get(ctx, projectID, templateID, nil)
+}
+
+func (t *SessionTemplateService) createVersion(ctx context.Context, q db.Querier, templateID string, version int32, spec types.SessionTemplateSpec) error {
+	specJSON, err := json.Marshal(SessionTemplateSpecV1{Version: 1, Spec: spec})
+	if err != nil {
+		return fmt.Errorf("failed to marshal template spec: %w", err)
+	}
+	params := db.SessionTemplateVersionCreateParams{
+		TemplateID: templateID,
+		Version:    version,
+		Spec:       specJSON,
+		CreatedBy:  t.srv.cid,
+	}
+	if err = q.SessionTemplateVersionCreate(ctx, params); err != nil {
+		return fmt.Errorf("failed to create session template version in db: %w", err)
+	}
+	return nil
+}
+
+func (t *SessionTemplateService) get(ctx context.Context, projectID, templateID string) (db.UnweaveSessionTemplate, error) {
+	tmpl, err := db.Q.SessionTemplateGet(ctx, templateID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.UnweaveSessionTemplate{}, &types.Error{
+				Code:    http.StatusNotFound,
+				Message: "Session template not found",
+			}
+		}
+		return db.UnweaveSessionTemplate{}, fmt.Errorf("failed to get session template from db: %w", err)
+	}
+	if tmpl.ProjectID != projectID {
+		return db.UnweaveSessionTemplate{}, &types.Error{
+			Code:    http.StatusNotFound,
+			Message: "Session template not found",
+		}
+	}
+	return tmpl, nil
+}
+
+// Get returns a version of a template. If version is nil, the latest version is returned.
+func (t *SessionTemplateService) Get(ctx context.Context, projectID, templateID string, version *int) (*types.SessionTemplate, error) {
+	tmpl, err := t.get(ctx, projectID, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := tmpl.LatestVersion
+	if version != nil {
+		v = int32(*version)
+	}
+	dbv, err := db.Q.SessionTemplateVersionGet(ctx, db.SessionTemplateVersionGetParams{
+		TemplateID: templateID,
+		Version:    v,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &types.Error{
+				Code:       http.StatusNotFound,
+				Message:    fmt.Sprintf("Session template version %d not found", v),
+				Suggestion: fmt.Sprintf("The latest version of this template is %d", tmpl.LatestVersion),
+			}
+		}
+		return nil, fmt.Errorf("failed to get session template version from db: %w", err)
+	}
+
+	res, err := dbSessionTemplateToAPI(tmpl, dbv)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// List returns the latest version of every template in the project.
+func (t *SessionTemplateService) List(ctx context.Context, projectID string) ([]types.SessionTemplate, error) {
+	tmpls, err := db.Q.SessionTemplatesGet(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session templates from db: %w", err)
+	}
+
+	res := make([]types.SessionTemplate, 0, len(tmpls))
+	for _, tmpl := range tmpls {
+		dbv, err := db.Q.SessionTemplateVersionGet(ctx, db.SessionTemplateVersionGetParams{
+			TemplateID: tmpl.ID,
+			Version:    tmpl.LatestVersion,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session template version from db: %w", err)
+		}
+		st, err := dbSessionTemplateToAPI(tmpl, dbv)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+func (t *SessionTemplateService) ListVersions(ctx context.Context, projectID, templateID string) ([]types.SessionTemplate, error) {
+	tmpl, err := t.get(ctx, projectID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := db.Q.SessionTemplateVersionsGet(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session template versions from db: %w", err)
+	}
+
+	res := make([]types.SessionTemplate, 0, len(versions))
+	for _, v := range versions {
+		st, err := dbSessionTemplateToAPI(tmpl, v)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+// Update creates a new version of the template with the given spec. Sessions created from
+// previous versions keep referencing the version they were created with.
+func (t *SessionTemplateService) Update(ctx context.Context, projectID, templateID string, params types.SessionTemplateUpdateParams) (*types.SessionTemplate, error) {
+	tmpl, err := t.get(ctx, projectID, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := tmpl.Name
+	if params.Name != nil {
+		name = *params.Name
+	}
+	// The version is bumped and created together so that the template never points
+	// at a version that doesn't exist.
+	err = db.Tx(ctx, func(q db.Querier) error {
+		version, err := q.SessionTemplateBumpVersion(ctx, db.SessionTemplateBumpVersionParams{
+			ID:   templateID,
+			Name: name,
+		})
+		if err != nil {
+			if e := errTemplateNameTaken(name, err); e != nil {
+				return e
+			}
+			return fmt.Errorf("failed to update session template in db: %w", err)
+		}
+		return t.createVersion(ctx, q, templateID, version, params.Spec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.Get(ctx, projectID, templateID, nil)
+}
+
+// Apply fills the fields of params that aren't set from the template it references. On
+// success, params.TemplateVersion is set to the version that was applied.
+func (t *SessionTemplateService) Apply(ctx context.Context, projectID string, params *types.SessionCreateParams) error {
+	tmpl, err := t.Get(ctx, projectID, *params.TemplateID, params.TemplateVersion)
+	if err != nil {
+		return err
+	}
+	spec := tmpl.Spec
+
+	if params.Provider == "" && spec.Provider != nil {
+		params.Provider = *spec.Provider
+	}
+	if params.NodeTypeID == "" && spec.NodeTypeID != nil {
+		params.NodeTypeID = *spec.NodeTypeID
+	}
+	if params.Region == nil {
+		params.Region = spec.Region
+	}
+	// The key is overridden as a whole, otherwise a name from the template could be
+	// paired with a public key from the request.
+	if params.SSHKeyName == nil && params.SSHPublicKey == nil {
+		params.SSHKeyName = spec.SSHKeyName
+		params.SSHPublicKey = spec.SSHPublicKey
+	}
+	if len(spec.Labels) > 0 {
+		labels := make(map[string]string, len(spec.Labels)+len(params.Labels))
+		for k, v := range spec.Labels {
+			labels[k] = v
+		}
+		for k, v := range params.Labels {
+			labels[k] = v
+		}
+		params.Labels = labels
+	}
+	if params.Readiness == nil {
+		params.Readiness = spec.Readiness
+	}
+	if params.SetupScript == nil {
+		params.SetupScript = spec.SetupScript
+	}
+	params.TemplateVersion = &tmpl.Version
+	return nil
+}
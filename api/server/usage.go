@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+// startSessionUsage opens the usage record of a session whose node was just launched.
+func startSessionUsage(ctx context.Context, sessionID string, params db.SessionCreateParams) error {
+	var gpus int32
+	if specs, err := parseNodeSpecs(params.NodeSpecs); err == nil && specs != nil {
+		gpus = int32(specs.GPUs)
+	}
+	usage := db.SessionUsageStartParams{
+		SessionID:  sessionID,
+		ProjectID:  params.ProjectID,
+		AccountID:  params.CreatedBy,
+		Provider:   params.Provider,
+		NodeTypeID: params.NodeTypeID.String,
+		Price:      params.Price,
+		Labels:     params.Labels,
+		Gpus:       gpus,
+	}
+	if err := db.Q.SessionUsageStart(ctx, usage); err != nil {
+		return fmt.Errorf("failed to start usage of session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
+// endSessionUsage closes the usage record of a session whose node stopped. It is a no-op
+// if the record is already closed.
+func endSessionUsage(ctx context.Context, sessionID string) {
+	if err := db.Q.SessionUsageEnd(ctx, sessionID); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to end usage of session %s", sessionID)
+	}
+}
+
+// monthStart returns the start of the calendar month of t in UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// usageSegment is the part of a usage record that falls within the requested range and,
+// when grouping by month, within a single month.
+type usageSegment struct {
+	start, end time.Time
+}
+
+func usageSegments(u db.UnweaveSessionUsage, from, to, now time.Time, byMonth bool) []usageSegment {
+	start, end := u.StartedAt, now
+	if u.EndedAt.Valid {
+		end = u.EndedAt.Time
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return nil
+	}
+	if !byMonth {
+		return []usageSegment{{start, end}}
+	}
+
+	var segments []usageSegment
+	for start.Before(end) {
+		next := monthStart(start).AddDate(0, 1, 0)
+		if next.After(end) {
+			next = end
+		}
+		segments = append(segments, usageSegment{start, next})
+		start = next
+	}
+	return segments
+}
+
+type usageGroup struct {
+	values   []string
+	sessions map[string]bool
+	hours    float64
+	cost     float64
+}
+
+// aggregateUsage sums the usage of the records within [from, to) by the dimensions in
+// groupBy. Open records accrue up to now.
+func aggregateUsage(records []db.UnweaveSessionUsage, from, to, now time.Time, groupBy []string) ([]types.UsageRow, error) {
+	byMonth := false
+	for _, d := range groupBy {
+		if d == types.UsageByMonth {
+			byMonth = true
+		}
+	}
+
+	groups := map[string]*usageGroup{}
+	for _, u := range records {
+		labels := map[string]string{}
+		if len(u.Labels) > 0 {
+			if err := json.Unmarshal(u.Labels, &labels); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal labels of session %s: %w", u.SessionID, err)
+			}
+		}
+
+		for _, seg := range usageSegments(u, from, to, now, byMonth) {
+			values := make([]string, len(groupBy))
+			for i, d := range groupBy {
+				switch d {
+				case types.UsageByProject:
+					values[i] = u.ProjectID
+				case types.UsageByAccount:
+					values[i] = u.AccountID.String()
+				case types.UsageByProvider:
+					values[i] = u.Provider
+				case types.UsageByNodeType:
+					values[i] = u.NodeTypeID
+				case types.UsageByMonth:
+					values[i] = seg.start.Format("2006-01")
+				default:
+					key, _ := types.UsageLabelKey(d)
+					values[i] = labels[key]
+				}
+			}
+
+			key := strings.Join(values, "\x00")
+			g, ok := groups[key]
+			if !ok {
+				g = &usageGroup{values: values, sessions: map[string]bool{}}
+				groups[key] = g
+			}
+			hours := seg.end.Sub(seg.start).Hours()
+			g.sessions[u.SessionID] = true
+			g.hours += hours
+			if u.Price.Valid {
+				g.cost += hours * float64(u.Price.Int32)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rows := make([]types.UsageRow, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		row := types.UsageRow{
+			Group:     map[string]string{},
+			Sessions:  len(g.sessions),
+			Hours:     g.hours,
+			CostCents: int64(math.Round(g.cost)),
+		}
+		for i, d := range groupBy {
+			row.Group[d] = g.values[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// writeUsageCSV writes the rows of the report with one column per dimension.
+func writeUsageCSV(w io.Writer, report *types.UsageReport) error {
+	cw := csv.NewWriter(w)
+	header := append(append([]string{}, report.GroupBy...), "sessions", "hours", "cost_cents")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		record := make([]string, 0, len(header))
+		for _, d := range report.GroupBy {
+			record = append(record, row.Group[d])
+		}
+		record = append(record,
+			strconv.Itoa(row.Sessions),
+			strconv.FormatFloat(row.Hours, 'f', 4, 64),
+			strconv.FormatInt(row.CostCents, 10),
+		)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type UsageService struct {
+	srv *Service
+}
+
+// Report aggregates the usage of the sessions in the caller's projects.
+func (u *UsageService) Report(ctx context.Context, params types.UsageParams) (*types.UsageReport, error) {
+	records, err := db.Q.SessionUsageList(ctx, db.SessionUsageListParams{
+		OwnerID: u.srv.cid,
+		Until:   params.To,
+		Since:   params.From,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage from db: %w", err)
+	}
+	if params.ProjectID != nil {
+		filtered := records[:0]
+		for _, r := range records {
+			if r.ProjectID == *params.ProjectID {
+				filtered = append(filtered, r)
+			}
+		}
+		records = filtered
+	}
+
+	rows, err := aggregateUsage(records, params.From, params.To, time.Now(), params.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	report := &types.UsageReport{
+		From:    params.From,
+		To:      params.To,
+		GroupBy: params.GroupBy,
+		Rows:    rows,
+	}
+	if report.GroupBy == nil {
+		report.GroupBy = []string{}
+	}
+	for _, row := range rows {
+		report.Hours += row.Hours
+		report.CostCents += row.CostCents
+	}
+	return report, nil
+}
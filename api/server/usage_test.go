@@ -0,0 +1,84 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+)
+
+func TestAggregateUsage(t *testing.T) {
+	from := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 4, 15, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2023, 4, 10, 0, 0, 0, 0, time.UTC)
+	account := uuid.New()
+
+	records := []db.UnweaveSessionUsage{
+		{
+			// Spans the start of the range and the end of March.
+			SessionID:  "ss_1",
+			ProjectID:  "pr_a",
+			AccountID:  account,
+			NodeTypeID: "gpu_1x_a100",
+			Price:      sql.NullInt32{Int32: 110, Valid: true},
+			Labels:     json.RawMessage(`{"team":"research"}`),
+			StartedAt:  time.Date(2023, 3, 14, 0, 0, 0, 0, time.UTC),
+			EndedAt:    sql.NullTime{Time: time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC), Valid: true},
+		},
+		{
+			// Still running, accrues up to now.
+			SessionID:  "ss_2",
+			ProjectID:  "pr_b",
+			AccountID:  account,
+			NodeTypeID: "gpu_1x_a10",
+			Price:      sql.NullInt32{Int32: 60, Valid: true},
+			Labels:     json.RawMessage(`{}`),
+			StartedAt:  time.Date(2023, 4, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// Unknown price.
+			SessionID:  "ss_3",
+			ProjectID:  "pr_a",
+			AccountID:  account,
+			NodeTypeID: "gpu_1x_a100",
+			Labels:     json.RawMessage(`{"team":"research"}`),
+			StartedAt:  time.Date(2023, 4, 2, 0, 0, 0, 0, time.UTC),
+			EndedAt:    sql.NullTime{Time: time.Date(2023, 4, 2, 1, 0, 0, 0, time.UTC), Valid: true},
+		},
+	}
+
+	rows, err := aggregateUsage(records, from, to, now, []string{types.UsageByProject, types.UsageByMonth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.UsageRow{
+		{Group: map[string]string{"project": "pr_a", "month": "2023-03"}, Sessions: 1, Hours: 17 * 24, CostCents: 17 * 24 * 110},
+		{Group: map[string]string{"project": "pr_a", "month": "2023-04"}, Sessions: 2, Hours: 13, CostCents: 12 * 110},
+		{Group: map[string]string{"project": "pr_b", "month": "2023-04"}, Sessions: 1, Hours: 24, CostCents: 24 * 60},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %d: %+v", len(want), len(rows), rows)
+	}
+	for i := range want {
+		got, w := rows[i], want[i]
+		if got.Group["project"] != w.Group["project"] || got.Group["month"] != w.Group["month"] ||
+			got.Sessions != w.Sessions || got.Hours != w.Hours || got.CostCents != w.CostCents {
+			t.Errorf("row %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+
+	rows, err = aggregateUsage(records, from, to, now, []string{"label:team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Group["label:team"] != "" || rows[1].Group["label:team"] != "research" {
+		t.Fatalf("unexpected rows grouped by label: %+v", rows)
+	}
+	if rows[1].Sessions != 2 {
+		t.Errorf("expected 2 sessions with label, got %d", rows[1].Sessions)
+	}
+}
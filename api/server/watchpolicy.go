@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/runtime"
+)
+
+var defaultWatchPolicy = types.WatchPolicy{
+	MaxRetries:         5,
+	BackoffSeconds:     5,
+	MaxBackoffSeconds:  5 * 60,
+	GracePeriodSeconds: 10 * 60,
+	Action:             types.WatchActionTerminate,
+}
+
+// WatchPolicyV1 versions the watch policy stored in the DB.
+type WatchPolicyV1 struct {
+	Version int               `json:"version"`
+	Policy  types.WatchPolicy `json:"policy"`
+}
+
+// parseWatchPolicy decodes the watch policy of a project. Projects that haven't set a
+// policy get the default one.
+func parseWatchPolicy(data json.RawMessage) (types.WatchPolicy, error) {
+	p := WatchPolicyV1{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &p); err != nil {
+			return types.WatchPolicy{}, fmt.Errorf("failed to unmarshal watch policy: %w", err)
+		}
+	}
+	if p.Version == 0 {
+		return defaultWatchPolicy, nil
+	}
+	return p.Policy, nil
+}
+
+func recordWatchDecision(ctx context.Context, sessionID string, d types.WatchDecision) {
+	log.Ctx(ctx).Warn().
+		Str("action", string(d.Action)).
+		Int("attempt", d.Attempt).
+		Msg(d.Reason)
+
+	params := db.SessionWatchDecisionAddParams{
+		SessionID: sessionID,
+		Action:    string(d.Action),
+		Reason:    d.Reason,
+		Attempt:   int32(d.Attempt),
+	}
+	if d.Error != nil {
+		params.Error = sql.NullString{String: *d.Error, Valid: true}
+	}
+	if err := db.Q.SessionWatchDecisionAdd(ctx, params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to record watch decision")
+	}
+}
+
+// watchRecovery is the outcome of handling a watch error.
+type watchRecovery int
+
+const (
+	// watchRecovered means the node status could be fetched again.
+	watchRecovered watchRecovery = iota
+	// watchDegraded means the session was degraded and should still be watched.
+	watchDegraded
+	// watchStop means the session was terminated or the watch cancelled.
+	watchStop
+)
+
+// handleWatchError applies the project's watch policy after the status of a session's
+// node couldn't be fetched. It retries fetching the status until the policy gives up and
+// then either terminates the node or degrades the session.
+//
+// A session that has already been degraded by the policy is only probed once per error
+// so that a long provider outage doesn't flood the decision log.
+func (s *SessionService) handleWatchError(
+	ctx context.Context,
+	rt runtime.Session,
+	sess db.UnweaveSession,
+	watchErr error,
+	degraded bool,
+) (types.SessionStatus, watchRecovery) {
+	policy, err := s.srv.Project.WatchPolicy(ctx, sess.ProjectID)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get watch policy, using the default")
+		policy = defaultWatchPolicy
+	}
+
+	if degraded {
+		status, err := rt.NodeStatus(ctx, sess.NodeID)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Node status is still unavailable")
+			return "", watchDegraded
+		}
+		recordWatchDecision(ctx, sess.ID, types.WatchDecision{
+			Action: types.WatchDecisionRecovered,
+			Reason: fmt.Sprintf("Node status %q is available again", status),
+		})
+		return status, watchRecovered
+	}
+
+	errMsg := watchErr.Error()
+	first := time.Now()
+	grace := time.Duration(policy.GracePeriodSeconds) * time.Second
+	backoff := time.Duration(policy.BackoffSeconds) * time.Second
+	maxBackoff := time.Duration(policy.MaxBackoffSeconds) * time.Second
+
+	attempt := 1
+	for ; attempt <= policy.MaxRetries || time.Since(first) < grace; attempt++ {
+		reason := fmt.Sprintf("Retrying to fetch the node status in %s (attempt %d of %d)",
+			backoff, attempt, policy.MaxRetries)
+		if attempt > policy.MaxRetries {
+			reason = fmt.Sprintf("Retrying to fetch the node status in %s until the %s grace period ends",
+				backoff, grace)
+		}
+		recordWatchDecision(ctx, sess.ID, types.WatchDecision{
+			Action:  types.WatchDecisionRetry,
+			Reason:  reason,
+			Attempt: attempt,
+			Error:   &errMsg,
+		})
+
+		select {
+		case <-ctx.Done():
+			return "", watchStop
+		case <-time.After(backoff):
+		}
+
+		status, err := rt.NodeStatus(ctx, sess.NodeID)
+		if err == nil {
+			recordWatchDecision(ctx, sess.ID, types.WatchDecision{
+				Action:  types.WatchDecisionRecovered,
+				Reason:  fmt.Sprintf("Fetched node status %q after %d retries", status, attempt),
+				Attempt: attempt,
+			})
+			return status, watchRecovered
+		}
+		errMsg = err.Error()
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	retries := attempt - 1
+	reason := fmt.Sprintf("Node status unavailable for %s after %d retries",
+		time.Since(first).Round(time.Second), retries)
+
+	if policy.Action == types.WatchActionDegrade {
+		recordWatchDecision(ctx, sess.ID, types.WatchDecision{
+			Action:  types.WatchDecisionDegrade,
+			Reason:  reason + ", marking the session as degraded",
+			Attempt: retries,
+			Error:   &errMsg,
+		})
+		params := db.SessionStatusUpdateParams{ID: sess.ID, Status: db.UnweaveSessionStatusDegraded}
+		if err := setSessionStatus(ctx, params); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to set session as degraded")
+		}
+		return "", watchDegraded
+	}
+
+	// Play it safe and terminate the node. The user loses their work but the alternative
+	// is a runaway node that drains all their credit.
+	recordWatchDecision(ctx, sess.ID, types.WatchDecision{
+		Action:  types.WatchDecisionTerminate,
+		Reason:  reason + ", terminating the node",
+		Attempt: retries,
+		Error:   &errMsg,
+	})
+	handleSessionError(ctx, sess.ID, watchErr, "Failed to watch session")
+	if err := s.Terminate(ctx, sess.ID, TerminateOptions{}); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to terminate session on failure to watch")
+	}
+	return "", watchStop
+}
+
+func (s *SessionService) WatchDecisions(ctx context.Context, sessionID string) ([]types.WatchDecision, error) {
+	rows, err := db.Q.SessionWatchDecisionsGet(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watch decisions from db: %w", err)
+	}
+	decisions := make([]types.WatchDecision, len(rows))
+	for i, r := range rows {
+		decisions[i] = types.WatchDecision{
+			Action:    types.WatchDecisionAction(r.Action),
+			Reason:    r.Reason,
+			Attempt:   int(r.Attempt),
+			CreatedAt: r.CreatedAt,
+		}
+		if r.Error.Valid {
+			decisions[i].Error = &r.Error.String
+		}
+	}
+	return decisions, nil
+}
@@ -0,0 +1,502 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/events"
+)
+
+var (
+	// webhookPollInterval is how often due deliveries are sent if no event nudged the
+	// dispatcher in the meantime.
+	webhookPollInterval = 5 * time.Second
+	// webhookTimeout is how long endpoints have to respond to a delivery.
+	webhookTimeout = 10 * time.Second
+	// webhookMaxAttempts is how many times a delivery is attempted before it fails.
+	webhookMaxAttempts = 10
+	// webhookMaxBackoff caps the time between attempts.
+	webhookMaxBackoff = 6 * time.Hour
+	// webhookBatchSize is how many deliveries are claimed at a time.
+	webhookBatchSize int32 = 20
+)
+
+const (
+	webhookSignatureHeader = "Unweave-Signature"
+	webhookEventHeader     = "Unweave-Event"
+	webhookDeliveryHeader  = "Unweave-Delivery"
+)
+
+// webhooks is the dispatcher of this replica. It is started when the API starts.
+var webhooks = newWebhookDispatcher()
+
+// webhookBackoff returns how long to wait before the next attempt of a delivery that
+// has failed attempts times.
+func webhookBackoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > webhookMaxBackoff {
+		d = webhookMaxBackoff
+	}
+	return d
+}
+
+// signWebhook returns the signature header of a delivery. Receivers compute the HMAC
+// SHA256 of "<timestamp>.<body>" with the webhook's secret and compare it with v1.
+func signWebhook(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func parseWebhookEvents(data json.RawMessage) []types.EventType {
+	var events []types.EventType
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil
+	}
+	return events
+}
+
+func subscribed(events []types.EventType, event types.EventType) bool {
+	for _, e := range events {
+		if e == event || e == types.EventAll {
+			return true
+		}
+	}
+	return false
+}
+
+// queueWebhookDeliveries queues a delivery of the event to every webhook of the project
+// that is subscribed to it.
+func queueWebhookDeliveries(ctx context.Context, e types.Event) error {
+	hooks, err := db.Q.WebhooksList(ctx, e.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	for _, h := range hooks {
+		if !subscribed(parseWebhookEvents(h.Events), e.Type) {
+			continue
+		}
+		params := db.WebhookDeliveryCreateParams{
+			WebhookID: h.ID,
+			EventID:   e.ID,
+			EventType: string(e.Type),
+			Payload:   payload,
+		}
+		if _, err = db.Q.WebhookDeliveryCreate(ctx, params); err != nil {
+			return fmt.Errorf("failed to queue delivery to webhook %s: %w", h.ID, err)
+		}
+	}
+	return nil
+}
+
+// publishSessionStatus publishes the event of a session moving to status, if there is
+// one for it.
+func publishSessionStatus(ctx context.Context, projectID, sessionID string, status db.UnweaveSessionStatus, errMsg *string) {
+	var event types.EventType
+	switch status {
+	case db.UnweaveSessionStatusProvisioning:
+		event = types.EventSessionProvisioning
+	case db.UnweaveSessionStatusRunning:
+		event = types.EventSessionRunning
+	case db.UnweaveSessionStatusDegraded:
+		event = types.EventSessionDegraded
+	case db.UnweaveSessionStatusTerminated:
+		event = types.EventSessionTerminated
+	case db.UnweaveSessionStatusError:
+		event = types.EventSessionError
+	default:
+		return
+	}
+	publishEvent(ctx, projectID, event, types.SessionEventData{
+		SessionID: sessionID,
+		Status:    types.SessionStatus(status),
+		Error:     errMsg,
+	})
+}
+
+// setSessionStatus updates the status of a session and publishes the change. Sessions
+// stop accruing usage once they terminate. Errored sessions keep accruing since their
+// node may still be up.
+func setSessionStatus(ctx context.Context, params db.SessionStatusUpdateParams) error {
+	sess, err := db.Q.SessionGet(ctx, params.ID)
+	if err != nil {
+		return err
+	}
+	if err = db.Q.SessionStatusUpdate(ctx, params); err != nil {
+		return err
+	}
+	if params.Status == db.UnweaveSessionStatusTerminated {
+		endSessionUsage(ctx, sess.ID)
+	}
+	if sess.Status != params.Status {
+		publishSessionStatus(ctx, sess.ProjectID, sess.ID, params.Status, nil)
+	}
+	return nil
+}
+
+type webhookDispatcher struct {
+	client *http.Client
+	wake   chan struct{}
+}
+
+func newWebhookDispatcher() *webhookDispatcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Endpoints are user supplied, so they must not reach the network the API runs in.
+	// Hosts are checked when connecting rather than when the webhook is created since
+	// what they resolve to can change. Proxies are not used as they would dial instead.
+	transport.Proxy = nil
+	transport.DialContext = webhookDialContext(&net.Dialer{Timeout: webhookTimeout})
+	return &webhookDispatcher{
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: transport,
+			// A redirect could point anywhere, so the response is recorded as is.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not routable on the
+// internet but isn't covered by net.IP.IsPrivate.
+var sharedAddressSpace = net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is a unicast address routable on the internet.
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() &&
+		!ip.IsPrivate() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// webhookDialContext resolves the host and connects to the first of its addresses. It
+// fails if any of the addresses isn't public so that endpoints can't target internal
+// services.
+func webhookDialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no addresses found for host %q", host)
+		}
+		for _, ip := range ips {
+			if !isPublicIP(ip.IP) {
+				return nil, fmt.Errorf("host %q resolves to non-public address %s", host, ip.IP)
+			}
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+	}
+}
+
+// nudge makes the dispatcher send due deliveries straight away.
+func (d *webhookDispatcher) nudge() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends due deliveries until the context is cancelled. Deliveries are claimed in
+// the db so that every replica can run a dispatcher. Events published on any replica
+// wake the dispatcher up.
+func (d *webhookDispatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	sub := eventBus.Subscribe(events.Filter{})
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		case <-sub.C:
+		}
+
+		for {
+			deliveries, err := db.Q.WebhookDeliveriesClaim(ctx, webhookBatchSize)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("Failed to claim webhook deliveries")
+				break
+			}
+			var wg sync.WaitGroup
+			for _, del := range deliveries {
+				wg.Add(1)
+				go func(del db.UnweaveWebhookDelivery) {
+					defer wg.Done()
+					d.deliver(ctx, del)
+				}(del)
+			}
+			wg.Wait()
+
+			if len(deliveries) < int(webhookBatchSize) {
+				break
+			}
+		}
+	}
+}
+
+// deliver attempts to send a delivery and records the outcome.
+func (d *webhookDispatcher) deliver(ctx context.Context, del db.UnweaveWebhookDelivery) {
+	ctx = log.Ctx(ctx).With().Str("webhookDelivery", del.ID).Logger().WithContext(ctx)
+
+	hook, err := db.Q.WebhookGet(ctx, del.WebhookID)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get webhook")
+		return
+	}
+
+	code, err := d.send(ctx, hook, del)
+	params := db.WebhookDeliveryAttemptParams{
+		ID:             del.ID,
+		Status:         db.UnweaveWebhookDeliveryStatusSucceeded,
+		NextAttemptAt:  time.Now(),
+		LastStatusCode: sql.NullInt32{Int32: int32(code), Valid: code != 0},
+	}
+	if err != nil {
+		attempts := int(del.Attempts) + 1
+		params.LastError = sql.NullString{String: err.Error(), Valid: true}
+		params.Status = db.UnweaveWebhookDeliveryStatusPending
+		params.NextAttemptAt = time.Now().Add(webhookBackoff(attempts))
+		if attempts >= webhookMaxAttempts {
+			params.Status = db.UnweaveWebhookDeliveryStatusFailed
+		}
+		log.Ctx(ctx).Warn().Err(err).Msgf("Webhook delivery attempt %d failed", attempts)
+	}
+	if err = db.Q.WebhookDeliveryAttempt(ctx, params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to record webhook delivery attempt")
+	}
+}
+
+func (d *webhookDispatcher) send(ctx context.Context, hook db.UnweaveWebhook, del db.UnweaveWebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Unweave-Webhooks")
+	req.Header.Set(webhookEventHeader, del.EventType)
+	req.Header.Set(webhookDeliveryHeader, del.ID)
+	req.Header.Set(webhookSignatureHeader, signWebhook(hook.Secret, time.Now(), del.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("endpoint responded with %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+type WebhookService struct {
+	srv *Service
+}
+
+func webhookFromDB(h db.UnweaveWebhook) types.Webhook {
+	return types.Webhook{
+		ID:        h.ID,
+		URL:       h.Url,
+		Events:    parseWebhookEvents(h.Events),
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+func webhookDeliveryFromDB(d db.UnweaveWebhookDelivery) types.WebhookDelivery {
+	res := types.WebhookDelivery{
+		ID:        d.ID,
+		EventID:   d.EventID,
+		EventType: types.EventType(d.EventType),
+		Status:    types.WebhookDeliveryStatus(d.Status),
+		Attempts:  int(d.Attempts),
+		CreatedAt: d.CreatedAt,
+	}
+	if d.Status == db.UnweaveWebhookDeliveryStatusPending {
+		res.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.LastAttemptAt.Valid {
+		res.LastAttemptAt = &d.LastAttemptAt.Time
+	}
+	if d.LastStatusCode.Valid {
+		code := int(d.LastStatusCode.Int32)
+		res.LastStatusCode = &code
+	}
+	if d.LastError.Valid {
+		res.LastError = &d.LastError.String
+	}
+	return res
+}
+
+func (w *WebhookService) get(ctx context.Context, projectID, webhookID string) (db.UnweaveWebhook, error) {
+	hook, err := db.Q.WebhookGet(ctx, webhookID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.UnweaveWebhook{}, &types.Error{
+				Code:    http.StatusNotFound,
+				Message: "Webhook not found",
+			}
+		}
+		return db.UnweaveWebhook{}, fmt.Errorf("failed to get webhook from db: %w", err)
+	}
+	if hook.ProjectID != projectID {
+		return db.UnweaveWebhook{}, &types.Error{
+			Code:    http.StatusNotFound,
+			Message: "Webhook not found",
+		}
+	}
+	return hook, nil
+}
+
+// Create registers a webhook. The returned webhook includes the secret its deliveries
+// are signed with, which can't be retrieved later.
+func (w *WebhookService) Create(ctx context.Context, projectID string, params types.WebhookCreateParams) (*types.Webhook, error) {
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	events, err := json.Marshal(params.Events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook events: %w", err)
+	}
+	id, err := db.Q.WebhookCreate(ctx, db.WebhookCreateParams{
+		ProjectID: projectID,
+		Url:       params.URL,
+		Secret:    secret,
+		Events:    events,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook in db: %w", err)
+	}
+	hook, err := w.get(ctx, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := webhookFromDB(hook)
+	res.Secret = &secret
+	return &res, nil
+}
+
+func (w *WebhookService) Get(ctx context.Context, projectID, webhookID string) (*types.Webhook, error) {
+	hook, err := w.get(ctx, projectID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	res := webhookFromDB(hook)
+	return &res, nil
+}
+
+func (w *WebhookService) List(ctx context.Context, projectID string) ([]types.Webhook, error) {
+	hooks, err := db.Q.WebhooksList(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks from db: %w", err)
+	}
+	res := make([]types.Webhook, 0, len(hooks))
+	for _, h := range hooks {
+		res = append(res, webhookFromDB(h))
+	}
+	return res, nil
+}
+
+func (w *WebhookService) Delete(ctx context.Context, projectID, webhookID string) error {
+	if _, err := w.get(ctx, projectID, webhookID); err != nil {
+		return err
+	}
+	if err := db.Q.WebhookDelete(ctx, webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// Deliveries returns the latest deliveries of the webhook.
+func (w *WebhookService) Deliveries(ctx context.Context, projectID, webhookID string) ([]types.WebhookDelivery, error) {
+	if _, err := w.get(ctx, projectID, webhookID); err != nil {
+		return nil, err
+	}
+	rows, err := db.Q.WebhookDeliveriesList(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries from db: %w", err)
+	}
+	res := make([]types.WebhookDelivery, 0, len(rows))
+	for _, d := range rows {
+		res = append(res, webhookDeliveryFromDB(d))
+	}
+	return res, nil
+}
+
+// Redeliver queues a delivery to be sent again with a fresh set of attempts.
+func (w *WebhookService) Redeliver(ctx context.Context, projectID, webhookID, deliveryID string) (*types.WebhookDelivery, error) {
+	if _, err := w.get(ctx, projectID, webhookID); err != nil {
+		return nil, err
+	}
+	del, err := db.Q.WebhookDeliveryGet(ctx, deliveryID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get webhook delivery from db: %w", err)
+	}
+	if err == sql.ErrNoRows || del.WebhookID != webhookID {
+		return nil, &types.Error{
+			Code:    http.StatusNotFound,
+			Message: "Webhook delivery not found",
+		}
+	}
+	if err = db.Q.WebhookDeliveryRedeliver(ctx, deliveryID); err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	webhooks.nudge()
+
+	if del, err = db.Q.WebhookDeliveryGet(ctx, deliveryID); err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery from db: %w", err)
+	}
+	res := webhookDeliveryFromDB(del)
+	return &res, nil
+}
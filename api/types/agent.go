@@ -0,0 +1,47 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/unweave/unweave/agent/protocol"
+)
+
+// SessionAgentCommandParams is a command to run through the node agent of a session.
+type SessionAgentCommandParams struct {
+	Kind     protocol.CommandKind      `json:"kind"`
+	Exec     *protocol.ExecCommand     `json:"exec,omitempty"`
+	FilePush *protocol.FilePushCommand `json:"filePush,omitempty"`
+}
+
+func (p *SessionAgentCommandParams) Bind(r *http.Request) error {
+	switch p.Kind {
+	case protocol.CommandExec:
+		if p.Exec == nil || len(p.Exec.Args) == 0 {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid request body: field 'exec.args' is required",
+			}
+		}
+	case protocol.CommandFilePush:
+		if p.FilePush == nil || p.FilePush.Path == "" {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid request body: field 'filePush.path' is required",
+			}
+		}
+	case protocol.CommandShutdown:
+	default:
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid request body: unsupported command kind %q", p.Kind),
+			Suggestion: fmt.Sprintf("Valid kinds are: %s, %s, %s",
+				protocol.CommandExec, protocol.CommandFilePush, protocol.CommandShutdown),
+		}
+	}
+	return nil
+}
+
+type SessionAgentCommandResponse struct {
+	Result protocol.CommandResult `json:"result"`
+}
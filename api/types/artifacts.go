@@ -0,0 +1,62 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// ArtifactSpec declares the paths on the node that are collected into the artifact store
+// before the session is terminated, e.g. checkpoints or an outputs directory.
+type ArtifactSpec struct {
+	// Paths are files or directories on the node. Relative paths are relative to the home
+	// directory of the node's user. Directories are collected recursively.
+	Paths []string `json:"paths"`
+	// TimeoutSeconds is how long collection can take before the session is terminated
+	// anyway. Defaults to 10 minutes.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+func (a *ArtifactSpec) Validate() error {
+	if a.TimeoutSeconds < 0 || a.TimeoutSeconds > 3600 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'artifacts.timeoutSeconds' must be between 0 and 3600",
+		}
+	}
+	for i, p := range a.Paths {
+		if strings.TrimSpace(p) == "" || path.Clean(p) == "/" || hasDotDot(p) {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid request body: artifact path %d is invalid", i),
+			}
+		}
+	}
+	return nil
+}
+
+// hasDotDot returns true if any segment of p is "..".
+func hasDotDot(p string) bool {
+	for _, s := range strings.Split(p, "/") {
+		if s == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+type Artifact struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionID"`
+	// Path is the path of the file on the node.
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ArtifactsListResponse struct {
+	Artifacts []Artifact `json:"artifacts"`
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"net/http"
+)
+
+// DefaultBudgetThresholds are the percentages of a budget that are alerted on if a
+// budget doesn't set its own.
+var DefaultBudgetThresholds = []int{50, 80, 100}
+
+// ProjectBudget limits the monthly spend of a project. Spend is the usage the project's
+// sessions accrued since the start of the calendar month in UTC.
+type ProjectBudget struct {
+	// MonthlyLimitCents is the budget in US cents. A limit of zero disables the budget.
+	MonthlyLimitCents int64 `json:"monthlyLimitCents"`
+	// Thresholds are the percentages of the budget at which a budget.threshold event is
+	// published. They default to DefaultBudgetThresholds.
+	Thresholds []int `json:"thresholds,omitempty"`
+	// HardLimit blocks new sessions once the budget is spent.
+	HardLimit bool `json:"hardLimit"`
+	// TerminateOnExceeded terminates the running sessions of the project once the
+	// budget is spent. It requires HardLimit.
+	TerminateOnExceeded bool `json:"terminateOnExceeded"`
+}
+
+func (b *ProjectBudget) Bind(r *http.Request) error {
+	return b.Validate()
+}
+
+func (b *ProjectBudget) Validate() error {
+	if b.MonthlyLimitCents < 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'monthlyLimitCents' must not be negative",
+		}
+	}
+	for _, t := range b.Thresholds {
+		if t < 1 || t > 1000 {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid request body: field 'thresholds' must be percentages between 1 and 1000",
+			}
+		}
+	}
+	if b.TerminateOnExceeded && !b.HardLimit {
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    "Invalid request body: field 'terminateOnExceeded' requires 'hardLimit'",
+			Suggestion: "Set 'hardLimit' to true to terminate sessions once the budget is spent",
+		}
+	}
+	return nil
+}
+
+type ProjectBudgetResponse struct {
+	Budget ProjectBudget `json:"budget"`
+	// Month is the calendar month the spend is for, e.g. 2023-04.
+	Month      string `json:"month"`
+	SpentCents int64  `json:"spentCents"`
+	Exceeded   bool   `json:"exceeded"`
+}
+
+type BudgetEventData struct {
+	Month      string `json:"month"`
+	Threshold  int    `json:"threshold"`
+	SpentCents int64  `json:"spentCents"`
+	LimitCents int64  `json:"limitCents"`
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxClusterSize caps the number of nodes a single cluster can launch.
+const maxClusterSize = 64
+
+type ClusterCreateParams struct {
+	Name *string `json:"name,omitempty"`
+	// Size is the number of nodes in the cluster.
+	Size         int               `json:"size"`
+	Provider     RuntimeProvider   `json:"provider"`
+	NodeTypeID   string            `json:"nodeTypeID"`
+	Region       *string           `json:"region,omitempty"`
+	SSHKeyName   *string           `json:"sshKeyName"`
+	SSHPublicKey *string           `json:"sshPublicKey"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Readiness    *ReadinessProbes  `json:"readiness,omitempty"`
+}
+
+func (c *ClusterCreateParams) Bind(r *http.Request) error {
+	if c.Provider == "" {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'provider' is required",
+		}
+	}
+	if c.NodeTypeID == "" {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'nodeTypeID' is required",
+		}
+	}
+	if c.SSHPublicKey == nil && c.SSHKeyName == nil {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: either 'sshKeyName' or 'sshPublicKey' is required",
+		}
+	}
+	if c.Size < 1 || c.Size > maxClusterSize {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid request body: field 'size' must be between 1 and %d", maxClusterSize),
+		}
+	}
+	if c.Readiness != nil {
+		return c.Readiness.Validate()
+	}
+	return nil
+}
+
+// ClusterMember is a session running on one of the nodes of a cluster. Ranks are
+// contiguous and start at 0.
+type ClusterMember struct {
+	Rank       int             `json:"rank"`
+	SessionID  string          `json:"sessionID"`
+	Status     SessionStatus   `json:"status"`
+	Connection *ConnectionInfo `json:"connection,omitempty"`
+}
+
+type Cluster struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Status     SessionStatus   `json:"status"`
+	Provider   RuntimeProvider `json:"provider"`
+	NodeTypeID string          `json:"nodeTypeID"`
+	Region     string          `json:"region"`
+	Size       int             `json:"size"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Error      *string         `json:"error,omitempty"`
+	Members    []ClusterMember `json:"members"`
+	// Hostfile lists the host of each member, one per line in rank order. It is only set
+	// once all members are running.
+	Hostfile *string `json:"hostfile,omitempty"`
+}
+
+type ClusterGetResponse struct {
+	Cluster Cluster `json:"cluster"`
+}
+
+type ClustersListResponse struct {
+	Clusters []Cluster `json:"clusters"`
+}
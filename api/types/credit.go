@@ -0,0 +1,93 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreditTransactionKind string
+
+const (
+	// CreditTopUp adds credit to an account, e.g. an allocation to a research group.
+	CreditTopUp CreditTransactionKind = "top_up"
+	// CreditUsage debits the usage of a session's node.
+	CreditUsage CreditTransactionKind = "usage"
+	// CreditAdjustment corrects the balance of an account in either direction.
+	CreditAdjustment CreditTransactionKind = "adjustment"
+)
+
+// FormatCredit formats an amount in US cents as US dollars, e.g. 1234 as "12.34".
+func FormatCredit(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+type CreditTransaction struct {
+	ID   string                `json:"id"`
+	Kind CreditTransactionKind `json:"kind"`
+	// AmountCents is the change of the account's balance in US cents. Debits are
+	// negative.
+	AmountCents int64     `json:"amountCents"`
+	Description string    `json:"description"`
+	SessionID   *string   `json:"sessionID,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type CreditTransactionCreateParams struct {
+	Kind        CreditTransactionKind `json:"kind"`
+	AmountCents int64                 `json:"amountCents"`
+	Description string                `json:"description"`
+}
+
+func (p *CreditTransactionCreateParams) Bind(r *http.Request) error {
+	switch p.Kind {
+	case CreditTopUp:
+		if p.AmountCents <= 0 {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid request body: field 'amountCents' of a top up must be positive",
+			}
+		}
+	case CreditAdjustment:
+		if p.AmountCents == 0 {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid request body: field 'amountCents' must not be zero",
+			}
+		}
+	default:
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    fmt.Sprintf("Invalid request body: invalid transaction kind %q", p.Kind),
+			Suggestion: fmt.Sprintf("Use %q or %q, usage is debited automatically", CreditTopUp, CreditAdjustment),
+		}
+	}
+	return nil
+}
+
+type CreditBalance struct {
+	AccountID    uuid.UUID `json:"accountID"`
+	BalanceCents int64     `json:"balanceCents"`
+	// Credit is the balance in US dollars, e.g. "12.34".
+	Credit string `json:"credit"`
+}
+
+type CreditBalanceResponse struct {
+	Balance CreditBalance `json:"balance"`
+}
+
+type CreditTransactionResponse struct {
+	Transaction CreditTransaction `json:"transaction"`
+	Balance     CreditBalance     `json:"balance"`
+}
+
+type CreditTransactionsListResponse struct {
+	Transactions []CreditTransaction `json:"transactions"`
+}
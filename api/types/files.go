@@ -0,0 +1,138 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+const maxFilesArchiveMemory = 1024 * 1024 * 32 // 32MB, larger archives are buffered on disk
+
+type ArchiveFormat string
+
+const (
+	ArchiveTar   ArchiveFormat = "tar"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// ArchiveFormatFromName detects the format of an archive from its file name.
+func ArchiveFormatFromName(name string) (ArchiveFormat, bool) {
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveTar, true
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz, true
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip, true
+	}
+	return "", false
+}
+
+// FileEntry is a regular file in a FileManifest. Path is relative to the manifest's path.
+type FileEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+type FileManifest struct {
+	Path  string      `json:"path"`
+	Files []FileEntry `json:"files"`
+}
+
+type SessionFilesManifestResponse struct {
+	Manifest FileManifest `json:"manifest"`
+}
+
+type SessionFilesUploadParams struct {
+	// Path is the directory the archive is extracted to. Relative paths are relative to
+	// the home directory of the node's user.
+	Path string `json:"path"`
+	// Sync only writes the files of the archive whose content differs from the node's.
+	Sync bool `json:"sync,omitempty"`
+	// Delete removes the files under Path that aren't part of the sync. It requires Sync.
+	Delete bool `json:"delete,omitempty"`
+	// Manifest lists all files of the sync when the archive only contains the changed
+	// ones, e.g. after comparing against GET .../files/manifest. Files in the manifest
+	// are kept by Delete even if they are missing from the archive.
+	Manifest []FileEntry `json:"manifest,omitempty"`
+
+	Archive multipart.File `json:"-"`
+	Format  ArchiveFormat  `json:"-"`
+}
+
+func (p *SessionFilesUploadParams) Bind(r *http.Request) error {
+	if err := r.ParseMultipartForm(maxFilesArchiveMemory); err != nil {
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    "Failed to parse multipart form",
+			Suggestion: "Send the params as a form field called 'params' and the archive as a file called 'archive'",
+			Err:        err,
+		}
+	}
+	if err := json.Unmarshal([]byte(r.FormValue("params")), p); err != nil {
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    "Failed to parse request body",
+			Suggestion: "Make sure the 'params' form field is valid JSON",
+			Err:        err,
+		}
+	}
+	if strings.TrimSpace(p.Path) == "" {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'path' is required",
+		}
+	}
+	if p.Delete && !p.Sync {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'delete' requires 'sync'",
+		}
+	}
+	if len(p.Manifest) > 0 && !p.Sync {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'manifest' requires 'sync'",
+		}
+	}
+
+	files := r.MultipartForm.File["archive"]
+	if len(files) != 1 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Expected exactly one file called 'archive'",
+		}
+	}
+	format, ok := ArchiveFormatFromName(files[0].Filename)
+	if !ok {
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    fmt.Sprintf("Unsupported archive %q", files[0].Filename),
+			Suggestion: "Supported archives are .tar, .tar.gz, .tgz and .zip",
+		}
+	}
+	archive, err := files[0].Open()
+	if err != nil {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Failed to open archive",
+			Err:     err,
+		}
+	}
+	p.Archive = archive
+	p.Format = format
+	return nil
+}
+
+type SessionFilesUploadResponse struct {
+	Path string `json:"path"`
+	// Written is the number of files written to the node.
+	Written int `json:"written"`
+	// Skipped is the number of files that were already up to date.
+	Skipped int   `json:"skipped"`
+	Deleted int   `json:"deleted"`
+	Bytes   int64 `json:"bytes"`
+}
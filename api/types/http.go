@@ -86,14 +86,46 @@ type NodeTypesListResponse struct {
 }
 
 type SessionCreateParams struct {
-	Provider     RuntimeProvider `json:"provider"`
-	NodeTypeID   string          `json:"nodeTypeID,omitempty"`
-	Region       *string         `json:"region,omitempty"`
-	SSHKeyName   *string         `json:"sshKeyName"`
-	SSHPublicKey *string         `json:"sshPublicKey"`
+	Provider     RuntimeProvider   `json:"provider"`
+	NodeTypeID   string            `json:"nodeTypeID,omitempty"`
+	Region       *string           `json:"region,omitempty"`
+	SSHKeyName   *string           `json:"sshKeyName"`
+	SSHPublicKey *string           `json:"sshPublicKey"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	// TemplateID is the session template to create the session from. Fields set in the
+	// request override the template's.
+	TemplateID *string `json:"templateID,omitempty"`
+	// TemplateVersion pins the template version to use. Defaults to the latest version.
+	TemplateVersion *int `json:"templateVersion,omitempty"`
+	// Readiness configures the probes the node has to pass before the session is running.
+	Readiness *ReadinessProbes `json:"readiness,omitempty"`
+	// Artifacts are collected from the node before the session is terminated.
+	Artifacts *ArtifactSpec `json:"artifacts,omitempty"`
+	// SetupScript is run with bash once the node is reachable over SSH. The session is
+	// provisioning until it exits and errors if it fails. Defaults to the project's.
+	SetupScript *string `json:"setupScript,omitempty"`
+	// TerminateOnSetupFailure terminates the node if the setup script fails. Otherwise
+	// the node is left running so that it can be debugged.
+	TerminateOnSetupFailure *bool `json:"terminateOnSetupFailure,omitempty"`
 }
 
 func (s *SessionCreateParams) Bind(r *http.Request) error {
+	if s.TemplateVersion != nil && s.TemplateID == nil {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'templateVersion' requires 'templateID'",
+		}
+	}
+	// Missing fields might be set by the template, so they are validated once the
+	// template has been applied.
+	if s.TemplateID != nil {
+		return nil
+	}
+	return s.Validate()
+}
+
+// Validate checks that all fields required to launch a session are set.
+func (s *SessionCreateParams) Validate() error {
 	if s.Provider == "" {
 		return &Error{
 			Code:    http.StatusBadRequest,
@@ -106,9 +138,33 @@ func (s *SessionCreateParams) Bind(r *http.Request) error {
 			Message: "Invalid request body: either 'sshKeyName' or 'sshPublicKey' is required",
 		}
 	}
+	if s.Readiness != nil {
+		if err := s.Readiness.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Artifacts != nil {
+		return s.Artifacts.Validate()
+	}
 	return nil
 }
 
+// SessionCreateDryRunResponse describes the session a create request would launch.
+type SessionCreateDryRunResponse struct {
+	DryRun     bool            `json:"dryRun"`
+	Provider   RuntimeProvider `json:"provider"`
+	NodeTypeID string          `json:"nodeTypeID"`
+	// Region is the region the node would be launched in.
+	Region     string    `json:"region,omitempty"`
+	SSHKeyName string    `json:"sshKeyName"`
+	Specs      NodeSpecs `json:"specs"`
+	// PricePerHourCents is the hourly price of the node in US cents, if known.
+	PricePerHourCents *int `json:"pricePerHourCents,omitempty"`
+	// DurationSeconds is the runtime the cost is estimated for, if one was requested.
+	DurationSeconds    *int64 `json:"durationSeconds,omitempty"`
+	EstimatedCostCents *int64 `json:"estimatedCostCents,omitempty"`
+}
+
 type ProviderConnectParams struct {
 	Provider      RuntimeProvider `json:"provider"`
 	ProviderToken string          `json:"providerToken,omitempty"`
@@ -132,6 +188,24 @@ type SessionGetResponse struct {
 	Session Session `json:"session"`
 }
 
+type SessionExposePortParams struct {
+	Port int `json:"port"`
+}
+
+func (p *SessionExposePortParams) Bind(r *http.Request) error {
+	if p.Port <= 0 || p.Port > 65535 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'port' must be between 1 and 65535",
+		}
+	}
+	return nil
+}
+
+type SessionExposePortResponse struct {
+	ExposedPort ExposedPort `json:"exposedPort"`
+}
+
 type SessionsListResponse struct {
 	Sessions []Session `json:"sessions"`
 }
@@ -41,7 +41,6 @@ type Account struct {
 	GithubID       int32     `json:"githubID"`
 	GithubUsername string    `json:"githubUsername"`
 	DateJoined     time.Time `json:"dateJoined"`
-	Credit         string    `json:"credit"`
 	FirstName      string    `json:"firstName"`
 	LastName       string    `json:"lastName"`
 	Providers      []string  `json:"providers"`
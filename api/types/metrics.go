@@ -0,0 +1,30 @@
+package types
+
+import "time"
+
+type GPUMetrics struct {
+	Index              int     `json:"index"`
+	UtilizationPercent float64 `json:"utilizationPercent"`
+	MemoryUsedBytes    int64   `json:"memoryUsedBytes"`
+	MemoryTotalBytes   int64   `json:"memoryTotalBytes"`
+}
+
+// MetricsSample is the resource utilization of a session's node at a point in time. When
+// samples are downsampled, the values are averages over the step starting at Time.
+type MetricsSample struct {
+	Time             time.Time    `json:"time"`
+	CPUPercent       float64      `json:"cpuPercent"`
+	MemoryUsedBytes  int64        `json:"memoryUsedBytes"`
+	MemoryTotalBytes int64        `json:"memoryTotalBytes"`
+	DiskUsedBytes    int64        `json:"diskUsedBytes"`
+	DiskTotalBytes   int64        `json:"diskTotalBytes"`
+	GPUs             []GPUMetrics `json:"gpus"`
+}
+
+type SessionMetricsResponse struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Step is the resolution of the samples in seconds.
+	Step    int             `json:"step"`
+	Samples []MetricsSample `json:"samples"`
+}
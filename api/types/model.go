@@ -11,9 +11,14 @@ type SessionStatus string
 const (
 	RuntimeProviderKey               = "RuntimeProvider"
 	StatusInitializing SessionStatus = "initializing"
+	// StatusProvisioning means the provider reports the node as running but it hasn't
+	// passed its readiness probes yet.
+	StatusProvisioning SessionStatus = "provisioning"
 	StatusRunning      SessionStatus = "running"
-	StatusTerminated   SessionStatus = "terminated"
-	StatusError        SessionStatus = "error"
+	// StatusDegraded means the node is running but its agent stopped sending heartbeats.
+	StatusDegraded   SessionStatus = "degraded"
+	StatusTerminated SessionStatus = "terminated"
+	StatusError      SessionStatus = "error"
 )
 
 type NoOpLogHook struct{}
@@ -49,6 +54,8 @@ func (r RuntimeProvider) DisplayName() string {
 type LogEntry struct {
 	TimeStamp time.Time `json:"timestamp"`
 	Message   string    `json:"message"`
+	// Stream is either stdout or stderr for the output of commands run on a node.
+	Stream string `json:"stream,omitempty"`
 }
 
 type NodeSpecs struct {
@@ -57,6 +64,8 @@ type NodeSpecs struct {
 	Memory int `json:"memory"`
 	// GPUMemory is the GPU RAM in GB
 	GPUMemory *int `json:"gpuMemory"`
+	// GPUs is the number of GPUs
+	GPUs int `json:"gpus"`
 }
 
 type NodeType struct {
@@ -93,17 +102,39 @@ type ConnectionInfo struct {
 	Host string `json:"host"`
 	Port int    `json:"port"`
 	User string `json:"user"`
+	// HostKey is the node's SSH host public key in the authorized_keys format, if known.
+	HostKey string `json:"hostKey,omitempty"`
 }
 
 type Session struct {
-	ID         string          `json:"id"`
-	SSHKey     SSHKey          `json:"sshKey"`
-	Connection *ConnectionInfo `json:"connection,omitempty"`
-	Status     SessionStatus   `json:"runtimeStatus"`
-	CreatedAt  *time.Time      `json:"createdAt,omitempty"`
-	NodeTypeID string          `json:"nodeTypeID"`
-	Region     string          `json:"region"`
-	Provider   RuntimeProvider `json:"provider"`
+	ID         string            `json:"id"`
+	SSHKey     SSHKey            `json:"sshKey"`
+	Connection *ConnectionInfo   `json:"connection,omitempty"`
+	Status     SessionStatus     `json:"runtimeStatus"`
+	CreatedAt  *time.Time        `json:"createdAt,omitempty"`
+	NodeTypeID string            `json:"nodeTypeID"`
+	Region     string            `json:"region"`
+	Provider   RuntimeProvider   `json:"provider"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	// Template is the session template version the session was created from, if any.
+	Template     *SessionTemplateRef `json:"template,omitempty"`
+	ExposedPorts []ExposedPort       `json:"exposedPorts"`
+	// NodeSpecs and Price are those of the node type when the session was launched. They
+	// are unknown for sessions launched before they were recorded.
+	NodeSpecs *NodeSpecs `json:"nodeSpecs,omitempty"`
+	// Price is the hourly price in US cents.
+	Price *int `json:"price,omitempty"`
+}
+
+// ExposedPort is a port on a session's node that is served over HTTP by the API.
+type ExposedPort struct {
+	Port int `json:"port"`
+	// URL is where the port can be reached. Requests are forwarded with their path
+	// unchanged so the application must be configured to serve from BasePath, e.g.
+	// `jupyter lab --ServerApp.base_url=<basePath>` or `tensorboard --path_prefix=<basePath>`.
+	URL       string    `json:"url"`
+	BasePath  string    `json:"basePath"`
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 type ExecParams struct {
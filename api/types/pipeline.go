@@ -0,0 +1,213 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+const maxPipelineDefinitionSize = 1024 * 1024 // 1MB
+
+type PipelineStatus string
+
+const (
+	PipelinePending   PipelineStatus = "pending"
+	PipelineRunning   PipelineStatus = "running"
+	PipelineSucceeded PipelineStatus = "succeeded"
+	PipelineFailed    PipelineStatus = "failed"
+	PipelineCanceled  PipelineStatus = "canceled"
+)
+
+// IsTerminal returns true if a pipeline, step, sweep or trial with this status will not
+// change status anymore.
+func (s PipelineStatus) IsTerminal() bool {
+	return s == PipelineSucceeded || s == PipelineFailed || s == PipelineCanceled
+}
+
+// PipelineStepParams defines a single step of a pipeline. Each step runs its command on
+// a dedicated node once all the steps it depends on have succeeded.
+type PipelineStepParams struct {
+	Name       string          `json:"name"`
+	DependsOn  []string        `json:"dependsOn,omitempty"`
+	Provider   RuntimeProvider `json:"provider"`
+	NodeTypeID string          `json:"nodeTypeID"`
+	Region     *string         `json:"region,omitempty"`
+	// BuildID is the build whose image the command runs in. If not set, the project's
+	// default build is used. If the project has no default build, the command runs
+	// directly on the node.
+	BuildID *string  `json:"buildID,omitempty"`
+	Command []string `json:"command"`
+	// Artifacts are collected from the node once the command exits.
+	Artifacts *ArtifactSpec `json:"artifacts,omitempty"`
+}
+
+type PipelineCreateParams struct {
+	Name  string               `json:"name"`
+	Steps []PipelineStepParams `json:"steps"`
+	// SSHKeyName or SSHPublicKey is the key the nodes of every step are launched with.
+	SSHKeyName   *string `json:"sshKeyName"`
+	SSHPublicKey *string `json:"sshPublicKey"`
+}
+
+// Bind parses a pipeline definition in either YAML or JSON from the request body and
+// validates that its steps form a directed acyclic graph.
+//
+//	eg. name: train
+//	    sshKeyName: my-key
+//	    steps:
+//	      - name: preprocess
+//	        provider: lambdalabs
+//	        nodeTypeID: gpu_1x_a10
+//	        command: ["python", "preprocess.py"]
+//	      - name: train
+//	        dependsOn: [preprocess]
+//	        provider: lambdalabs
+//	        nodeTypeID: gpu_8x_a100
+//	        command: ["python", "train.py"]
+func (p *PipelineCreateParams) Bind(r *http.Request) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPipelineDefinitionSize))
+	if err != nil {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Failed to read request body",
+			Err:     err,
+		}
+	}
+	if err = yaml.Unmarshal(body, p); err != nil {
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    "Failed to parse pipeline definition",
+			Suggestion: "Make sure the pipeline definition is valid YAML or JSON",
+			Err:        err,
+		}
+	}
+	return p.Validate()
+}
+
+// Validate checks that every step is well-formed, that dependencies refer to existing
+// steps and that there are no dependency cycles.
+func (p *PipelineCreateParams) Validate() error {
+	if p.SSHPublicKey == nil && p.SSHKeyName == nil {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid pipeline: either 'sshKeyName' or 'sshPublicKey' is required",
+		}
+	}
+	if len(p.Steps) == 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid pipeline: at least one step is required",
+		}
+	}
+
+	steps := make(map[string]PipelineStepParams, len(p.Steps))
+	for _, s := range p.Steps {
+		if s.Name == "" {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid pipeline: field 'name' is required for every step",
+			}
+		}
+		if _, ok := steps[s.Name]; ok {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid pipeline: duplicate step %q", s.Name),
+			}
+		}
+		if s.Provider == "" || s.NodeTypeID == "" {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid pipeline: step %q requires 'provider' and 'nodeTypeID'", s.Name),
+			}
+		}
+		if len(s.Command) == 0 {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid pipeline: step %q requires a 'command'", s.Name),
+			}
+		}
+		if s.Artifacts != nil {
+			if err := s.Artifacts.Validate(); err != nil {
+				return err
+			}
+		}
+		steps[s.Name] = s
+	}
+
+	for _, s := range p.Steps {
+		for _, dep := range s.DependsOn {
+			if _, ok := steps[dep]; !ok {
+				return &Error{
+					Code:    http.StatusBadRequest,
+					Message: fmt.Sprintf("Invalid pipeline: step %q depends on unknown step %q", s.Name, dep),
+				}
+			}
+		}
+	}
+
+	// Depth first search for cycles
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(steps))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid pipeline: dependency cycle through step %q", name),
+			}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range steps[name].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, s := range p.Steps {
+		if err := visit(s.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type PipelineStep struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Status     PipelineStatus     `json:"status"`
+	Params     PipelineStepParams `json:"params"`
+	SessionID  *string            `json:"sessionID,omitempty"`
+	ExitCode   *int               `json:"exitCode,omitempty"`
+	StartedAt  *time.Time         `json:"startedAt,omitempty"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Error      *string            `json:"error,omitempty"`
+}
+
+type Pipeline struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Status    PipelineStatus `json:"status"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Error     *string        `json:"error,omitempty"`
+	Steps     []PipelineStep `json:"steps"`
+}
+
+type PipelineGetResponse struct {
+	Pipeline Pipeline `json:"pipeline"`
+}
+
+type PipelinesListResponse struct {
+	Pipelines []Pipeline `json:"pipelines"`
+}
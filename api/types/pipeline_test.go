@@ -0,0 +1,57 @@
+package types
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPipelineCreateParams_Validate(t *testing.T) {
+	step := func(name string, deps ...string) PipelineStepParams {
+		return PipelineStepParams{
+			Name:       name,
+			DependsOn:  deps,
+			Provider:   LambdaLabsProvider,
+			NodeTypeID: "gpu_1x_a10",
+			Command:    []string{"python", name + ".py"},
+		}
+	}
+
+	key := "my-key"
+	tests := []struct {
+		name  string
+		steps []PipelineStepParams
+		// wantErr is a substring of the error message. Empty if the pipeline is valid.
+		wantErr string
+	}{
+		{"single step", []PipelineStepParams{step("train")}, ""},
+		{"chain", []PipelineStepParams{step("a"), step("b", "a"), step("c", "b")}, ""},
+		{"diamond", []PipelineStepParams{step("a"), step("b", "a"), step("c", "a"), step("d", "b", "c")}, ""},
+		{"declared out of order", []PipelineStepParams{step("b", "a"), step("a")}, ""},
+		{"no steps", nil, "at least one step"},
+		{"missing name", []PipelineStepParams{step("")}, "'name' is required"},
+		{"duplicate step", []PipelineStepParams{step("a"), step("a")}, `duplicate step "a"`},
+		{"unknown dependency", []PipelineStepParams{step("a", "b")}, `unknown step "b"`},
+		{"self dependency", []PipelineStepParams{step("a", "a")}, "dependency cycle"},
+		{"cycle", []PipelineStepParams{step("a", "c"), step("b", "a"), step("c", "b")}, "dependency cycle"},
+		{"cycle behind a valid step", []PipelineStepParams{step("a"), step("b", "a", "c"), step("c", "b")}, "dependency cycle"},
+	}
+	for _, tt := range tests {
+		p := PipelineCreateParams{Name: "test", Steps: tt.steps, SSHKeyName: &key}
+		err := p.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if e.Code != http.StatusBadRequest || !strings.Contains(e.Message, tt.wantErr) {
+			t.Errorf("%s: expected a 400 containing %q, got %d %q", tt.name, tt.wantErr, e.Code, e.Message)
+		}
+	}
+}
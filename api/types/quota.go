@@ -0,0 +1,82 @@
+package types
+
+import (
+	"net/http"
+)
+
+// Quota limits the resources of a project or account. Limits that aren't set are
+// unlimited.
+type Quota struct {
+	// MaxSessions is the maximum number of sessions running at once.
+	MaxSessions *int `json:"maxSessions,omitempty"`
+	// MaxGPUs is the maximum number of GPUs of the sessions running at once.
+	MaxGPUs *int `json:"maxGPUs,omitempty"`
+	// AllowedNodeTypes are the node types sessions can be launched on. All node types
+	// are allowed if it's empty.
+	AllowedNodeTypes []string `json:"allowedNodeTypes,omitempty"`
+	// MonthlyGPUHours is the maximum number of GPU hours sessions can use in a calendar
+	// month in UTC.
+	MonthlyGPUHours *float64 `json:"monthlyGPUHours,omitempty"`
+}
+
+func (q *Quota) Bind(r *http.Request) error {
+	return q.Validate()
+}
+
+func (q *Quota) Validate() error {
+	if q.MaxSessions != nil && *q.MaxSessions < 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'maxSessions' must not be negative",
+		}
+	}
+	if q.MaxGPUs != nil && *q.MaxGPUs < 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'maxGPUs' must not be negative",
+		}
+	}
+	if q.MonthlyGPUHours != nil && *q.MonthlyGPUHours < 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'monthlyGPUHours' must not be negative",
+		}
+	}
+	for _, nt := range q.AllowedNodeTypes {
+		if nt == "" {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid request body: field 'allowedNodeTypes' must not contain empty ids",
+			}
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether the quota doesn't limit anything.
+func (q Quota) IsZero() bool {
+	return q.MaxSessions == nil && q.MaxGPUs == nil && len(q.AllowedNodeTypes) == 0 &&
+		q.MonthlyGPUHours == nil
+}
+
+// QuotaUsage is the usage quotas are checked against.
+type QuotaUsage struct {
+	// Sessions is the number of sessions running.
+	Sessions int `json:"sessions"`
+	// GPUs is the number of GPUs of the sessions running.
+	GPUs int `json:"gpus"`
+	// GPUHours are the GPU hours used since the start of the month.
+	GPUHours float64 `json:"gpuHours"`
+}
+
+type QuotaStatus struct {
+	Quota Quota      `json:"quota"`
+	Usage QuotaUsage `json:"usage"`
+}
+
+type QuotaResponse struct {
+	// Month is the calendar month GPU hours are counted for, e.g. 2023-04.
+	Month   string       `json:"month"`
+	Project *QuotaStatus `json:"project,omitempty"`
+	Account *QuotaStatus `json:"account,omitempty"`
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ReadinessProbes configure the checks a node has to pass after the provider reports it
+// as running. The session is provisioning until all probes pass. The SSH port is always
+// probed first, over TCP and then with an SSH login, since the session isn't usable
+// without it.
+type ReadinessProbes struct {
+	// Commands are run on the node in order once SSH is up and must exit with status 0,
+	// e.g. `nvidia-smi` to wait for the GPU drivers.
+	Commands []string `json:"commands,omitempty"`
+	// TimeoutSeconds is how long the node has to pass all probes before the session is
+	// marked as errored. Defaults to 10 minutes.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// IntervalSeconds is how long to wait before retrying a failed probe. Defaults to 5
+	// seconds.
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+}
+
+func (p *ReadinessProbes) Validate() error {
+	if p.TimeoutSeconds < 0 || p.TimeoutSeconds > 3600 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'readiness.timeoutSeconds' must be between 0 and 3600",
+		}
+	}
+	if p.IntervalSeconds < 0 || p.IntervalSeconds > 300 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'readiness.intervalSeconds' must be between 0 and 300",
+		}
+	}
+	for i, c := range p.Commands {
+		if strings.TrimSpace(c) == "" {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid request body: readiness command %d is empty", i),
+			}
+		}
+	}
+	return nil
+}
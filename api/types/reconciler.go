@@ -0,0 +1,44 @@
+package types
+
+import "time"
+
+type ReconcileFindingKind string
+
+const (
+	// ReconcileOrphan is a node launched by Unweave that no session knows about, e.g.
+	// because the API crashed while launching it.
+	ReconcileOrphan ReconcileFindingKind = "orphan"
+	// ReconcileZombie is a node that is still running although its session was
+	// terminated.
+	ReconcileZombie ReconcileFindingKind = "zombie"
+	// ReconcileVanished is an active session whose node no longer exists.
+	ReconcileVanished ReconcileFindingKind = "vanished"
+	// ReconcileDrift is an active session whose status doesn't match its node's.
+	ReconcileDrift ReconcileFindingKind = "drift"
+)
+
+type ReconcileFinding struct {
+	Kind           ReconcileFindingKind `json:"kind"`
+	Provider       RuntimeProvider      `json:"provider"`
+	NodeID         string               `json:"nodeID"`
+	SessionID      *string              `json:"sessionID,omitempty"`
+	SessionStatus  *SessionStatus       `json:"sessionStatus,omitempty"`
+	ProviderStatus *SessionStatus       `json:"providerStatus,omitempty"`
+	// Action describes what the reconciler did about the finding.
+	Action string  `json:"action"`
+	Error  *string `json:"error,omitempty"`
+}
+
+type ReconcileReport struct {
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt time.Time          `json:"finishedAt"`
+	DryRun     bool               `json:"dryRun"`
+	Findings   []ReconcileFinding `json:"findings"`
+	// Errors are failures that kept the reconciler from checking part of the nodes,
+	// e.g. a provider that couldn't be reached.
+	Errors []string `json:"errors"`
+}
+
+type ReconcileReportResponse struct {
+	Report ReconcileReport `json:"report"`
+}
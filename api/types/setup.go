@@ -0,0 +1,37 @@
+package types
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Log streams of the commands run on a node.
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+)
+
+// ProjectDefaults are applied to sessions created in the project. Fields set in the
+// SessionCreateParams or the session's template take precedence.
+type ProjectDefaults struct {
+	// SetupScript is run with bash once the node is reachable over SSH.
+	SetupScript *string `json:"setupScript,omitempty"`
+	// TerminateOnSetupFailure terminates the node if the setup script fails.
+	TerminateOnSetupFailure *bool `json:"terminateOnSetupFailure,omitempty"`
+}
+
+func (d *ProjectDefaults) Bind(r *http.Request) error {
+	if d.SetupScript != nil && strings.TrimSpace(*d.SetupScript) == "" {
+		// An empty script unsets the default.
+		d.SetupScript = nil
+	}
+	return nil
+}
+
+type ProjectDefaultsResponse struct {
+	Defaults ProjectDefaults `json:"defaults"`
+}
+
+type SessionLogsResponse struct {
+	Logs []LogEntry `json:"logs"`
+}
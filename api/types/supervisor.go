@@ -0,0 +1,25 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionWatcher struct {
+	SessionID string    `json:"sessionID"`
+	ProjectID string    `json:"projectID"`
+	AccountID uuid.UUID `json:"accountID"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+type SupervisorState struct {
+	// Replica is the ID of the API replica the state is from.
+	Replica  string           `json:"replica"`
+	Draining bool             `json:"draining"`
+	Watchers []SessionWatcher `json:"watchers"`
+}
+
+type SupervisorStateResponse struct {
+	State SupervisorState `json:"state"`
+}
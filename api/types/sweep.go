@@ -0,0 +1,201 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// maxSweepTrials caps the number of trials a single sweep can fan out into.
+const maxSweepTrials = 1000
+
+type SweepMethod string
+
+const (
+	// SweepGrid runs one trial for every combination of parameter values.
+	SweepGrid SweepMethod = "grid"
+	// SweepRandom runs a fixed number of trials with randomly sampled parameter values.
+	SweepRandom SweepMethod = "random"
+)
+
+// SweepParameter is a single dimension of a sweep's parameter space. It is either a list
+// of discrete Values or, for random sweeps only, a continuous range between Min and Max.
+type SweepParameter struct {
+	Name   string        `json:"name"`
+	Values []interface{} `json:"values,omitempty"`
+	Min    *float64      `json:"min,omitempty"`
+	Max    *float64      `json:"max,omitempty"`
+	// LogScale samples the range between Min and Max uniformly in log space. Useful for
+	// parameters such as learning rates.
+	LogScale bool `json:"logScale,omitempty"`
+	// Integer rounds values sampled from the range to the nearest integer.
+	Integer bool `json:"integer,omitempty"`
+}
+
+type SweepCreateParams struct {
+	Name string `json:"name"`
+	// Command is a Go template rendered with each trial's parameters and executed with
+	// sh. Parameter values are shell quoted when rendered.
+	//
+	// eg. python train.py --lr {{.lr}} --batch-size {{.batch_size}}
+	Command    string           `json:"command"`
+	Method     SweepMethod      `json:"method"`
+	Samples    int              `json:"samples,omitempty"`
+	Parameters []SweepParameter `json:"parameters"`
+	// MaxConcurrency is the maximum number of trials running at the same time.
+	MaxConcurrency int             `json:"maxConcurrency"`
+	Provider       RuntimeProvider `json:"provider"`
+	// NodeTypeIDs are the node types trials can be scheduled on. Each trial runs on the
+	// first of these that has available capacity when it is launched.
+	NodeTypeIDs  []string `json:"nodeTypeIDs"`
+	Region       *string  `json:"region,omitempty"`
+	BuildID      *string  `json:"buildID,omitempty"`
+	SSHKeyName   *string  `json:"sshKeyName"`
+	SSHPublicKey *string  `json:"sshPublicKey"`
+}
+
+func (s *SweepCreateParams) Bind(r *http.Request) error {
+	if s.Provider == "" {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'provider' is required",
+		}
+	}
+	if len(s.NodeTypeIDs) == 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'nodeTypeIDs' requires at least one node type",
+		}
+	}
+	if s.SSHPublicKey == nil && s.SSHKeyName == nil {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: either 'sshKeyName' or 'sshPublicKey' is required",
+		}
+	}
+	if s.MaxConcurrency <= 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'maxConcurrency' must be greater than 0",
+		}
+	}
+	if _, err := template.New("command").Option("missingkey=error").Parse(s.Command); err != nil || s.Command == "" {
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    "Invalid request body: field 'command' must be a valid template",
+			Suggestion: "Reference parameters by name, e.g. python train.py --lr {{.lr}}",
+			Err:        err,
+		}
+	}
+	if len(s.Parameters) == 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: at least one parameter is required",
+		}
+	}
+
+	names := make(map[string]bool, len(s.Parameters))
+	trials := 1
+	for _, p := range s.Parameters {
+		if p.Name == "" || names[p.Name] {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid parameter %q: names must be unique and non-empty", p.Name),
+			}
+		}
+		names[p.Name] = true
+
+		isRange := p.Min != nil || p.Max != nil
+		switch {
+		case isRange && len(p.Values) > 0:
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid parameter %q: set either 'values' or 'min' and 'max'", p.Name),
+			}
+		case isRange && (p.Min == nil || p.Max == nil || *p.Min > *p.Max):
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid parameter %q: 'min' and 'max' are required and 'min' must not exceed 'max'", p.Name),
+			}
+		case isRange && p.LogScale && *p.Min <= 0:
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid parameter %q: log scale ranges must be positive", p.Name),
+			}
+		case isRange && s.Method == SweepGrid:
+			return &Error{
+				Code:       http.StatusBadRequest,
+				Message:    fmt.Sprintf("Invalid parameter %q: ranges are not supported in grid sweeps", p.Name),
+				Suggestion: "List discrete 'values' or use the random method",
+			}
+		case !isRange && len(p.Values) == 0:
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid parameter %q: at least one value is required", p.Name),
+			}
+		}
+		if !isRange && trials <= maxSweepTrials {
+			trials *= len(p.Values)
+		}
+	}
+
+	switch s.Method {
+	case SweepGrid:
+	case SweepRandom:
+		if s.Samples <= 0 {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid request body: field 'samples' must be greater than 0 for random sweeps",
+			}
+		}
+		trials = s.Samples
+	default:
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    fmt.Sprintf("Invalid sweep method: %q", s.Method),
+			Suggestion: fmt.Sprintf("Use %q or %q", SweepGrid, SweepRandom),
+		}
+	}
+	if trials > maxSweepTrials {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Sweep exceeds the maximum of %d trials", maxSweepTrials),
+		}
+	}
+	return nil
+}
+
+type SweepTrial struct {
+	ID         string                 `json:"id"`
+	Index      int                    `json:"index"`
+	Params     map[string]interface{} `json:"params"`
+	Command    string                 `json:"command"`
+	Status     PipelineStatus         `json:"status"`
+	NodeTypeID *string                `json:"nodeTypeID,omitempty"`
+	SessionID  *string                `json:"sessionID,omitempty"`
+	ExitCode   *int                   `json:"exitCode,omitempty"`
+	StartedAt  *time.Time             `json:"startedAt,omitempty"`
+	FinishedAt *time.Time             `json:"finishedAt,omitempty"`
+	Error      *string                `json:"error,omitempty"`
+}
+
+type Sweep struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	Status         PipelineStatus `json:"status"`
+	Command        string         `json:"command"`
+	MaxConcurrency int            `json:"maxConcurrency"`
+	NodeTypeIDs    []string       `json:"nodeTypeIDs"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	Error          *string        `json:"error,omitempty"`
+	Trials         []SweepTrial   `json:"trials"`
+}
+
+type SweepGetResponse struct {
+	Sweep Sweep `json:"sweep"`
+}
+
+type SweepsListResponse struct {
+	Sweeps []Sweep `json:"sweeps"`
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"net/http"
+	"time"
+)
+
+// SessionTemplateSpec is a reusable launch configuration. Every field is optional. When
+// a session is created from a template, fields set in the SessionCreateParams take
+// precedence over the template's and labels are merged.
+type SessionTemplateSpec struct {
+	Provider     *RuntimeProvider  `json:"provider,omitempty"`
+	NodeTypeID   *string           `json:"nodeTypeID,omitempty"`
+	Region       *string           `json:"region,omitempty"`
+	SSHKeyName   *string           `json:"sshKeyName,omitempty"`
+	SSHPublicKey *string           `json:"sshPublicKey,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	SetupScript  *string           `json:"setupScript,omitempty"`
+	Readiness    *ReadinessProbes  `json:"readiness,omitempty"`
+}
+
+// SessionTemplateRef identifies the exact template version a session was created from.
+type SessionTemplateRef struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+type SessionTemplate struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Version   int                 `json:"version"`
+	Spec      SessionTemplateSpec `json:"spec"`
+	CreatedAt time.Time           `json:"createdAt"`
+	UpdatedAt time.Time           `json:"updatedAt"`
+}
+
+type SessionTemplateCreateParams struct {
+	Name string              `json:"name"`
+	Spec SessionTemplateSpec `json:"spec"`
+}
+
+func (p *SessionTemplateCreateParams) Bind(r *http.Request) error {
+	if p.Name == "" {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'name' is required",
+		}
+	}
+	return nil
+}
+
+// SessionTemplateUpdateParams replaces the spec of a template. Templates are immutable so
+// updating a template creates a new version of it.
+type SessionTemplateUpdateParams struct {
+	Name *string             `json:"name,omitempty"`
+	Spec SessionTemplateSpec `json:"spec"`
+}
+
+func (p *SessionTemplateUpdateParams) Bind(r *http.Request) error {
+	if p.Name != nil && *p.Name == "" {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'name' must not be empty",
+		}
+	}
+	return nil
+}
+
+type SessionTemplateGetResponse struct {
+	Template SessionTemplate `json:"template"`
+}
+
+type SessionTemplatesListResponse struct {
+	Templates []SessionTemplate `json:"templates"`
+}
+
+type SessionTemplateVersionsListResponse struct {
+	Versions []SessionTemplate `json:"versions"`
+}
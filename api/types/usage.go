@@ -0,0 +1,86 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Dimensions usage can be grouped by. Usage can also be grouped by the value of a label
+// with "label:<key>".
+const (
+	UsageByProject  = "project"
+	UsageByAccount  = "account"
+	UsageByProvider = "provider"
+	UsageByNodeType = "nodeType"
+	// UsageByMonth splits usage at the start of every calendar month in UTC.
+	UsageByMonth = "month"
+
+	usageByLabelPrefix = "label:"
+)
+
+// UsageLabelKey returns the label key of a "label:<key>" dimension.
+func UsageLabelKey(dimension string) (string, bool) {
+	if !strings.HasPrefix(dimension, usageByLabelPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(dimension, usageByLabelPrefix), true
+}
+
+// ParseUsageGroupBy parses a comma separated list of dimensions.
+func ParseUsageGroupBy(s string) ([]string, error) {
+	var dims []string
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		switch d {
+		case UsageByProject, UsageByAccount, UsageByProvider, UsageByNodeType, UsageByMonth:
+		default:
+			if key, ok := UsageLabelKey(d); !ok || key == "" {
+				return nil, &Error{
+					Code:    http.StatusBadRequest,
+					Message: fmt.Sprintf("Invalid usage dimension %q", d),
+					Suggestion: fmt.Sprintf("Group by %s, %s, %s, %s, %s or label:<key>",
+						UsageByProject, UsageByAccount, UsageByProvider, UsageByNodeType, UsageByMonth),
+				}
+			}
+		}
+		dims = append(dims, d)
+	}
+	return dims, nil
+}
+
+type UsageParams struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []string
+	// ProjectID limits usage to a single project.
+	ProjectID *string
+}
+
+// UsageRow is the usage of a group of sessions within the requested time range.
+type UsageRow struct {
+	// Group holds the value of every dimension usage is grouped by. Sessions without the
+	// label of a label dimension are grouped under an empty value.
+	Group    map[string]string `json:"group"`
+	Sessions int               `json:"sessions"`
+	Hours    float64           `json:"hours"`
+	// CostCents is the cost in US cents. Sessions without a known price cost nothing.
+	CostCents int64 `json:"costCents"`
+}
+
+type UsageReport struct {
+	From      time.Time  `json:"from"`
+	To        time.Time  `json:"to"`
+	GroupBy   []string   `json:"groupBy"`
+	Rows      []UsageRow `json:"rows"`
+	Hours     float64    `json:"hours"`
+	CostCents int64      `json:"costCents"`
+}
+
+type UsageResponse struct {
+	Usage UsageReport `json:"usage"`
+}
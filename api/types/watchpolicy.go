@@ -0,0 +1,97 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type WatchErrorAction string
+
+const (
+	// WatchActionTerminate terminates the node once watching it keeps failing. This
+	// guards against runaway nodes that can't be monitored.
+	WatchActionTerminate WatchErrorAction = "terminate"
+	// WatchActionDegrade marks the session as degraded and keeps the node running.
+	WatchActionDegrade WatchErrorAction = "degrade"
+)
+
+// WatchPolicy configures what happens when the status of a session's node can't be
+// fetched from the provider. Failed lookups are retried with exponential backoff. The
+// action is only taken once the retries are exhausted and the grace period has passed.
+type WatchPolicy struct {
+	MaxRetries int `json:"maxRetries"`
+	// BackoffSeconds is how long to wait before the first retry. The wait doubles on
+	// every retry up to MaxBackoffSeconds.
+	BackoffSeconds    int `json:"backoffSeconds"`
+	MaxBackoffSeconds int `json:"maxBackoffSeconds"`
+	// GracePeriodSeconds is how long after the first failure the action is taken at the
+	// earliest. Retries continue at the maximum backoff until then.
+	GracePeriodSeconds int              `json:"gracePeriodSeconds"`
+	Action             WatchErrorAction `json:"action"`
+}
+
+func (p *WatchPolicy) Bind(r *http.Request) error {
+	return p.Validate()
+}
+
+func (p *WatchPolicy) Validate() error {
+	if p.MaxRetries < 0 || p.MaxRetries > 100 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'maxRetries' must be between 0 and 100",
+		}
+	}
+	if p.BackoffSeconds < 1 || p.BackoffSeconds > 3600 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'backoffSeconds' must be between 1 and 3600",
+		}
+	}
+	if p.MaxBackoffSeconds < p.BackoffSeconds || p.MaxBackoffSeconds > 3600 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'maxBackoffSeconds' must be between 'backoffSeconds' and 3600",
+		}
+	}
+	if p.GracePeriodSeconds < 0 || p.GracePeriodSeconds > 7*24*3600 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'gracePeriodSeconds' must be between 0 and 604800",
+		}
+	}
+	if p.Action != WatchActionTerminate && p.Action != WatchActionDegrade {
+		return &Error{
+			Code:       http.StatusBadRequest,
+			Message:    fmt.Sprintf("Invalid request body: unknown action %q", p.Action),
+			Suggestion: fmt.Sprintf("Valid actions are: %s, %s", WatchActionTerminate, WatchActionDegrade),
+		}
+	}
+	return nil
+}
+
+type WatchPolicyResponse struct {
+	Policy WatchPolicy `json:"policy"`
+}
+
+type WatchDecisionAction string
+
+const (
+	WatchDecisionRetry     WatchDecisionAction = "retry"
+	WatchDecisionRecovered WatchDecisionAction = "recovered"
+	WatchDecisionTerminate WatchDecisionAction = "terminate"
+	WatchDecisionDegrade   WatchDecisionAction = "degrade"
+)
+
+// WatchDecision records what was done about a failure to watch a session and why.
+type WatchDecision struct {
+	Action    WatchDecisionAction `json:"action"`
+	Reason    string              `json:"reason"`
+	Attempt   int                 `json:"attempt"`
+	Error     *string             `json:"error,omitempty"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
+
+type WatchDecisionsListResponse struct {
+	Decisions []WatchDecision `json:"decisions"`
+}
@@ -0,0 +1,165 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventSessionProvisioning EventType = "session.provisioning"
+	EventSessionRunning      EventType = "session.running"
+	EventSessionDegraded     EventType = "session.degraded"
+	EventSessionTerminated   EventType = "session.terminated"
+	EventSessionError        EventType = "session.error"
+	EventBuildSucceeded      EventType = "build.succeeded"
+	EventBuildFailed         EventType = "build.failed"
+	EventPipelineSucceeded   EventType = "pipeline.succeeded"
+	EventPipelineFailed      EventType = "pipeline.failed"
+	EventPipelineCanceled    EventType = "pipeline.canceled"
+	EventSweepSucceeded      EventType = "sweep.succeeded"
+	EventSweepFailed         EventType = "sweep.failed"
+	EventSweepCanceled       EventType = "sweep.canceled"
+	EventBudgetThreshold     EventType = "budget.threshold"
+
+	// EventAll subscribes a webhook to every event type.
+	EventAll EventType = "*"
+)
+
+var eventTypes = map[EventType]bool{
+	EventSessionProvisioning: true,
+	EventSessionRunning:      true,
+	EventSessionDegraded:     true,
+	EventSessionTerminated:   true,
+	EventSessionError:        true,
+	EventBuildSucceeded:      true,
+	EventBuildFailed:         true,
+	EventPipelineSucceeded:   true,
+	EventPipelineFailed:      true,
+	EventPipelineCanceled:    true,
+	EventSweepSucceeded:      true,
+	EventSweepFailed:         true,
+	EventSweepCanceled:       true,
+	EventBudgetThreshold:     true,
+	EventAll:                 true,
+}
+
+// Valid reports whether t is a known event type.
+func (t EventType) Valid() bool {
+	return eventTypes[t]
+}
+
+// Event is the body of webhook deliveries and of the messages of event streams.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      EventType       `json:"type"`
+	ProjectID string          `json:"projectID"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type SessionEventData struct {
+	SessionID string        `json:"sessionID"`
+	Status    SessionStatus `json:"status"`
+	Error     *string       `json:"error,omitempty"`
+}
+
+type BuildEventData struct {
+	BuildID string  `json:"buildID"`
+	Status  string  `json:"status"`
+	Error   *string `json:"error,omitempty"`
+}
+
+type PipelineEventData struct {
+	PipelineID string         `json:"pipelineID"`
+	Status     PipelineStatus `json:"status"`
+	Error      *string        `json:"error,omitempty"`
+}
+
+type SweepEventData struct {
+	SweepID string         `json:"sweepID"`
+	Status  PipelineStatus `json:"status"`
+	Error   *string        `json:"error,omitempty"`
+}
+
+type Webhook struct {
+	ID     string      `json:"id"`
+	URL    string      `json:"url"`
+	Events []EventType `json:"events"`
+	// Secret signs the deliveries of the webhook. It is only returned when the webhook is
+	// created.
+	Secret    *string   `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WebhookCreateParams struct {
+	URL    string      `json:"url"`
+	Events []EventType `json:"events"`
+}
+
+func (p *WebhookCreateParams) Bind(r *http.Request) error {
+	u, err := url.Parse(p.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'url' must be an https URL",
+		}
+	}
+	if len(p.Events) == 0 {
+		return &Error{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid request body: field 'events' is required",
+		}
+	}
+	for _, e := range p.Events {
+		if !e.Valid() {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid request body: unknown event type %q", e),
+			}
+		}
+	}
+	return nil
+}
+
+type WebhookResponse struct {
+	Webhook Webhook `json:"webhook"`
+}
+
+type WebhooksListResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+type WebhookDelivery struct {
+	ID            string                `json:"id"`
+	EventID       string                `json:"eventID"`
+	EventType     EventType             `json:"eventType"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt *time.Time            `json:"nextAttemptAt,omitempty"`
+	LastAttemptAt *time.Time            `json:"lastAttemptAt,omitempty"`
+	// LastStatusCode is the HTTP status the endpoint responded with on the last attempt.
+	LastStatusCode *int      `json:"lastStatusCode,omitempty"`
+	LastError      *string   `json:"lastError,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveryResponse struct {
+	Delivery WebhookDelivery `json:"delivery"`
+}
+
+type WebhookDeliveriesListResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
@@ -0,0 +1,25 @@
+// Package artifacts stores the files collected from sessions before they terminate.
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when an artifact doesn't exist in the store.
+var ErrNotFound = errors.New("artifact not found")
+
+// Store defines the interface for storing and retrieving artifacts. Keys are slash
+// separated paths, e.g. se_xxx/outputs/model.pt.
+type Store interface {
+	// Put stores the content of r under key, replacing any existing artifact. size is
+	// the length of the content, or -1 if it isn't known.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get returns the content of the artifact stored under key. It returns ErrNotFound if
+	// there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the artifact stored under key. Deleting a missing artifact is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,41 @@
+package local
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/unweave/unweave/artifacts"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Put(ctx, "se_1/outputs/model.pt", strings.NewReader("weights"), 7); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := s.Get(ctx, "se_1/outputs/model.pt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "weights" {
+		t.Fatalf("expected %q, got %q", "weights", b)
+	}
+
+	if err = s.Delete(ctx, "se_1/outputs/model.pt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get(ctx, "se_1/outputs/model.pt"); err != artifacts.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err = s.Put(ctx, "../escape", strings.NewReader(""), 0); err == nil {
+		t.Fatal("expected keys outside the root to be rejected")
+	}
+}
@@ -0,0 +1,81 @@
+// Package s3 stores artifacts in an S3 compatible object store such as AWS S3, GCS or
+// MinIO.
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/unweave/unweave/artifacts"
+)
+
+type Config struct {
+	// Endpoint is the host of the object store, e.g. s3.amazonaws.com.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to all keys.
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Insecure disables TLS. It should only be used with local object stores.
+	Insecure bool
+}
+
+type Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewStore(cfg Config) (*Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+	return &Store{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (s *Store) key(key string) string {
+	return path.Join(s.prefix, key)
+}
+
+func (s *Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	opts := minio.PutObjectOptions{ContentType: "application/octet-stream"}
+	if _, err := s.client.PutObject(ctx, s.bucket, s.key(key), r, size, opts); err != nil {
+		return fmt.Errorf("failed to upload artifact: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, s.key(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artifact: %w", err)
+	}
+	// GetObject is lazy, stat the object to find out whether it exists.
+	if _, err = obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, artifacts.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get artifact: %w", err)
+	}
+	return obj, nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, s.key(key), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete artifact: %w", err)
+	}
+	return nil
+}
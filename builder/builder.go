@@ -24,6 +24,8 @@ type LogDriver interface {
 // Builder defines the interface for building and storing container images.
 type Builder interface {
 	GetBuilder() string
+	// GetImageURI returns the URI an image built with buildID is pushed to.
+	GetImageURI(buildID, namespace, reponame string) string
 	// Build builds a container image from a build context.
 	// The build context is a zip file containing the source code and any other files
 	// needed to build the image.
@@ -232,6 +232,10 @@ func (b *Builder) GetBuilder() string {
 	return "docker"
 }
 
+func (b *Builder) GetImageURI(buildID, namespace, reponame string) string {
+	return fmt.Sprintf("%s/%s/%s:%s", b.registryURI, namespace, reponame, buildID)
+}
+
 func (b *Builder) Logs(ctx context.Context, buildID string) ([]types.LogEntry, error) {
 	ctx = log.With().Str("builder", b.GetBuilder()).Str("buildID", buildID).Logger().WithContext(ctx)
 	log.Ctx(ctx).Info().Msg("Executing logs request")
@@ -303,7 +307,7 @@ func (b *Builder) Push(ctx context.Context, buildID, namespace, reponame string)
 
 	// Tag provisional image with namespace/reponame:buildID
 
-	target := fmt.Sprintf("%s/%s/%s:%s", b.registryURI, namespace, reponame, buildID)
+	target := b.GetImageURI(buildID, namespace, reponame)
 	out, err := tagImage(ctx, imageID, target)
 	if err != nil {
 		if e, ok := err.(*exec.ExitError); ok {
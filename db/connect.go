@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -11,6 +12,34 @@ import (
 // safe to use across go routines.
 var Q Querier
 
+// conn is the connection Q was initialized with. Transactions are started on it.
+var conn *sql.DB
+
+// Use initializes Q with the global database connection.
+func Use(c *sql.DB) {
+	conn = c
+	Q = New(c)
+}
+
+// Tx runs fn in a transaction. The transaction is committed if fn returns nil and rolled
+// back otherwise.
+func Tx(ctx context.Context, fn func(q Querier) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err = fn(New(tx)); err != nil {
+		if e := tx.Rollback(); e != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, e)
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 type Config struct {
 	Host     string `json:"host" env:"UNWEAVE_DB_HOST"`
 	Port     int    `json:"port" env:"UNWEAVE_DB_PORT"`
@@ -19,18 +48,21 @@ type Config struct {
 	Password string `json:"password" env:"UNWEAVE_DB_PASSWORD"`
 }
 
-func Connect(cfg Config) (*sql.DB, error) {
-	url := fmt.Sprintf(
+// URL returns the connection string of the database.
+func (c Config) URL() string {
+	return fmt.Sprintf(
 		"postgresql://%s:%s@%s:%d/%s",
-		cfg.User,
-		cfg.Password,
-		cfg.Host,
-		cfg.Port,
-		cfg.Name,
+		c.User,
+		c.Password,
+		c.Host,
+		c.Port,
+		c.Name,
 	)
+}
 
+func Connect(cfg Config) (*sql.DB, error) {
 	var err error
-	conn, err := sql.Open("pgx", url)
+	conn, err := sql.Open("pgx", cfg.URL())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create type unweave.pipeline_status as enum ('pending', 'running', 'succeeded', 'failed', 'canceled');
+
+create table unweave.pipeline
+(
+    id         text primary key                              default 'pl_' || nanoid() check ( length(id) > 11 ),
+    name       text                                 not null default '',
+    project_id text references unweave.project (id) not null,
+    created_by uuid references unweave.account (id) not null,
+    status     unweave.pipeline_status              not null default 'pending',
+    created_at timestamptz                          not null default now(),
+    updated_at timestamptz                          not null default now(),
+    error      text
+);
+
+create table unweave.pipeline_step
+(
+    id          text primary key                               default 'ps_' || nanoid() check ( length(id) > 11 ),
+    pipeline_id text references unweave.pipeline (id) not null,
+    name        text                                  not null,
+    -- spec holds the versioned step definition (node type, build, command, dependencies).
+    spec        jsonb                                 not null default '{}'::jsonb,
+    status      unweave.pipeline_status               not null default 'pending',
+    session_id  text references unweave.session (id),
+    exit_code   int,
+    started_at  timestamptz,
+    finished_at timestamptz,
+    error       text,
+
+    unique (pipeline_id, name)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.pipeline_step;
+drop table unweave.pipeline;
+drop type unweave.pipeline_status;
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create type unweave.job_status as enum ('pending', 'running', 'succeeded', 'failed', 'canceled');
+
+create table unweave.sweep
+(
+    id              text primary key                              default 'sw_' || nanoid() check ( length(id) > 11 ),
+    name            text                                 not null default '',
+    project_id      text references unweave.project (id) not null,
+    created_by      uuid references unweave.account (id) not null,
+    status          unweave.job_status                   not null default 'pending',
+    -- spec holds the versioned sweep definition (command template, node types etc).
+    spec            jsonb                                not null default '{}'::jsonb,
+    max_concurrency int                                  not null check ( max_concurrency > 0 ),
+    created_at      timestamptz                          not null default now(),
+    updated_at      timestamptz                          not null default now(),
+    error           text
+);
+
+create table unweave.sweep_trial
+(
+    id           text primary key                            default 'tr_' || nanoid() check ( length(id) > 11 ),
+    sweep_id     text references unweave.sweep (id) not null,
+    index        int                                not null,
+    params       jsonb                              not null default '{}'::jsonb,
+    command      text                               not null,
+    status       unweave.job_status                 not null default 'pending',
+    node_type_id text,
+    session_id   text references unweave.session (id),
+    exit_code    int,
+    started_at   timestamptz,
+    finished_at  timestamptz,
+    error        text,
+
+    unique (sweep_id, index)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.sweep_trial;
+drop table unweave.sweep;
+drop type unweave.job_status;
+-- +goose StatementEnd
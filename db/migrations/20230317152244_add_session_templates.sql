@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table unweave.session_template
+(
+    id             text primary key                              default 'tm_' || nanoid() check ( length(id) > 11 ),
+    name           text                                 not null check ( name <> '' ),
+    project_id     text references unweave.project (id) not null,
+    created_by     uuid references unweave.account (id) not null,
+    latest_version int                                  not null default 1,
+    created_at     timestamptz                          not null default now(),
+    updated_at     timestamptz                          not null default now(),
+
+    unique (project_id, name)
+);
+
+-- Templates are immutable once created. Updating a template adds a new version so
+-- sessions can always be traced back to the exact configuration they were created with.
+create table unweave.session_template_version
+(
+    template_id text references unweave.session_template (id) not null,
+    version     int                                           not null,
+    spec        jsonb                                         not null default '{}'::jsonb,
+    created_by  uuid references unweave.account (id)          not null,
+    created_at  timestamptz                                   not null default now(),
+
+    primary key (template_id, version)
+);
+
+alter table unweave.session
+    add column labels           jsonb not null default '{}'::jsonb,
+    add column template_id      text references unweave.session_template (id),
+    add column template_version int;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table unweave.session
+    drop column labels,
+    drop column template_id,
+    drop column template_version;
+drop table unweave.session_template_version;
+drop table unweave.session_template;
+-- +goose StatementEnd
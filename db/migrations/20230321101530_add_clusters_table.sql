@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A cluster is a group of sessions on nodes of the same type in the same region that
+-- are launched and torn down together.
+create table unweave.cluster
+(
+    id           text primary key                              default 'cl_' || nanoid() check ( length(id) > 11 ),
+    name         text                                 not null,
+    project_id   text references unweave.project (id) not null,
+    created_by   uuid references unweave.account (id) not null,
+    provider     text                                 not null,
+    node_type_id text                                 not null,
+    region       text                                 not null,
+    size         int                                  not null check ( size > 0 ),
+    status       unweave.session_status               not null default 'initializing',
+    created_at   timestamptz                          not null default now(),
+    ready_at     timestamptz,
+    exited_at    timestamptz,
+    error        text
+);
+
+alter table unweave.session
+    add column cluster_id   text references unweave.cluster (id),
+    add column cluster_rank int,
+    add unique (cluster_id, cluster_rank);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table unweave.session
+    drop column cluster_id,
+    drop column cluster_rank;
+drop table unweave.cluster;
+-- +goose StatementEnd
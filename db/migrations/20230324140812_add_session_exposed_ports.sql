@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table unweave.session_exposed_port
+(
+    session_id text references unweave.session (id) not null,
+    port       int                                  not null check ( port > 0 and port < 65536 ),
+    created_at timestamptz                          not null default now(),
+
+    primary key (session_id, port)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.session_exposed_port;
+-- +goose StatementEnd
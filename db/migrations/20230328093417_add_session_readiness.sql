@@ -0,0 +1,18 @@
+-- +goose NO TRANSACTION
+-- Adding a value to an enum can't run inside a transaction block.
+
+-- +goose Up
+alter type unweave.session_status add value if not exists 'provisioning' after 'initializing';
+
+alter table unweave.session
+    add column readiness jsonb not null default '{}'::jsonb;
+
+-- +goose Down
+-- Postgres can't drop a value from an enum so provisioning sessions are moved back to
+-- initializing and the value is left in place.
+update unweave.session
+set status = 'initializing'
+where status = 'provisioning';
+
+alter table unweave.session
+    drop column readiness;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table unweave.session_metric
+(
+    session_id       text references unweave.session (id) not null,
+    sampled_at       timestamptz                          not null,
+    cpu_percent      real                                 not null,
+    mem_used_bytes   bigint                               not null,
+    mem_total_bytes  bigint                               not null,
+    disk_used_bytes  bigint                               not null,
+    disk_total_bytes bigint                               not null,
+    -- Per GPU samples, empty for nodes without GPUs.
+    gpus             jsonb                                not null default '[]'::jsonb,
+
+    primary key (session_id, sampled_at)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.session_metric;
+-- +goose StatementEnd
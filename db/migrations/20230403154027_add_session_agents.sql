@@ -0,0 +1,25 @@
+-- +goose NO TRANSACTION
+-- Adding a value to an enum can't run inside a transaction block.
+
+-- +goose Up
+alter type unweave.session_status add value if not exists 'degraded' after 'running';
+
+create table unweave.session_agent
+(
+    session_id        text references unweave.session (id) primary key,
+    -- sha256 of the token the agent authenticates with.
+    token_hash        text        not null unique,
+    agent_version     text,
+    protocol_version  int,
+    installed_at      timestamptz not null default now(),
+    last_heartbeat_at timestamptz
+);
+
+-- +goose Down
+drop table unweave.session_agent;
+
+-- Postgres can't drop a value from an enum so degraded sessions are moved back to
+-- running and the value is left in place.
+update unweave.session
+set status = 'running'
+where status = 'degraded';
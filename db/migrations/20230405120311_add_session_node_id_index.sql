@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+create index session_node_id_idx on unweave.session (node_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index unweave.session_node_id_idx;
+-- +goose StatementEnd
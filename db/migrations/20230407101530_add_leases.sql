@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Leases give a single API replica ownership of a piece of background work, e.g.
+-- watching a session. Leases that aren't renewed expire and can be taken over.
+create table unweave.lease
+(
+    key        text primary key,
+    owner      text        not null,
+    expires_at timestamptz not null
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.lease;
+-- +goose StatementEnd
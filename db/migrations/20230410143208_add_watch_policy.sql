@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table unweave.project
+    add column watch_policy jsonb not null default '{}';
+
+-- Every decision taken when watching a session fails, e.g. to retry or terminate the node.
+create table unweave.session_watch_decision
+(
+    id         bigint generated always as identity primary key,
+    session_id text references unweave.session (id) not null,
+    created_at timestamptz                          not null default now(),
+    action     text                                 not null,
+    reason     text                                 not null,
+    attempt    int                                  not null,
+    error      text
+);
+
+create index session_watch_decision_session_id_idx on unweave.session_watch_decision (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.session_watch_decision;
+
+alter table unweave.project
+    drop column watch_policy;
+-- +goose StatementEnd
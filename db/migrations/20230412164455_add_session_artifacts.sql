@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table unweave.session
+    add column artifacts jsonb not null default '{}';
+
+create table unweave.session_artifact
+(
+    id         text primary key                     default 'ar_' || nanoid() check ( length(id) > 11 ),
+    session_id text references unweave.session (id) not null,
+    -- path is the path of the file on the node.
+    path       text                                 not null,
+    -- key is where the file is stored in the artifact store.
+    key        text                                 not null unique,
+    size       bigint                               not null,
+    sha256     text                                 not null,
+    created_at timestamptz                          not null default now(),
+    unique (session_id, path)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.session_artifact;
+
+alter table unweave.session
+    drop column artifacts;
+-- +goose StatementEnd
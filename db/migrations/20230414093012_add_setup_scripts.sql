@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table unweave.project
+    add column defaults jsonb not null default '{}';
+
+alter table unweave.session
+    add column setup jsonb not null default '{}';
+
+-- Output of the commands run on a session's node, e.g. its setup script.
+create table unweave.session_log
+(
+    id         bigint generated always as identity primary key,
+    session_id text references unweave.session (id) not null,
+    source     text                                 not null,
+    stream     text                                 not null,
+    timestamp  timestamptz                          not null,
+    message    text                                 not null
+);
+
+create index session_log_session_id_idx on unweave.session_log (session_id, source);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.session_log;
+
+alter table unweave.session
+    drop column setup;
+
+alter table unweave.project
+    drop column defaults;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+create table unweave.webhook
+(
+    id         text primary key                     default 'wh_' || nanoid() check ( length(id) > 11 ),
+    project_id text references unweave.project (id) not null,
+    url        text                                 not null,
+    -- secret signs the deliveries so that receivers can verify they come from us.
+    secret     text                                 not null,
+    -- events is a JSON array of the event types the webhook is subscribed to.
+    events     jsonb                                not null,
+    created_at timestamptz                          not null default now()
+);
+
+create index webhook_project_id_idx on unweave.webhook (project_id);
+
+create type unweave.webhook_delivery_status as enum ('pending', 'succeeded', 'failed');
+
+create table unweave.webhook_delivery
+(
+    id               text primary key                                          default 'wd_' || nanoid() check ( length(id) > 11 ),
+    webhook_id       text references unweave.webhook (id) on delete cascade not null,
+    event_id         text                                                   not null,
+    event_type       text                                                   not null,
+    payload          jsonb                                                  not null,
+    status           unweave.webhook_delivery_status                        not null default 'pending',
+    attempts         int                                                    not null default 0,
+    next_attempt_at  timestamptz                                            not null default now(),
+    last_attempt_at  timestamptz,
+    last_status_code int,
+    last_error       text,
+    created_at       timestamptz                                            not null default now()
+);
+
+create index webhook_delivery_webhook_id_idx on unweave.webhook_delivery (webhook_id, created_at);
+create index webhook_delivery_pending_idx on unweave.webhook_delivery (next_attempt_at) where status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.webhook_delivery;
+drop type unweave.webhook_delivery_status;
+drop table unweave.webhook;
+-- +goose StatementEnd
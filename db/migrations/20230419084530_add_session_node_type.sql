@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The node type, its specs and its hourly price in US cents at the time the session was
+-- launched. They are unknown for sessions launched before they were recorded.
+alter table unweave.session
+    add column node_type_id text,
+    add column node_specs   jsonb not null default '{}'::jsonb,
+    add column price        integer;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table unweave.session
+    drop column price,
+    drop column node_specs,
+    drop column node_type_id;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A usage record is opened when a session's node is launched and closed once the node
+-- is terminated. Open records accrue up to the current time. The price is in US cents per hour.
+create table unweave.session_usage
+(
+    session_id   text primary key references unweave.session (id),
+    project_id   text references unweave.project (id) not null,
+    account_id   uuid references unweave.account (id) not null,
+    provider     text                                 not null,
+    node_type_id text                                 not null,
+    price        integer,
+    labels       jsonb                                not null default '{}'::jsonb,
+    started_at   timestamptz                          not null default now(),
+    ended_at     timestamptz
+);
+
+create index session_usage_started_at_idx on unweave.session_usage (started_at);
+
+insert into unweave.session_usage (session_id, project_id, account_id, provider, node_type_id,
+                                   price, labels, started_at, ended_at)
+select id,
+       project_id,
+       created_by,
+       provider,
+       node_type_id,
+       price,
+       labels,
+       created_at,
+       case
+           when status = 'terminated' then coalesce(exited_at, now())
+           end
+from unweave.session
+where node_type_id is not null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.session_usage;
+-- +goose StatementEnd
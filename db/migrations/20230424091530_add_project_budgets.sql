@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table unweave.project
+    add column budget jsonb not null default '{}'::jsonb;
+
+-- The budget thresholds a project has reached, so that each is only alerted on once a
+-- month.
+create table unweave.project_budget_alert
+(
+    project_id text references unweave.project (id) not null,
+    month      date                                 not null,
+    threshold  integer                              not null,
+    spent      bigint                               not null,
+    created_at timestamptz                          not null default now(),
+    primary key (project_id, month, threshold)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.project_budget_alert;
+
+alter table unweave.project
+    drop column budget;
+-- +goose StatementEnd
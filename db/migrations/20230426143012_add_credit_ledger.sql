@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+create type unweave.credit_transaction_kind as enum ('top_up', 'usage', 'adjustment');
+
+-- A credit transaction changes the balance of an account. amount is the signed change in
+-- US cents.
+create table unweave.credit_transaction
+(
+    id          text primary key                             default 'ct_' || nanoid() check ( length(id) > 11 ),
+    account_id  uuid references unweave.account (id)         not null,
+    kind        unweave.credit_transaction_kind              not null,
+    amount      bigint                                       not null,
+    description text                                         not null default '',
+    session_id  text references unweave.session (id),
+    created_at  timestamptz                                  not null default now()
+);
+
+create index credit_transaction_account_id_idx on unweave.credit_transaction (account_id, created_at);
+create index credit_transaction_session_id_idx on unweave.credit_transaction (session_id) where session_id is not null;
+
+-- Every transaction moves credit between the ledger of an account and a platform ledger
+-- so the entries of a transaction always sum to zero. The balance of an account is the
+-- sum of the entries of its ledger.
+create table unweave.credit_entry
+(
+    id             bigint generated always as identity primary key,
+    transaction_id text references unweave.credit_transaction (id) not null,
+    ledger         text                                            not null,
+    amount         bigint                                          not null
+);
+
+create index credit_entry_ledger_idx on unweave.credit_entry (ledger);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.credit_entry;
+drop table unweave.credit_transaction;
+drop type unweave.credit_transaction_kind;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table unweave.account
+    add column quota jsonb not null default '{}'::jsonb;
+
+alter table unweave.project
+    add column quota jsonb not null default '{}'::jsonb;
+
+alter table unweave.session_usage
+    add column gpus integer not null default 0;
+
+-- Node types didn't record their GPU count before, so it's taken from the node type id,
+-- e.g. gpu_8x_a100.
+update unweave.session_usage
+set gpus = coalesce(substring(node_type_id from 'gpu_(\d+)x_')::integer, 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table unweave.session_usage
+    drop column gpus;
+
+alter table unweave.project
+    drop column quota;
+
+alter table unweave.account
+    drop column quota;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table unweave.pipeline
+    add column ssh_key_name text not null default '';
+
+-- The platform key was saved in each account so that job sessions could be created with
+-- it. Nodes are now launched with the user's key and the platform key is only
+-- registered with the provider.
+update unweave.ssh_key
+set is_active = false
+where name like 'uw:platform-%';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table unweave.pipeline
+    drop column ssh_key_name;
+-- +goose StatementEnd
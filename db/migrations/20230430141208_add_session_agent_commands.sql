@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Commands are queued in the db so that they reach the agent whichever replica the
+-- agent polls, and their results reach the replica that sent them.
+create table unweave.session_agent_command
+(
+    id           text primary key,
+    session_id   text references unweave.session (id) not null,
+    command      jsonb                                not null default '{}'::jsonb,
+    result       jsonb                                not null default '{}'::jsonb,
+    created_at   timestamptz                          not null default now(),
+    delivered_at timestamptz,
+    completed_at timestamptz
+);
+
+create index session_agent_command_pending_idx on unweave.session_agent_command (session_id)
+    where delivered_at is null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.session_agent_command;
+-- +goose StatementEnd
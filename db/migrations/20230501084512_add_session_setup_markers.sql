@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When the setup script of a session started and finished. Setup is claimed by setting
+-- setup_started_at so that it runs at most once, even if provisioning is restarted.
+alter table unweave.session
+    add column setup_started_at  timestamptz,
+    add column setup_finished_at timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table unweave.session
+    drop column setup_finished_at,
+    drop column setup_started_at;
+-- +goose StatementEnd
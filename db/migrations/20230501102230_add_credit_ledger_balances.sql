@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The running balance of every ledger. It is updated along with the entries of each
+-- transaction so that balances don't need to be summed from all entries.
+create table unweave.credit_ledger_balance
+(
+    ledger     text primary key,
+    balance    bigint      not null,
+    updated_at timestamptz not null default now()
+);
+
+insert into unweave.credit_ledger_balance (ledger, balance)
+select ledger, sum(amount)
+from unweave.credit_entry
+group by ledger;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table unweave.credit_ledger_balance;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sweeps and their trials go through the same states as pipelines and their steps, so
+-- they share the pipeline status instead of having their own.
+alter table unweave.sweep
+    alter column status drop default,
+    alter column status type unweave.pipeline_status using status::text::unweave.pipeline_status,
+    alter column status set default 'pending';
+
+alter table unweave.sweep_trial
+    alter column status drop default,
+    alter column status type unweave.pipeline_status using status::text::unweave.pipeline_status,
+    alter column status set default 'pending';
+
+drop type unweave.job_status;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+create type unweave.job_status as enum ('pending', 'running', 'succeeded', 'failed', 'canceled');
+
+alter table unweave.sweep_trial
+    alter column status drop default,
+    alter column status type unweave.job_status using status::text::unweave.job_status,
+    alter column status set default 'pending';
+
+alter table unweave.sweep
+    alter column status drop default,
+    alter column status type unweave.job_status using status::text::unweave.job_status,
+    alter column status set default 'pending';
+-- +goose StatementEnd
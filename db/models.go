@@ -60,11 +60,101 @@ func (ns NullUnweaveBuildStatus) Value() (driver.Value, error) {
 	return ns.UnweaveBuildStatus, nil
 }
 
+type UnweaveCreditTransactionKind string
+
+const (
+	UnweaveCreditTransactionKindTopUp      UnweaveCreditTransactionKind = "top_up"
+	UnweaveCreditTransactionKindUsage      UnweaveCreditTransactionKind = "usage"
+	UnweaveCreditTransactionKindAdjustment UnweaveCreditTransactionKind = "adjustment"
+)
+
+func (e *UnweaveCreditTransactionKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UnweaveCreditTransactionKind(s)
+	case string:
+		*e = UnweaveCreditTransactionKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UnweaveCreditTransactionKind: %T", src)
+	}
+	return nil
+}
+
+type NullUnweaveCreditTransactionKind struct {
+	UnweaveCreditTransactionKind UnweaveCreditTransactionKind
+	Valid                        bool // Valid is true if String is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUnweaveCreditTransactionKind) Scan(value interface{}) error {
+	if value == nil {
+		ns.UnweaveCreditTransactionKind, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UnweaveCreditTransactionKind.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUnweaveCreditTransactionKind) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return ns.UnweaveCreditTransactionKind, nil
+}
+
+type UnweavePipelineStatus string
+
+const (
+	UnweavePipelineStatusPending   UnweavePipelineStatus = "pending"
+	UnweavePipelineStatusRunning   UnweavePipelineStatus = "running"
+	UnweavePipelineStatusSucceeded UnweavePipelineStatus = "succeeded"
+	UnweavePipelineStatusFailed    UnweavePipelineStatus = "failed"
+	UnweavePipelineStatusCanceled  UnweavePipelineStatus = "canceled"
+)
+
+func (e *UnweavePipelineStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UnweavePipelineStatus(s)
+	case string:
+		*e = UnweavePipelineStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UnweavePipelineStatus: %T", src)
+	}
+	return nil
+}
+
+type NullUnweavePipelineStatus struct {
+	UnweavePipelineStatus UnweavePipelineStatus
+	Valid                 bool // Valid is true if String is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUnweavePipelineStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.UnweavePipelineStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UnweavePipelineStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUnweavePipelineStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return ns.UnweavePipelineStatus, nil
+}
+
 type UnweaveSessionStatus string
 
 const (
 	UnweaveSessionStatusInitializing UnweaveSessionStatus = "initializing"
+	UnweaveSessionStatusProvisioning UnweaveSessionStatus = "provisioning"
 	UnweaveSessionStatusRunning      UnweaveSessionStatus = "running"
+	UnweaveSessionStatusDegraded     UnweaveSessionStatus = "degraded"
 	UnweaveSessionStatusTerminated   UnweaveSessionStatus = "terminated"
 	UnweaveSessionStatusError        UnweaveSessionStatus = "error"
 )
@@ -104,8 +194,52 @@ func (ns NullUnweaveSessionStatus) Value() (driver.Value, error) {
 	return ns.UnweaveSessionStatus, nil
 }
 
+type UnweaveWebhookDeliveryStatus string
+
+const (
+	UnweaveWebhookDeliveryStatusPending   UnweaveWebhookDeliveryStatus = "pending"
+	UnweaveWebhookDeliveryStatusSucceeded UnweaveWebhookDeliveryStatus = "succeeded"
+	UnweaveWebhookDeliveryStatusFailed    UnweaveWebhookDeliveryStatus = "failed"
+)
+
+func (e *UnweaveWebhookDeliveryStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UnweaveWebhookDeliveryStatus(s)
+	case string:
+		*e = UnweaveWebhookDeliveryStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UnweaveWebhookDeliveryStatus: %T", src)
+	}
+	return nil
+}
+
+type NullUnweaveWebhookDeliveryStatus struct {
+	UnweaveWebhookDeliveryStatus UnweaveWebhookDeliveryStatus
+	Valid                        bool // Valid is true if String is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUnweaveWebhookDeliveryStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.UnweaveWebhookDeliveryStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UnweaveWebhookDeliveryStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUnweaveWebhookDeliveryStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return ns.UnweaveWebhookDeliveryStatus, nil
+}
+
 type UnweaveAccount struct {
-	ID uuid.UUID `json:"id"`
+	ID    uuid.UUID       `json:"id"`
+	Quota json.RawMessage `json:"quota"`
 }
 
 type UnweaveBuild struct {
@@ -118,29 +252,221 @@ type UnweaveBuild struct {
 	MetaData    json.RawMessage    `json:"metaData"`
 }
 
+type UnweaveCluster struct {
+	ID         string               `json:"id"`
+	Name       string               `json:"name"`
+	ProjectID  string               `json:"projectID"`
+	CreatedBy  uuid.UUID            `json:"createdBy"`
+	Provider   string               `json:"provider"`
+	NodeTypeID string               `json:"nodeTypeID"`
+	Region     string               `json:"region"`
+	Size       int32                `json:"size"`
+	Status     UnweaveSessionStatus `json:"status"`
+	CreatedAt  time.Time            `json:"createdAt"`
+	ReadyAt    sql.NullTime         `json:"readyAt"`
+	ExitedAt   sql.NullTime         `json:"exitedAt"`
+	Error      sql.NullString       `json:"error"`
+}
+
+type UnweaveCreditEntry struct {
+	ID            int64  `json:"id"`
+	TransactionID string `json:"transactionID"`
+	Ledger        string `json:"ledger"`
+	Amount        int64  `json:"amount"`
+}
+
+type UnweaveCreditLedgerBalance struct {
+	Ledger    string    `json:"ledger"`
+	Balance   int64     `json:"balance"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type UnweaveCreditTransaction struct {
+	ID          string                       `json:"id"`
+	AccountID   uuid.UUID                    `json:"accountID"`
+	Kind        UnweaveCreditTransactionKind `json:"kind"`
+	Amount      int64                        `json:"amount"`
+	Description string                       `json:"description"`
+	SessionID   sql.NullString               `json:"sessionID"`
+	CreatedAt   time.Time                    `json:"createdAt"`
+}
+
+type UnweaveLease struct {
+	Key       string    `json:"key"`
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type UnweavePipeline struct {
+	ID         string                `json:"id"`
+	Name       string                `json:"name"`
+	ProjectID  string                `json:"projectID"`
+	CreatedBy  uuid.UUID             `json:"createdBy"`
+	Status     UnweavePipelineStatus `json:"status"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	UpdatedAt  time.Time             `json:"updatedAt"`
+	Error      sql.NullString        `json:"error"`
+	SshKeyName string                `json:"sshKeyName"`
+}
+
+type UnweavePipelineStep struct {
+	ID         string                `json:"id"`
+	PipelineID string                `json:"pipelineID"`
+	Name       string                `json:"name"`
+	Spec       json.RawMessage       `json:"spec"`
+	Status     UnweavePipelineStatus `json:"status"`
+	SessionID  sql.NullString        `json:"sessionID"`
+	ExitCode   sql.NullInt32         `json:"exitCode"`
+	StartedAt  sql.NullTime          `json:"startedAt"`
+	FinishedAt sql.NullTime          `json:"finishedAt"`
+	Error      sql.NullString        `json:"error"`
+}
+
 type UnweaveProject struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Icon      string    `json:"icon"`
-	OwnerID   uuid.UUID `json:"ownerID"`
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	Icon         string          `json:"icon"`
+	OwnerID      uuid.UUID       `json:"ownerID"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	DefaultBuild sql.NullString  `json:"defaultBuild"`
+	WatchPolicy  json.RawMessage `json:"watchPolicy"`
+	Defaults     json.RawMessage `json:"defaults"`
+	Budget       json.RawMessage `json:"budget"`
+	Quota        json.RawMessage `json:"quota"`
+}
+
+type UnweaveProjectBudgetAlert struct {
+	ProjectID string    `json:"projectID"`
+	Month     time.Time `json:"month"`
+	Threshold int32     `json:"threshold"`
+	Spent     int64     `json:"spent"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
 type UnweaveSession struct {
-	ID             string               `json:"id"`
-	Name           string               `json:"name"`
-	NodeID         string               `json:"nodeID"`
-	Region         string               `json:"region"`
-	CreatedBy      uuid.UUID            `json:"createdBy"`
-	CreatedAt      time.Time            `json:"createdAt"`
-	ReadyAt        sql.NullTime         `json:"readyAt"`
-	ExitedAt       sql.NullTime         `json:"exitedAt"`
-	Status         UnweaveSessionStatus `json:"status"`
-	ProjectID      string               `json:"projectID"`
-	Provider       string               `json:"provider"`
-	SshKeyID       string               `json:"sshKeyID"`
-	ConnectionInfo json.RawMessage      `json:"connectionInfo"`
-	Error          sql.NullString       `json:"error"`
+	ID              string               `json:"id"`
+	Name            string               `json:"name"`
+	NodeID          string               `json:"nodeID"`
+	Region          string               `json:"region"`
+	CreatedBy       uuid.UUID            `json:"createdBy"`
+	CreatedAt       time.Time            `json:"createdAt"`
+	ReadyAt         sql.NullTime         `json:"readyAt"`
+	ExitedAt        sql.NullTime         `json:"exitedAt"`
+	Status          UnweaveSessionStatus `json:"status"`
+	ProjectID       string               `json:"projectID"`
+	Provider        string               `json:"provider"`
+	SshKeyID        string               `json:"sshKeyID"`
+	ConnectionInfo  json.RawMessage      `json:"connectionInfo"`
+	Error           sql.NullString       `json:"error"`
+	Labels          json.RawMessage      `json:"labels"`
+	TemplateID      sql.NullString       `json:"templateID"`
+	TemplateVersion sql.NullInt32        `json:"templateVersion"`
+	ClusterID       sql.NullString       `json:"clusterID"`
+	ClusterRank     sql.NullInt32        `json:"clusterRank"`
+	Readiness       json.RawMessage      `json:"readiness"`
+	Artifacts       json.RawMessage      `json:"artifacts"`
+	Setup           json.RawMessage      `json:"setup"`
+	NodeTypeID      sql.NullString       `json:"nodeTypeID"`
+	NodeSpecs       json.RawMessage      `json:"nodeSpecs"`
+	Price           sql.NullInt32        `json:"price"`
+	SetupStartedAt  sql.NullTime         `json:"setupStartedAt"`
+	SetupFinishedAt sql.NullTime         `json:"setupFinishedAt"`
+}
+
+type UnweaveSessionAgent struct {
+	SessionID       string         `json:"sessionID"`
+	TokenHash       string         `json:"tokenHash"`
+	AgentVersion    sql.NullString `json:"agentVersion"`
+	ProtocolVersion sql.NullInt32  `json:"protocolVersion"`
+	InstalledAt     time.Time      `json:"installedAt"`
+	LastHeartbeatAt sql.NullTime   `json:"lastHeartbeatAt"`
+}
+
+type UnweaveSessionAgentCommand struct {
+	ID          string          `json:"id"`
+	SessionID   string          `json:"sessionID"`
+	Command     json.RawMessage `json:"command"`
+	Result      json.RawMessage `json:"result"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	DeliveredAt sql.NullTime    `json:"deliveredAt"`
+	CompletedAt sql.NullTime    `json:"completedAt"`
+}
+
+type UnweaveSessionArtifact struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionID"`
+	Path      string    `json:"path"`
+	Key       string    `json:"key"`
+	Size      int64     `json:"size"`
+	Sha256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type UnweaveSessionExposedPort struct {
+	SessionID string    `json:"sessionID"`
+	Port      int32     `json:"port"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type UnweaveSessionLog struct {
+	ID        int64     `json:"id"`
+	SessionID string    `json:"sessionID"`
+	Source    string    `json:"source"`
+	Stream    string    `json:"stream"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+type UnweaveSessionMetric struct {
+	SessionID      string          `json:"sessionID"`
+	SampledAt      time.Time       `json:"sampledAt"`
+	CpuPercent     float32         `json:"cpuPercent"`
+	MemUsedBytes   int64           `json:"memUsedBytes"`
+	MemTotalBytes  int64           `json:"memTotalBytes"`
+	DiskUsedBytes  int64           `json:"diskUsedBytes"`
+	DiskTotalBytes int64           `json:"diskTotalBytes"`
+	Gpus           json.RawMessage `json:"gpus"`
+}
+
+type UnweaveSessionTemplate struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	ProjectID     string    `json:"projectID"`
+	CreatedBy     uuid.UUID `json:"createdBy"`
+	LatestVersion int32     `json:"latestVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type UnweaveSessionTemplateVersion struct {
+	TemplateID string          `json:"templateID"`
+	Version    int32           `json:"version"`
+	Spec       json.RawMessage `json:"spec"`
+	CreatedBy  uuid.UUID       `json:"createdBy"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+type UnweaveSessionUsage struct {
+	SessionID  string          `json:"sessionID"`
+	ProjectID  string          `json:"projectID"`
+	AccountID  uuid.UUID       `json:"accountID"`
+	Provider   string          `json:"provider"`
+	NodeTypeID string          `json:"nodeTypeID"`
+	Price      sql.NullInt32   `json:"price"`
+	Labels     json.RawMessage `json:"labels"`
+	StartedAt  time.Time       `json:"startedAt"`
+	EndedAt    sql.NullTime    `json:"endedAt"`
+	Gpus       int32           `json:"gpus"`
+}
+
+type UnweaveSessionWatchDecision struct {
+	ID        int64          `json:"id"`
+	SessionID string         `json:"sessionID"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Action    string         `json:"action"`
+	Reason    string         `json:"reason"`
+	Attempt   int32          `json:"attempt"`
+	Error     sql.NullString `json:"error"`
 }
 
 type UnweaveSshKey struct {
@@ -151,3 +477,55 @@ type UnweaveSshKey struct {
 	PublicKey string    `json:"publicKey"`
 	IsActive  bool      `json:"isActive"`
 }
+
+type UnweaveSweep struct {
+	ID             string                `json:"id"`
+	Name           string                `json:"name"`
+	ProjectID      string                `json:"projectID"`
+	CreatedBy      uuid.UUID             `json:"createdBy"`
+	Status         UnweavePipelineStatus `json:"status"`
+	Spec           json.RawMessage       `json:"spec"`
+	MaxConcurrency int32                 `json:"maxConcurrency"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	UpdatedAt      time.Time             `json:"updatedAt"`
+	Error          sql.NullString        `json:"error"`
+}
+
+type UnweaveSweepTrial struct {
+	ID         string                `json:"id"`
+	SweepID    string                `json:"sweepID"`
+	Index      int32                 `json:"index"`
+	Params     json.RawMessage       `json:"params"`
+	Command    string                `json:"command"`
+	Status     UnweavePipelineStatus `json:"status"`
+	NodeTypeID sql.NullString        `json:"nodeTypeID"`
+	SessionID  sql.NullString        `json:"sessionID"`
+	ExitCode   sql.NullInt32         `json:"exitCode"`
+	StartedAt  sql.NullTime          `json:"startedAt"`
+	FinishedAt sql.NullTime          `json:"finishedAt"`
+	Error      sql.NullString        `json:"error"`
+}
+
+type UnweaveWebhook struct {
+	ID        string          `json:"id"`
+	ProjectID string          `json:"projectID"`
+	Url       string          `json:"url"`
+	Secret    string          `json:"secret"`
+	Events    json.RawMessage `json:"events"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type UnweaveWebhookDelivery struct {
+	ID             string                       `json:"id"`
+	WebhookID      string                       `json:"webhookID"`
+	EventID        string                       `json:"eventID"`
+	EventType      string                       `json:"eventType"`
+	Payload        json.RawMessage              `json:"payload"`
+	Status         UnweaveWebhookDeliveryStatus `json:"status"`
+	Attempts       int32                        `json:"attempts"`
+	NextAttemptAt  time.Time                    `json:"nextAttemptAt"`
+	LastAttemptAt  sql.NullTime                 `json:"lastAttemptAt"`
+	LastStatusCode sql.NullInt32                `json:"lastStatusCode"`
+	LastError      sql.NullString               `json:"lastError"`
+	CreatedAt      time.Time                    `json:"createdAt"`
+}
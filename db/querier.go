@@ -6,31 +6,129 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
+	AccountQuotaGet(ctx context.Context, id uuid.UUID) (json.RawMessage, error)
+	AccountQuotaUpdate(ctx context.Context, arg AccountQuotaUpdateParams) error
 	BuildCreate(ctx context.Context, arg BuildCreateParams) (string, error)
 	BuildGet(ctx context.Context, id string) (UnweaveBuild, error)
 	BuildUpdate(ctx context.Context, arg BuildUpdateParams) error
+	ClusterCreate(ctx context.Context, arg ClusterCreateParams) (string, error)
+	ClusterGet(ctx context.Context, id string) (UnweaveCluster, error)
+	ClusterGetAllActive(ctx context.Context) ([]UnweaveCluster, error)
+	ClusterGetAllActiveUnleased(ctx context.Context) ([]string, error)
+	ClusterMembersGet(ctx context.Context, clusterID sql.NullString) ([]ClusterMembersGetRow, error)
+	ClusterStatusUpdate(ctx context.Context, arg ClusterStatusUpdateParams) error
+	ClustersGet(ctx context.Context, projectID string) ([]UnweaveCluster, error)
+	CreditBalance(ctx context.Context, accountID uuid.UUID) (int64, error)
+	CreditTransactionCreate(ctx context.Context, arg CreditTransactionCreateParams) (UnweaveCreditTransaction, error)
+	CreditTransactionsList(ctx context.Context, arg CreditTransactionsListParams) ([]UnweaveCreditTransaction, error)
+	EventNotify(ctx context.Context, arg EventNotifyParams) error
+	LeaseAcquire(ctx context.Context, arg LeaseAcquireParams) (string, error)
+	LeaseRelease(ctx context.Context, arg LeaseReleaseParams) error
+	LeaseRenew(ctx context.Context, arg LeaseRenewParams) ([]string, error)
 	//-----------------------------------------------------------------
 	// The queries below return data in the format expected by the API.
 	//-----------------------------------------------------------------
 	MxSessionGet(ctx context.Context, id string) (MxSessionGetRow, error)
 	MxSessionsGet(ctx context.Context, projectID string) ([]MxSessionsGetRow, error)
+	PipelineCreate(ctx context.Context, arg PipelineCreateParams) (string, error)
+	PipelineFinish(ctx context.Context, arg PipelineFinishParams) (string, error)
+	PipelineGet(ctx context.Context, id string) (UnweavePipeline, error)
+	PipelineGetAllActive(ctx context.Context) ([]UnweavePipeline, error)
+	PipelineGetAllActiveUnleased(ctx context.Context) ([]string, error)
+	PipelineStatusUpdate(ctx context.Context, arg PipelineStatusUpdateParams) error
+	PipelineStepCreate(ctx context.Context, arg PipelineStepCreateParams) (string, error)
+	PipelineStepFinish(ctx context.Context, arg PipelineStepFinishParams) error
+	PipelineStepSetSession(ctx context.Context, arg PipelineStepSetSessionParams) error
+	PipelineStepStart(ctx context.Context, id string) error
+	PipelineStepsGet(ctx context.Context, pipelineID string) ([]UnweavePipelineStep, error)
+	PipelinesGet(ctx context.Context, projectID string) ([]UnweavePipeline, error)
+	ProjectBudgetAlertAdd(ctx context.Context, arg ProjectBudgetAlertAddParams) (int32, error)
+	ProjectBudgetUpdate(ctx context.Context, arg ProjectBudgetUpdateParams) error
+	ProjectDefaultsUpdate(ctx context.Context, arg ProjectDefaultsUpdateParams) error
 	ProjectGet(ctx context.Context, id string) (UnweaveProject, error)
+	ProjectQuotaUpdate(ctx context.Context, arg ProjectQuotaUpdateParams) error
+	ProjectWatchPolicyUpdate(ctx context.Context, arg ProjectWatchPolicyUpdateParams) error
+	ProjectsWithBudget(ctx context.Context) ([]UnweaveProject, error)
 	SSHKeyAdd(ctx context.Context, arg SSHKeyAddParams) error
 	SSHKeyGetByName(ctx context.Context, arg SSHKeyGetByNameParams) (UnweaveSshKey, error)
 	SSHKeyGetByPublicKey(ctx context.Context, arg SSHKeyGetByPublicKeyParams) (UnweaveSshKey, error)
 	SSHKeysGet(ctx context.Context, ownerID uuid.UUID) ([]UnweaveSshKey, error)
+	SessionAgentCommandComplete(ctx context.Context, arg SessionAgentCommandCompleteParams) (string, error)
+	SessionAgentCommandCreate(ctx context.Context, arg SessionAgentCommandCreateParams) error
+	SessionAgentCommandDelete(ctx context.Context, id string) error
+	SessionAgentCommandResultGet(ctx context.Context, id string) (json.RawMessage, error)
+	SessionAgentCommandsDeliver(ctx context.Context, sessionID string) ([]json.RawMessage, error)
+	SessionAgentCreate(ctx context.Context, arg SessionAgentCreateParams) error
+	SessionAgentGet(ctx context.Context, sessionID string) (UnweaveSessionAgent, error)
+	SessionAgentGetByToken(ctx context.Context, tokenHash string) (UnweaveSessionAgent, error)
+	SessionAgentHeartbeat(ctx context.Context, arg SessionAgentHeartbeatParams) error
+	SessionArtifactAdd(ctx context.Context, arg SessionArtifactAddParams) (string, error)
+	SessionArtifactGet(ctx context.Context, id string) (UnweaveSessionArtifact, error)
+	SessionArtifactsList(ctx context.Context, sessionID string) ([]UnweaveSessionArtifact, error)
 	SessionCreate(ctx context.Context, arg SessionCreateParams) (string, error)
+	SessionExposedPortAdd(ctx context.Context, arg SessionExposedPortAddParams) error
+	SessionExposedPortRemove(ctx context.Context, arg SessionExposedPortRemoveParams) error
+	SessionExposedPortsGet(ctx context.Context, sessionID string) ([]UnweaveSessionExposedPort, error)
 	SessionGet(ctx context.Context, id string) (UnweaveSession, error)
 	SessionGetAllActive(ctx context.Context) ([]UnweaveSession, error)
+	SessionGetAllActiveUnleased(ctx context.Context) ([]string, error)
+	SessionGetByNodeID(ctx context.Context, arg SessionGetByNodeIDParams) (UnweaveSession, error)
+	SessionLogAdd(ctx context.Context, arg SessionLogAddParams) error
+	SessionLogsGet(ctx context.Context, arg SessionLogsGetParams) ([]UnweaveSessionLog, error)
+	SessionMetricAdd(ctx context.Context, arg SessionMetricAddParams) error
+	SessionMetricsGet(ctx context.Context, arg SessionMetricsGetParams) ([]UnweaveSessionMetric, error)
+	SessionProviderAccountsGet(ctx context.Context) ([]SessionProviderAccountsGetRow, error)
+	SessionSetCluster(ctx context.Context, arg SessionSetClusterParams) error
 	SessionSetError(ctx context.Context, arg SessionSetErrorParams) error
+	SessionSetupFinish(ctx context.Context, id string) error
+	SessionSetupStart(ctx context.Context, id string) (string, error)
 	SessionStatusUpdate(ctx context.Context, arg SessionStatusUpdateParams) error
+	SessionTemplateBumpVersion(ctx context.Context, arg SessionTemplateBumpVersionParams) (int32, error)
+	SessionTemplateCreate(ctx context.Context, arg SessionTemplateCreateParams) (string, error)
+	SessionTemplateGet(ctx context.Context, id string) (UnweaveSessionTemplate, error)
+	SessionTemplateVersionCreate(ctx context.Context, arg SessionTemplateVersionCreateParams) error
+	SessionTemplateVersionGet(ctx context.Context, arg SessionTemplateVersionGetParams) (UnweaveSessionTemplateVersion, error)
+	SessionTemplateVersionsGet(ctx context.Context, templateID string) ([]UnweaveSessionTemplateVersion, error)
+	SessionTemplatesGet(ctx context.Context, projectID string) ([]UnweaveSessionTemplate, error)
 	SessionUpdateConnectionInfo(ctx context.Context, arg SessionUpdateConnectionInfoParams) error
+	SessionUsageEnd(ctx context.Context, sessionID string) error
+	SessionUsageList(ctx context.Context, arg SessionUsageListParams) ([]UnweaveSessionUsage, error)
+	SessionUsageListByAccount(ctx context.Context, arg SessionUsageListByAccountParams) ([]UnweaveSessionUsage, error)
+	SessionUsageListByProject(ctx context.Context, arg SessionUsageListByProjectParams) ([]UnweaveSessionUsage, error)
+	SessionUsageStart(ctx context.Context, arg SessionUsageStartParams) error
+	SessionUsageToDebit(ctx context.Context, since time.Time) ([]SessionUsageToDebitRow, error)
+	SessionWatchDecisionAdd(ctx context.Context, arg SessionWatchDecisionAddParams) error
+	SessionWatchDecisionsGet(ctx context.Context, sessionID string) ([]UnweaveSessionWatchDecision, error)
 	SessionsGet(ctx context.Context, arg SessionsGetParams) ([]SessionsGetRow, error)
+	SweepCreate(ctx context.Context, arg SweepCreateParams) (string, error)
+	SweepGet(ctx context.Context, id string) (UnweaveSweep, error)
+	SweepGetAllActive(ctx context.Context) ([]UnweaveSweep, error)
+	SweepGetAllActiveUnleased(ctx context.Context) ([]string, error)
+	SweepStatusUpdate(ctx context.Context, arg SweepStatusUpdateParams) error
+	SweepTrialCreate(ctx context.Context, arg SweepTrialCreateParams) (string, error)
+	SweepTrialFinish(ctx context.Context, arg SweepTrialFinishParams) error
+	SweepTrialSetSession(ctx context.Context, arg SweepTrialSetSessionParams) error
+	SweepTrialStart(ctx context.Context, arg SweepTrialStartParams) error
+	SweepTrialsGet(ctx context.Context, sweepID string) ([]UnweaveSweepTrial, error)
+	SweepsGet(ctx context.Context, projectID string) ([]UnweaveSweep, error)
+	WebhookCreate(ctx context.Context, arg WebhookCreateParams) (string, error)
+	WebhookDelete(ctx context.Context, id string) error
+	WebhookDeliveriesClaim(ctx context.Context, limit int32) ([]UnweaveWebhookDelivery, error)
+	WebhookDeliveriesList(ctx context.Context, webhookID string) ([]UnweaveWebhookDelivery, error)
+	WebhookDeliveryAttempt(ctx context.Context, arg WebhookDeliveryAttemptParams) error
+	WebhookDeliveryCreate(ctx context.Context, arg WebhookDeliveryCreateParams) (string, error)
+	WebhookDeliveryGet(ctx context.Context, id string) (UnweaveWebhookDelivery, error)
+	WebhookDeliveryRedeliver(ctx context.Context, id string) error
+	WebhookGet(ctx context.Context, id string) (UnweaveWebhook, error)
+	WebhooksList(ctx context.Context, projectID string) ([]UnweaveWebhook, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -74,12 +74,19 @@ func (q *Queries) BuildUpdate(ctx context.Context, arg BuildUpdateParams) error
 const MxSessionGet = `-- name: MxSessionGet :one
 
 select s.id,
+       s.project_id,
        s.status,
        s.node_id,
        s.provider,
        s.region,
        s.created_at,
        s.connection_info,
+       s.labels,
+       s.template_id,
+       s.template_version,
+       s.node_type_id,
+       s.node_specs,
+       s.price,
        ssh_key.name       as ssh_key_name,
        ssh_key.public_key,
        ssh_key.created_at as ssh_key_created_at
@@ -90,12 +97,19 @@ where s.id = $1
 
 type MxSessionGetRow struct {
 	ID              string               `json:"id"`
+	ProjectID       string               `json:"projectID"`
 	Status          UnweaveSessionStatus `json:"status"`
 	NodeID          string               `json:"nodeID"`
 	Provider        string               `json:"provider"`
 	Region          string               `json:"region"`
 	CreatedAt       time.Time            `json:"createdAt"`
 	ConnectionInfo  json.RawMessage      `json:"connectionInfo"`
+	Labels          json.RawMessage      `json:"labels"`
+	TemplateID      sql.NullString       `json:"templateID"`
+	TemplateVersion sql.NullInt32        `json:"templateVersion"`
+	NodeTypeID      sql.NullString       `json:"nodeTypeID"`
+	NodeSpecs       json.RawMessage      `json:"nodeSpecs"`
+	Price           sql.NullInt32        `json:"price"`
 	SshKeyName      string               `json:"sshKeyName"`
 	PublicKey       string               `json:"publicKey"`
 	SshKeyCreatedAt time.Time            `json:"sshKeyCreatedAt"`
@@ -109,12 +123,19 @@ func (q *Queries) MxSessionGet(ctx context.Context, id string) (MxSessionGetRow,
 	var i MxSessionGetRow
 	err := row.Scan(
 		&i.ID,
+		&i.ProjectID,
 		&i.Status,
 		&i.NodeID,
 		&i.Provider,
 		&i.Region,
 		&i.CreatedAt,
 		&i.ConnectionInfo,
+		&i.Labels,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.NodeTypeID,
+		&i.NodeSpecs,
+		&i.Price,
 		&i.SshKeyName,
 		&i.PublicKey,
 		&i.SshKeyCreatedAt,
@@ -124,12 +145,19 @@ func (q *Queries) MxSessionGet(ctx context.Context, id string) (MxSessionGetRow,
 
 const MxSessionsGet = `-- name: MxSessionsGet :many
 select s.id,
+       s.project_id,
        s.status,
        s.node_id,
        s.provider,
        s.region,
        s.created_at,
        s.connection_info,
+       s.labels,
+       s.template_id,
+       s.template_version,
+       s.node_type_id,
+       s.node_specs,
+       s.price,
        ssh_key.name       as ssh_key_name,
        ssh_key.public_key,
        ssh_key.created_at as ssh_key_created_at
@@ -140,12 +168,19 @@ where s.project_id = $1
 
 type MxSessionsGetRow struct {
 	ID              string               `json:"id"`
+	ProjectID       string               `json:"projectID"`
 	Status          UnweaveSessionStatus `json:"status"`
 	NodeID          string               `json:"nodeID"`
 	Provider        string               `json:"provider"`
 	Region          string               `json:"region"`
 	CreatedAt       time.Time            `json:"createdAt"`
 	ConnectionInfo  json.RawMessage      `json:"connectionInfo"`
+	Labels          json.RawMessage      `json:"labels"`
+	TemplateID      sql.NullString       `json:"templateID"`
+	TemplateVersion sql.NullInt32        `json:"templateVersion"`
+	NodeTypeID      sql.NullString       `json:"nodeTypeID"`
+	NodeSpecs       json.RawMessage      `json:"nodeSpecs"`
+	Price           sql.NullInt32        `json:"price"`
 	SshKeyName      string               `json:"sshKeyName"`
 	PublicKey       string               `json:"publicKey"`
 	SshKeyCreatedAt time.Time            `json:"sshKeyCreatedAt"`
@@ -162,12 +197,19 @@ func (q *Queries) MxSessionsGet(ctx context.Context, projectID string) ([]MxSess
 		var i MxSessionsGetRow
 		if err := rows.Scan(
 			&i.ID,
+			&i.ProjectID,
 			&i.Status,
 			&i.NodeID,
 			&i.Provider,
 			&i.Region,
 			&i.CreatedAt,
 			&i.ConnectionInfo,
+			&i.Labels,
+			&i.TemplateID,
+			&i.TemplateVersion,
+			&i.NodeTypeID,
+			&i.NodeSpecs,
+			&i.Price,
 			&i.SshKeyName,
 			&i.PublicKey,
 			&i.SshKeyCreatedAt,
@@ -185,8 +227,24 @@ func (q *Queries) MxSessionsGet(ctx context.Context, projectID string) ([]MxSess
 	return items, nil
 }
 
+const ProjectDefaultsUpdate = `-- name: ProjectDefaultsUpdate :exec
+update unweave.project
+set defaults = $2
+where id = $1
+`
+
+type ProjectDefaultsUpdateParams struct {
+	ID       string          `json:"id"`
+	Defaults json.RawMessage `json:"defaults"`
+}
+
+func (q *Queries) ProjectDefaultsUpdate(ctx context.Context, arg ProjectDefaultsUpdateParams) error {
+	_, err := q.db.ExecContext(ctx, ProjectDefaultsUpdate, arg.ID, arg.Defaults)
+	return err
+}
+
 const ProjectGet = `-- name: ProjectGet :one
-select id, name, icon, owner_id, created_at
+select id, name, icon, owner_id, created_at, default_build, watch_policy, defaults, budget, quota
 from unweave.project
 where id = $1
 `
@@ -200,10 +258,31 @@ func (q *Queries) ProjectGet(ctx context.Context, id string) (UnweaveProject, er
 		&i.Icon,
 		&i.OwnerID,
 		&i.CreatedAt,
+		&i.DefaultBuild,
+		&i.WatchPolicy,
+		&i.Defaults,
+		&i.Budget,
+		&i.Quota,
 	)
 	return i, err
 }
 
+const ProjectWatchPolicyUpdate = `-- name: ProjectWatchPolicyUpdate :exec
+update unweave.project
+set watch_policy = $2
+where id = $1
+`
+
+type ProjectWatchPolicyUpdateParams struct {
+	ID          string          `json:"id"`
+	WatchPolicy json.RawMessage `json:"watchPolicy"`
+}
+
+func (q *Queries) ProjectWatchPolicyUpdate(ctx context.Context, arg ProjectWatchPolicyUpdateParams) error {
+	_, err := q.db.ExecContext(ctx, ProjectWatchPolicyUpdate, arg.ID, arg.WatchPolicy)
+	return err
+}
+
 const SSHKeyAdd = `-- name: SSHKeyAdd :exec
 insert into unweave.ssh_key (owner_id, name, public_key)
 values ($1, $2, $3)
@@ -310,23 +389,34 @@ func (q *Queries) SSHKeysGet(ctx context.Context, ownerID uuid.UUID) ([]UnweaveS
 
 const SessionCreate = `-- name: SessionCreate :one
 insert into unweave.session (node_id, created_by, project_id, provider, ssh_key_id,
-                             region, name, connection_info)
+                             region, name, connection_info, labels, template_id,
+                             template_version, readiness, artifacts, setup, node_type_id,
+                             node_specs, price)
 values ($1, $2, $3, $4, (select id
                          from unweave.ssh_key as ssh_keys
-                         where ssh_keys.name = $8
-                           and owner_id = $2), $5, $6, $7)
+                         where ssh_keys.name = $17
+                           and owner_id = $2), $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 returning id
 `
 
 type SessionCreateParams struct {
-	NodeID         string          `json:"nodeID"`
-	CreatedBy      uuid.UUID       `json:"createdBy"`
-	ProjectID      string          `json:"projectID"`
-	Provider       string          `json:"provider"`
-	Region         string          `json:"region"`
-	Name           string          `json:"name"`
-	ConnectionInfo json.RawMessage `json:"connectionInfo"`
-	SshKeyName     string          `json:"sshKeyName"`
+	NodeID          string          `json:"nodeID"`
+	CreatedBy       uuid.UUID       `json:"createdBy"`
+	ProjectID       string          `json:"projectID"`
+	Provider        string          `json:"provider"`
+	Region          string          `json:"region"`
+	Name            string          `json:"name"`
+	ConnectionInfo  json.RawMessage `json:"connectionInfo"`
+	Labels          json.RawMessage `json:"labels"`
+	TemplateID      sql.NullString  `json:"templateID"`
+	TemplateVersion sql.NullInt32   `json:"templateVersion"`
+	Readiness       json.RawMessage `json:"readiness"`
+	Artifacts       json.RawMessage `json:"artifacts"`
+	Setup           json.RawMessage `json:"setup"`
+	NodeTypeID      sql.NullString  `json:"nodeTypeID"`
+	NodeSpecs       json.RawMessage `json:"nodeSpecs"`
+	Price           sql.NullInt32   `json:"price"`
+	SshKeyName      string          `json:"sshKeyName"`
 }
 
 func (q *Queries) SessionCreate(ctx context.Context, arg SessionCreateParams) (string, error) {
@@ -338,6 +428,15 @@ func (q *Queries) SessionCreate(ctx context.Context, arg SessionCreateParams) (s
 		arg.Region,
 		arg.Name,
 		arg.ConnectionInfo,
+		arg.Labels,
+		arg.TemplateID,
+		arg.TemplateVersion,
+		arg.Readiness,
+		arg.Artifacts,
+		arg.Setup,
+		arg.NodeTypeID,
+		arg.NodeSpecs,
+		arg.Price,
 		arg.SshKeyName,
 	)
 	var id string
@@ -346,7 +445,7 @@ func (q *Queries) SessionCreate(ctx context.Context, arg SessionCreateParams) (s
 }
 
 const SessionGet = `-- name: SessionGet :one
-select id, name, node_id, region, created_by, created_at, ready_at, exited_at, status, project_id, provider, ssh_key_id, connection_info, error
+select id, name, node_id, region, created_by, created_at, ready_at, exited_at, status, project_id, provider, ssh_key_id, connection_info, error, labels, template_id, template_version, cluster_id, cluster_rank, readiness, artifacts, setup, node_type_id, node_specs, price, setup_started_at, setup_finished_at
 from unweave.session
 where id = $1
 `
@@ -369,15 +468,30 @@ func (q *Queries) SessionGet(ctx context.Context, id string) (UnweaveSession, er
 		&i.SshKeyID,
 		&i.ConnectionInfo,
 		&i.Error,
+		&i.Labels,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.ClusterID,
+		&i.ClusterRank,
+		&i.Readiness,
+		&i.Artifacts,
+		&i.Setup,
+		&i.NodeTypeID,
+		&i.NodeSpecs,
+		&i.Price,
+		&i.SetupStartedAt,
+		&i.SetupFinishedAt,
 	)
 	return i, err
 }
 
 const SessionGetAllActive = `-- name: SessionGetAllActive :many
-select id, name, node_id, region, created_by, created_at, ready_at, exited_at, status, project_id, provider, ssh_key_id, connection_info, error
+select id, name, node_id, region, created_by, created_at, ready_at, exited_at, status, project_id, provider, ssh_key_id, connection_info, error, labels, template_id, template_version, cluster_id, cluster_rank, readiness, artifacts, setup, node_type_id, node_specs, price, setup_started_at, setup_finished_at
 from unweave.session
 where status = 'initializing'
+   or status = 'provisioning'
    or status = 'running'
+   or status = 'degraded'
 `
 
 func (q *Queries) SessionGetAllActive(ctx context.Context) ([]UnweaveSession, error) {
@@ -404,6 +518,19 @@ func (q *Queries) SessionGetAllActive(ctx context.Context) ([]UnweaveSession, er
 			&i.SshKeyID,
 			&i.ConnectionInfo,
 			&i.Error,
+			&i.Labels,
+			&i.TemplateID,
+			&i.TemplateVersion,
+			&i.ClusterID,
+			&i.ClusterRank,
+			&i.Readiness,
+			&i.Artifacts,
+			&i.Setup,
+			&i.NodeTypeID,
+			&i.NodeSpecs,
+			&i.Price,
+			&i.SetupStartedAt,
+			&i.SetupFinishedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -418,6 +545,86 @@ func (q *Queries) SessionGetAllActive(ctx context.Context) ([]UnweaveSession, er
 	return items, nil
 }
 
+const SessionGetByNodeID = `-- name: SessionGetByNodeID :one
+select id, name, node_id, region, created_by, created_at, ready_at, exited_at, status, project_id, provider, ssh_key_id, connection_info, error, labels, template_id, template_version, cluster_id, cluster_rank, readiness, artifacts, setup, node_type_id, node_specs, price, setup_started_at, setup_finished_at
+from unweave.session
+where node_id = $1
+  and provider = $2
+`
+
+type SessionGetByNodeIDParams struct {
+	NodeID   string `json:"nodeID"`
+	Provider string `json:"provider"`
+}
+
+func (q *Queries) SessionGetByNodeID(ctx context.Context, arg SessionGetByNodeIDParams) (UnweaveSession, error) {
+	row := q.db.QueryRowContext(ctx, SessionGetByNodeID, arg.NodeID, arg.Provider)
+	var i UnweaveSession
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.NodeID,
+		&i.Region,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.ReadyAt,
+		&i.ExitedAt,
+		&i.Status,
+		&i.ProjectID,
+		&i.Provider,
+		&i.SshKeyID,
+		&i.ConnectionInfo,
+		&i.Error,
+		&i.Labels,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.ClusterID,
+		&i.ClusterRank,
+		&i.Readiness,
+		&i.Artifacts,
+		&i.Setup,
+		&i.NodeTypeID,
+		&i.NodeSpecs,
+		&i.Price,
+		&i.SetupStartedAt,
+		&i.SetupFinishedAt,
+	)
+	return i, err
+}
+
+const SessionProviderAccountsGet = `-- name: SessionProviderAccountsGet :many
+select distinct created_by, provider
+from unweave.session
+`
+
+type SessionProviderAccountsGetRow struct {
+	CreatedBy uuid.UUID `json:"createdBy"`
+	Provider  string    `json:"provider"`
+}
+
+func (q *Queries) SessionProviderAccountsGet(ctx context.Context) ([]SessionProviderAccountsGetRow, error) {
+	rows, err := q.db.QueryContext(ctx, SessionProviderAccountsGet)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SessionProviderAccountsGetRow
+	for rows.Next() {
+		var i SessionProviderAccountsGetRow
+		if err := rows.Scan(&i.CreatedBy, &i.Provider); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const SessionSetError = `-- name: SessionSetError :exec
 update unweave.session
 set status = 'error'::unweave.session_status,
@@ -435,6 +642,31 @@ func (q *Queries) SessionSetError(ctx context.Context, arg SessionSetErrorParams
 	return err
 }
 
+const SessionSetupFinish = `-- name: SessionSetupFinish :exec
+update unweave.session
+set setup_finished_at = now()
+where id = $1
+`
+
+func (q *Queries) SessionSetupFinish(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, SessionSetupFinish, id)
+	return err
+}
+
+const SessionSetupStart = `-- name: SessionSetupStart :one
+update unweave.session
+set setup_started_at = now()
+where id = $1
+  and setup_started_at is null
+returning id
+`
+
+func (q *Queries) SessionSetupStart(ctx context.Context, id string) (string, error) {
+	row := q.db.QueryRowContext(ctx, SessionSetupStart, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
 const SessionStatusUpdate = `-- name: SessionStatusUpdate :exec
 update unweave.session
 set status = $2
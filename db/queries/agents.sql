@@ -0,0 +1,60 @@
+-- name: SessionAgentCreate :exec
+insert into unweave.session_agent (session_id, token_hash)
+values ($1, $2)
+on conflict (session_id) do update set token_hash        = excluded.token_hash,
+                                       agent_version     = null,
+                                       protocol_version  = null,
+                                       installed_at      = now(),
+                                       last_heartbeat_at = null;
+
+-- name: SessionAgentGet :one
+select *
+from unweave.session_agent
+where session_id = $1;
+
+-- name: SessionAgentGetByToken :one
+select *
+from unweave.session_agent
+where token_hash = $1;
+
+-- name: SessionAgentHeartbeat :exec
+update unweave.session_agent
+set agent_version     = $2,
+    protocol_version  = $3,
+    last_heartbeat_at = now()
+where session_id = $1;
+
+-- name: SessionAgentCommandCreate :exec
+insert into unweave.session_agent_command (id, session_id, command)
+values ($1, $2, $3);
+
+-- name: SessionAgentCommandsDeliver :many
+with delivered as (
+    update unweave.session_agent_command
+    set delivered_at = now()
+    where session_id = $1
+      and delivered_at is null
+    returning command, created_at)
+select command
+from delivered
+order by created_at;
+
+-- name: SessionAgentCommandComplete :one
+update unweave.session_agent_command
+set result       = $3,
+    completed_at = now()
+where id = $1
+  and session_id = $2
+  and completed_at is null
+returning id;
+
+-- name: SessionAgentCommandResultGet :one
+select result
+from unweave.session_agent_command
+where id = $1
+  and completed_at is not null;
+
+-- name: SessionAgentCommandDelete :exec
+delete
+from unweave.session_agent_command
+where id = $1;
@@ -0,0 +1,19 @@
+-- name: SessionArtifactAdd :one
+insert into unweave.session_artifact (session_id, path, key, size, sha256)
+values ($1, $2, $3, $4, $5)
+on conflict (session_id, path) do update set key        = excluded.key,
+                                             size       = excluded.size,
+                                             sha256     = excluded.sha256,
+                                             created_at = now()
+returning id;
+
+-- name: SessionArtifactGet :one
+select *
+from unweave.session_artifact
+where id = $1;
+
+-- name: SessionArtifactsList :many
+select *
+from unweave.session_artifact
+where session_id = $1
+order by path;
@@ -0,0 +1,15 @@
+-- name: ProjectBudgetUpdate :exec
+update unweave.project
+set budget = $2
+where id = $1;
+
+-- name: ProjectsWithBudget :many
+select *
+from unweave.project
+where budget <> '{}'::jsonb;
+
+-- name: ProjectBudgetAlertAdd :one
+insert into unweave.project_budget_alert (project_id, month, threshold, spent)
+values ($1, $2, $3, $4)
+on conflict do nothing
+returning threshold;
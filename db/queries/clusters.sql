@@ -0,0 +1,44 @@
+-- name: ClusterCreate :one
+insert into unweave.cluster (name, project_id, created_by, provider, node_type_id, region, size)
+values ($1, $2, $3, $4, $5, $6, $7)
+returning id;
+
+-- name: ClusterGet :one
+select *
+from unweave.cluster
+where id = $1;
+
+-- name: ClusterGetAllActive :many
+select *
+from unweave.cluster
+where status = 'initializing'
+   or status = 'running';
+
+-- name: ClustersGet :many
+select *
+from unweave.cluster
+where project_id = $1
+order by created_at desc;
+
+-- name: ClusterStatusUpdate :exec
+update unweave.cluster
+set status    = $2,
+    error     = $3,
+    ready_at  = case when $2 = 'running'::unweave.session_status then now() else ready_at end,
+    exited_at = case
+                    when $2 in ('terminated'::unweave.session_status, 'error'::unweave.session_status)
+                        then now()
+                    else exited_at end
+where id = $1;
+
+-- name: ClusterMembersGet :many
+select id, node_id, status, connection_info, cluster_rank
+from unweave.session
+where cluster_id = $1
+order by cluster_rank;
+
+-- name: SessionSetCluster :exec
+update unweave.session
+set cluster_id   = $2,
+    cluster_rank = $3
+where id = $1;
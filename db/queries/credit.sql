@@ -0,0 +1,43 @@
+-- name: CreditTransactionCreate :one
+with t as (
+    insert into unweave.credit_transaction (account_id, kind, amount, description, session_id)
+        values ($1, $2, $3, $4, $5)
+        returning *),
+     e as (
+         insert into unweave.credit_entry (transaction_id, ledger, amount)
+             select t.id, 'account:' || t.account_id, t.amount
+             from t
+             union all
+             select t.id, @counter_ledger::text, -t.amount
+             from t
+             returning ledger, amount),
+     b as (
+         insert into unweave.credit_ledger_balance (ledger, balance)
+             select ledger, amount
+             from e
+             on conflict (ledger) do update set balance    = credit_ledger_balance.balance + excluded.balance,
+                                                updated_at = now())
+select *
+from t;
+
+-- name: CreditBalance :one
+select coalesce((select balance
+                 from unweave.credit_ledger_balance
+                 where ledger = 'account:' || @account_id::uuid), 0)::bigint as balance;
+
+-- name: CreditTransactionsList :many
+select *
+from unweave.credit_transaction
+where account_id = $1
+order by created_at desc
+limit $2;
+
+-- name: SessionUsageToDebit :many
+select u.*,
+       coalesce((select sum(-t.amount)
+                 from unweave.credit_transaction as t
+                 where t.session_id = u.session_id
+                   and t.kind = 'usage'), 0)::bigint as debited
+from unweave.session_usage as u
+where u.price is not null
+  and (u.ended_at is null or u.ended_at > @since);
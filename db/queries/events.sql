@@ -0,0 +1,2 @@
+-- name: EventNotify :exec
+select pg_notify(@channel::text, @payload::text);
@@ -0,0 +1,55 @@
+-- name: LeaseAcquire :one
+insert into unweave.lease (key, owner, expires_at)
+values ($1, $2, now() + @ttl_seconds::int * interval '1 second')
+on conflict (key) do update set owner      = excluded.owner,
+                                expires_at = excluded.expires_at
+where lease.owner = excluded.owner
+   or lease.expires_at < now()
+returning key;
+
+-- name: LeaseRelease :exec
+delete
+from unweave.lease
+where key = $1
+  and owner = $2;
+
+-- name: LeaseRenew :many
+update unweave.lease
+set expires_at = now() + @ttl_seconds::int * interval '1 second'
+where owner = $1
+  and expires_at > now()
+returning key;
+
+-- name: SessionGetAllActiveUnleased :many
+select s.id
+from unweave.session s
+         left join unweave.lease l on l.key = 'session/' || s.id and l.expires_at > now()
+where (s.status = 'initializing'
+    or s.status = 'provisioning'
+    or s.status = 'running'
+    or s.status = 'degraded')
+  and l.key is null;
+
+-- name: ClusterGetAllActiveUnleased :many
+select c.id
+from unweave.cluster c
+         left join unweave.lease l on l.key = 'cluster/' || c.id and l.expires_at > now()
+where (c.status = 'initializing'
+    or c.status = 'running')
+  and l.key is null;
+
+-- name: PipelineGetAllActiveUnleased :many
+select p.id
+from unweave.pipeline p
+         left join unweave.lease l on l.key = 'pipeline/' || p.id and l.expires_at > now()
+where (p.status = 'pending'
+    or p.status = 'running')
+  and l.key is null;
+
+-- name: SweepGetAllActiveUnleased :many
+select s.id
+from unweave.sweep s
+         left join unweave.lease l on l.key = 'sweep/' || s.id and l.expires_at > now()
+where (s.status = 'pending'
+    or s.status = 'running')
+  and l.key is null;
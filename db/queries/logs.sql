@@ -0,0 +1,10 @@
+-- name: SessionLogAdd :exec
+insert into unweave.session_log (session_id, source, stream, timestamp, message)
+values ($1, $2, $3, $4, $5);
+
+-- name: SessionLogsGet :many
+select *
+from unweave.session_log
+where session_id = $1
+  and source = $2
+order by timestamp, id;
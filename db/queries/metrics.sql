@@ -0,0 +1,13 @@
+-- name: SessionMetricAdd :exec
+insert into unweave.session_metric (session_id, sampled_at, cpu_percent, mem_used_bytes,
+                                    mem_total_bytes, disk_used_bytes, disk_total_bytes, gpus)
+values ($1, $2, $3, $4, $5, $6, $7, $8)
+on conflict (session_id, sampled_at) do nothing;
+
+-- name: SessionMetricsGet :many
+select *
+from unweave.session_metric
+where session_id = $1
+  and sampled_at >= @from_time
+  and sampled_at < @to_time
+order by sampled_at;
@@ -0,0 +1,67 @@
+-- name: PipelineCreate :one
+insert into unweave.pipeline (name, project_id, created_by, ssh_key_name)
+values ($1, $2, $3, $4)
+returning id;
+
+-- name: PipelineGet :one
+select *
+from unweave.pipeline
+where id = $1;
+
+-- name: PipelineGetAllActive :many
+select *
+from unweave.pipeline
+where status = 'pending'
+   or status = 'running';
+
+-- name: PipelinesGet :many
+select *
+from unweave.pipeline
+where project_id = $1
+order by created_at desc;
+
+-- name: PipelineStatusUpdate :exec
+update unweave.pipeline
+set status     = $2,
+    error      = $3,
+    updated_at = now()
+where id = $1;
+
+-- name: PipelineFinish :one
+update unweave.pipeline
+set status     = $2,
+    error      = $3,
+    updated_at = now()
+where id = $1
+  and status <> 'canceled'
+returning id;
+
+-- name: PipelineStepCreate :one
+insert into unweave.pipeline_step (pipeline_id, name, spec)
+values ($1, $2, $3)
+returning id;
+
+-- name: PipelineStepsGet :many
+select *
+from unweave.pipeline_step
+where pipeline_id = $1
+order by name;
+
+-- name: PipelineStepSetSession :exec
+update unweave.pipeline_step
+set session_id = $2
+where id = $1;
+
+-- name: PipelineStepStart :exec
+update unweave.pipeline_step
+set status     = 'running'::unweave.pipeline_status,
+    started_at = now()
+where id = $1;
+
+-- name: PipelineStepFinish :exec
+update unweave.pipeline_step
+set status      = $2,
+    exit_code   = $3,
+    error       = $4,
+    finished_at = now()
+where id = $1;
@@ -0,0 +1,16 @@
+-- name: SessionExposedPortAdd :exec
+insert into unweave.session_exposed_port (session_id, port)
+values ($1, $2)
+on conflict (session_id, port) do nothing;
+
+-- name: SessionExposedPortRemove :exec
+delete
+from unweave.session_exposed_port
+where session_id = $1
+  and port = $2;
+
+-- name: SessionExposedPortsGet :many
+select *
+from unweave.session_exposed_port
+where session_id = $1
+order by port;
@@ -19,13 +19,25 @@ select *
 from unweave.project
 where id = $1;
 
+-- name: ProjectDefaultsUpdate :exec
+update unweave.project
+set defaults = $2
+where id = $1;
+
+-- name: ProjectWatchPolicyUpdate :exec
+update unweave.project
+set watch_policy = $2
+where id = $1;
+
 -- name: SessionCreate :one
 insert into unweave.session (node_id, created_by, project_id, provider, ssh_key_id,
-                             region, name, connection_info)
+                             region, name, connection_info, labels, template_id,
+                             template_version, readiness, artifacts, setup, node_type_id,
+                             node_specs, price)
 values ($1, $2, $3, $4, (select id
                          from unweave.ssh_key as ssh_keys
                          where ssh_keys.name = @ssh_key_name
-                           and owner_id = $2), $5, $6, $7)
+                           and owner_id = $2), $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 returning id;
 
 -- name: SessionGet :one
@@ -33,11 +45,19 @@ select *
 from unweave.session
 where id = $1;
 
+-- name: SessionGetByNodeID :one
+select *
+from unweave.session
+where node_id = $1
+  and provider = $2;
+
 -- name: SessionGetAllActive :many
 select *
 from unweave.session
 where status = 'initializing'
-   or status = 'running';
+   or status = 'provisioning'
+   or status = 'running'
+   or status = 'degraded';
 
 -- name: SessionUpdateConnectionInfo :exec
 update unweave.session
@@ -53,12 +73,28 @@ where project_id = $1
 order by unweave.session.created_at desc
 limit $2 offset $3;
 
+-- name: SessionProviderAccountsGet :many
+select distinct created_by, provider
+from unweave.session;
+
 -- name: SessionSetError :exec
 update unweave.session
 set status = 'error'::unweave.session_status,
     error  = $2
 where id = $1;
 
+-- name: SessionSetupFinish :exec
+update unweave.session
+set setup_finished_at = now()
+where id = $1;
+
+-- name: SessionSetupStart :one
+update unweave.session
+set setup_started_at = now()
+where id = $1
+  and setup_started_at is null
+returning id;
+
 -- name: SessionStatusUpdate :exec
 update unweave.session
 set status = $2
@@ -92,12 +128,19 @@ where public_key = $1
 
 -- name: MxSessionGet :one
 select s.id,
+       s.project_id,
        s.status,
        s.node_id,
        s.provider,
        s.region,
        s.created_at,
        s.connection_info,
+       s.labels,
+       s.template_id,
+       s.template_version,
+       s.node_type_id,
+       s.node_specs,
+       s.price,
        ssh_key.name       as ssh_key_name,
        ssh_key.public_key,
        ssh_key.created_at as ssh_key_created_at
@@ -107,12 +150,19 @@ where s.id = $1;
 
 -- name: MxSessionsGet :many
 select s.id,
+       s.project_id,
        s.status,
        s.node_id,
        s.provider,
        s.region,
        s.created_at,
        s.connection_info,
+       s.labels,
+       s.template_id,
+       s.template_version,
+       s.node_type_id,
+       s.node_specs,
+       s.price,
        ssh_key.name       as ssh_key_name,
        ssh_key.public_key,
        ssh_key.created_at as ssh_key_created_at
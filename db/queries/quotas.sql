@@ -0,0 +1,22 @@
+-- name: AccountQuotaGet :one
+select quota
+from unweave.account
+where id = $1;
+
+-- name: AccountQuotaUpdate :exec
+update unweave.account
+set quota = $2
+where id = $1;
+
+-- name: ProjectQuotaUpdate :exec
+update unweave.project
+set quota = $2
+where id = $1;
+
+-- name: SessionUsageListByAccount :many
+select *
+from unweave.session_usage
+where account_id = @account_id
+  and started_at < @until
+  and (ended_at is null or ended_at > @since)
+order by started_at;
@@ -0,0 +1,59 @@
+-- name: SweepCreate :one
+insert into unweave.sweep (name, project_id, created_by, spec, max_concurrency)
+values ($1, $2, $3, $4, $5)
+returning id;
+
+-- name: SweepGet :one
+select *
+from unweave.sweep
+where id = $1;
+
+-- name: SweepGetAllActive :many
+select *
+from unweave.sweep
+where status = 'pending'
+   or status = 'running';
+
+-- name: SweepsGet :many
+select *
+from unweave.sweep
+where project_id = $1
+order by created_at desc;
+
+-- name: SweepStatusUpdate :exec
+update unweave.sweep
+set status     = $2,
+    error      = $3,
+    updated_at = now()
+where id = $1;
+
+-- name: SweepTrialCreate :one
+insert into unweave.sweep_trial (sweep_id, index, params, command)
+values ($1, $2, $3, $4)
+returning id;
+
+-- name: SweepTrialsGet :many
+select *
+from unweave.sweep_trial
+where sweep_id = $1
+order by index;
+
+-- name: SweepTrialStart :exec
+update unweave.sweep_trial
+set status       = 'running'::unweave.pipeline_status,
+    node_type_id = $2,
+    started_at   = now()
+where id = $1;
+
+-- name: SweepTrialSetSession :exec
+update unweave.sweep_trial
+set session_id = $2
+where id = $1;
+
+-- name: SweepTrialFinish :exec
+update unweave.sweep_trial
+set status      = $2,
+    exit_code   = $3,
+    error       = $4,
+    finished_at = now()
+where id = $1;
@@ -0,0 +1,39 @@
+-- name: SessionTemplateCreate :one
+insert into unweave.session_template (name, project_id, created_by)
+values ($1, $2, $3)
+returning id;
+
+-- name: SessionTemplateGet :one
+select *
+from unweave.session_template
+where id = $1;
+
+-- name: SessionTemplatesGet :many
+select *
+from unweave.session_template
+where project_id = $1
+order by name;
+
+-- name: SessionTemplateBumpVersion :one
+update unweave.session_template
+set latest_version = latest_version + 1,
+    name           = $2,
+    updated_at     = now()
+where id = $1
+returning latest_version;
+
+-- name: SessionTemplateVersionCreate :exec
+insert into unweave.session_template_version (template_id, version, spec, created_by)
+values ($1, $2, $3, $4);
+
+-- name: SessionTemplateVersionGet :one
+select *
+from unweave.session_template_version
+where template_id = $1
+  and version = $2;
+
+-- name: SessionTemplateVersionsGet :many
+select *
+from unweave.session_template_version
+where template_id = $1
+order by version desc;
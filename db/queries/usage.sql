@@ -0,0 +1,28 @@
+-- name: SessionUsageStart :exec
+insert into unweave.session_usage (session_id, project_id, account_id, provider, node_type_id,
+                                   price, labels, gpus)
+values ($1, $2, $3, $4, $5, $6, $7, $8)
+on conflict (session_id) do nothing;
+
+-- name: SessionUsageEnd :exec
+update unweave.session_usage
+set ended_at = now()
+where session_id = $1
+  and ended_at is null;
+
+-- name: SessionUsageList :many
+select u.*
+from unweave.session_usage as u
+         join unweave.project as p on u.project_id = p.id
+where p.owner_id = @owner_id
+  and u.started_at < @until
+  and (u.ended_at is null or u.ended_at > @since)
+order by u.started_at;
+
+-- name: SessionUsageListByProject :many
+select *
+from unweave.session_usage
+where project_id = @project_id
+  and started_at < @until
+  and (ended_at is null or ended_at > @since)
+order by started_at;
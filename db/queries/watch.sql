@@ -0,0 +1,9 @@
+-- name: SessionWatchDecisionAdd :exec
+insert into unweave.session_watch_decision (session_id, action, reason, attempt, error)
+values ($1, $2, $3, $4, $5);
+
+-- name: SessionWatchDecisionsGet :many
+select *
+from unweave.session_watch_decision
+where session_id = $1
+order by created_at, id;
@@ -0,0 +1,65 @@
+-- name: WebhookCreate :one
+insert into unweave.webhook (project_id, url, secret, events)
+values ($1, $2, $3, $4)
+returning id;
+
+-- name: WebhookGet :one
+select *
+from unweave.webhook
+where id = $1;
+
+-- name: WebhooksList :many
+select *
+from unweave.webhook
+where project_id = $1
+order by created_at;
+
+-- name: WebhookDelete :exec
+delete
+from unweave.webhook
+where id = $1;
+
+-- name: WebhookDeliveryCreate :one
+insert into unweave.webhook_delivery (webhook_id, event_id, event_type, payload)
+values ($1, $2, $3, $4)
+returning id;
+
+-- name: WebhookDeliveryGet :one
+select *
+from unweave.webhook_delivery
+where id = $1;
+
+-- name: WebhookDeliveriesList :many
+select *
+from unweave.webhook_delivery
+where webhook_id = $1
+order by created_at desc
+limit 100;
+
+-- name: WebhookDeliveriesClaim :many
+update unweave.webhook_delivery
+set next_attempt_at = now() + interval '1 minute'
+where id in (select id
+             from unweave.webhook_delivery as d
+             where d.status = 'pending'
+               and d.next_attempt_at <= now()
+             order by d.next_attempt_at
+             limit $1 for update skip locked)
+returning *;
+
+-- name: WebhookDeliveryAttempt :exec
+update unweave.webhook_delivery
+set status           = $2,
+    attempts         = attempts + 1,
+    next_attempt_at  = $3,
+    last_attempt_at  = now(),
+    last_status_code = $4,
+    last_error       = $5
+where id = $1;
+
+-- name: WebhookDeliveryRedeliver :exec
+update unweave.webhook_delivery
+set status          = 'pending',
+    attempts        = 0,
+    next_attempt_at = now()
+where id = $1;
@@ -0,0 +1,106 @@
+// Package events carries the state changes of sessions, builds and jobs between the
+// replicas of the API, e.g. to serve live event streams and webhooks for resources
+// managed by another replica.
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/unweave/unweave/api/types"
+)
+
+// subscriptionBuffer is how many events a subscriber can fall behind before events are
+// dropped for it.
+const subscriptionBuffer = 64
+
+// Bus publishes events to all subscribers, on every replica of the API.
+type Bus interface {
+	// Publish sends the event to all subscribers whose filter matches it.
+	Publish(ctx context.Context, e types.Event) error
+	// Subscribe returns a subscription to the events that match the filter. The
+	// subscription must be closed once it's not needed anymore.
+	Subscribe(filter Filter) *Subscription
+}
+
+// Filter selects events. Empty fields match all events.
+type Filter struct {
+	ProjectID string
+	Types     []types.EventType
+}
+
+func (f Filter) Match(e types.Event) bool {
+	if f.ProjectID != "" && f.ProjectID != e.ProjectID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type || t == types.EventAll {
+			return true
+		}
+	}
+	return false
+}
+
+type Subscription struct {
+	// C receives the events of the subscription. It is closed when the subscription is.
+	C <-chan types.Event
+
+	c      chan types.Event
+	filter Filter
+	broker *Broker
+}
+
+// Close stops the delivery of events to the subscription. It is safe to call more than
+// once.
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// Broker fans events out to the subscribers of this process. Slow subscribers miss
+// events rather than holding up everyone else. Bus implementations use it to dispatch
+// the events they receive.
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func (b *Broker) Subscribe(filter Filter) *Subscription {
+	c := make(chan types.Event, subscriptionBuffer)
+	s := &Subscription{C: c, c: c, filter: filter, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = map[*Subscription]struct{}{}
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+func (b *Broker) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.c)
+}
+
+// Dispatch sends the event to the local subscribers whose filter matches it.
+func (b *Broker) Dispatch(e types.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package memory is an event bus that only delivers events within the process. It is
+// meant for tests and single replica deployments.
+package memory
+
+import (
+	"context"
+
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/events"
+)
+
+type Bus struct {
+	events.Broker
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Publish(ctx context.Context, e types.Event) error {
+	b.Dispatch(e)
+	return nil
+}
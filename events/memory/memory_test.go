@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/events"
+)
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+
+	all := bus.Subscribe(events.Filter{})
+	project := bus.Subscribe(events.Filter{ProjectID: "p1", Types: []types.EventType{types.EventSessionRunning}})
+
+	published := []types.Event{
+		{ID: "ev_1", Type: types.EventSessionRunning, ProjectID: "p1"},
+		{ID: "ev_2", Type: types.EventSessionRunning, ProjectID: "p2"},
+		{ID: "ev_3", Type: types.EventBuildFailed, ProjectID: "p1"},
+	}
+	for _, e := range published {
+		if err := bus.Publish(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"ev_1", "ev_2", "ev_3"} {
+		if e := <-all.C; e.ID != want {
+			t.Fatalf("got event %s, want %s", e.ID, want)
+		}
+	}
+	if e := <-project.C; e.ID != "ev_1" {
+		t.Fatalf("got event %s, want ev_1", e.ID)
+	}
+	select {
+	case e := <-project.C:
+		t.Fatalf("got unexpected event %s", e.ID)
+	default:
+	}
+
+	project.Close()
+	project.Close()
+	if _, ok := <-project.C; ok {
+		t.Fatal("channel of closed subscription is open")
+	}
+	if err := bus.Publish(ctx, published[0]); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-all.C; e.ID != "ev_1" {
+		t.Fatalf("got event %s, want ev_1", e.ID)
+	}
+}
@@ -0,0 +1,96 @@
+// Package postgres is an event bus carried over Postgres LISTEN/NOTIFY. Every replica of
+// the API listens on the same channel so events published by one replica reach the
+// subscribers of all of them.
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/unweave/unweave/api/types"
+	"github.com/unweave/unweave/db"
+	"github.com/unweave/unweave/events"
+)
+
+// channel is the notification channel events are published on.
+const channel = "unweave_events"
+
+// maxPayloadSize is the largest notification payload Postgres accepts.
+const maxPayloadSize = 8000
+
+var reconnectInterval = 5 * time.Second
+
+type Bus struct {
+	events.Broker
+
+	q   db.Querier
+	url string
+}
+
+// NewBus returns a bus that publishes with q and listens on a dedicated connection to
+// the database at url. Events are only received once Listen is running.
+func NewBus(q db.Querier, url string) *Bus {
+	return &Bus{q: q, url: url}
+}
+
+func (b *Bus) Publish(ctx context.Context, e types.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if len(payload) > maxPayloadSize {
+		return fmt.Errorf("event %s is too large to publish (%d bytes)", e.ID, len(payload))
+	}
+	params := db.EventNotifyParams{Channel: channel, Payload: string(payload)}
+	if err = b.q.EventNotify(ctx, params); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// Listen receives the events published by all replicas until the context is cancelled.
+// Events published while the connection is down are lost.
+func (b *Bus) Listen(ctx context.Context) {
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Lost event bus connection, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+func (b *Bus) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, b.url)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "listen "+channel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		e := types.Event{}
+		if err = json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal event")
+			continue
+		}
+		b.Dispatch(e)
+	}
+}
@@ -26,7 +26,7 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to database")
 	}
-	db.Q = db.New(conn)
+	db.Use(conn)
 
 	// Initialize unweave from environment variables
 	runtimeCfg := &EnvInitializer{}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +20,20 @@ import (
 
 const apiURL = "https://cloud.lambdalabs.com/api/v1/"
 
+// gpuCountRe matches the GPU count in instance type names such as gpu_8x_a100.
+var gpuCountRe = regexp.MustCompile(`^gpu_(\d+)x_`)
+
+// gpuCount returns the number of GPUs of an instance type. The API doesn't return it so
+// it's parsed from the name.
+func gpuCount(instanceType string) int {
+	m := gpuCountRe.FindStringSubmatch(instanceType)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
 // err400 can happen when ll doesn't have enough capacity to create the instance
 func err400(msg string, err error) *types.Error {
 	return &types.Error{
@@ -226,8 +242,15 @@ func (s *Session) HealthCheck(ctx context.Context) error {
 	return err
 }
 
-func (s *Session) InitNode(ctx context.Context, sshKey types.SSHKey, nodeTypeID string, region *string) (types.Node, error) {
-	log.Ctx(ctx).Debug().Msgf("Launching instance with SSH key %q", sshKey.Name)
+func (s *Session) InitNode(ctx context.Context, sshKeys []types.SSHKey, nodeTypeID string, region *string) (types.Node, error) {
+	if len(sshKeys) == 0 {
+		return types.Node{}, fmt.Errorf("at least one ssh key is required")
+	}
+	keyNames := make([]string, len(sshKeys))
+	for i, k := range sshKeys {
+		keyNames[i] = k.Name
+	}
+	log.Ctx(ctx).Debug().Msgf("Launching instance with SSH keys %q", keyNames)
 
 	if region == nil {
 		var err error
@@ -245,7 +268,7 @@ func (s *Session) InitNode(ctx context.Context, sshKey types.SSHKey, nodeTypeID
 		Name:             tools.Stringy("uw-" + random.GenerateRandomPhrase(3, "-")),
 		Quantity:         tools.Inty(1),
 		RegionName:       *region,
-		SshKeyNames:      []string{sshKey.Name},
+		SshKeyNames:      keyNames,
 	}
 
 	res, err := s.client.LaunchInstanceWithResponse(ctx, req)
@@ -306,12 +329,55 @@ func (s *Session) InitNode(ctx context.Context, sshKey types.SSHKey, nodeTypeID
 		ID:       res.JSON200.Data.InstanceIds[0],
 		TypeID:   nodeTypeID,
 		Region:   *region,
-		KeyPair:  sshKey,
+		KeyPair:  sshKeys[0],
 		Status:   types.StatusInitializing,
 		Provider: types.LambdaLabsProvider,
 	}, nil
 }
 
+func (s *Session) ListNodes(ctx context.Context) ([]types.Node, error) {
+	log.Ctx(ctx).Debug().Msgf("Listing instances")
+
+	res, err := s.client.ListInstancesWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if res.JSON200 == nil {
+		if res.JSON401 != nil {
+			return nil, err401(res.JSON401.Error.Message, nil)
+		}
+		if res.JSON403 != nil {
+			return nil, err403(res.JSON403.Error.Message, nil)
+		}
+		return nil, errUnknown(res.StatusCode(), nil)
+	}
+
+	nodes := make([]types.Node, 0, len(res.JSON200.Data))
+	for _, instance := range res.JSON200.Data {
+		status, err := sessionStatus(instance.Status)
+		if err != nil {
+			log.Ctx(ctx).Warn().Msgf("Skipping instance %s with unknown status %s", instance.Id, instance.Status)
+			continue
+		}
+		node := types.Node{
+			ID:       instance.Id,
+			Status:   status,
+			Provider: types.LambdaLabsProvider,
+		}
+		if instance.InstanceType != nil {
+			node.TypeID = instance.InstanceType.Name
+		}
+		if instance.Region != nil {
+			node.Region = instance.Region.Name
+		}
+		if len(instance.SshKeyNames) > 0 {
+			node.KeyPair = types.SSHKey{Name: instance.SshKeyNames[0]}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
 func (s *Session) ListNodeTypes(ctx context.Context, filterAvailable bool) ([]types.NodeType, error) {
 	log.Ctx(ctx).Debug().Msgf("Listing instance availability")
 
@@ -345,6 +411,7 @@ func (s *Session) ListNodeTypes(ctx context.Context, filterAvailable bool) ([]ty
 				VCPUs:     data.InstanceType.Specs.Vcpus,
 				Memory:    data.InstanceType.Specs.MemoryGib,
 				GPUMemory: nil,
+				GPUs:      gpuCount(id),
 			},
 		}
 
@@ -411,7 +478,16 @@ func (s *Session) NodeStatus(ctx context.Context, nodeID string) (types.SessionS
 		return "", errUnknown(res.StatusCode(), nil)
 	}
 
-	switch res.JSON200.Data.Status {
+	status, err := sessionStatus(res.JSON200.Data.Status)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Unknown lambda status %s", res.JSON200.Data.Status)
+		return "", err
+	}
+	return status, nil
+}
+
+func sessionStatus(status client.InstanceStatus) (types.SessionStatus, error) {
+	switch status {
 	case client.Active:
 		return types.StatusRunning, nil
 	case client.Booting:
@@ -421,8 +497,7 @@ func (s *Session) NodeStatus(ctx context.Context, nodeID string) (types.SessionS
 	case client.Unhealthy:
 		return types.StatusError, nil
 	default:
-		log.Ctx(ctx).Error().Msgf("Unknown lambda status %s", res.JSON200.Data.Status)
-		return "", fmt.Errorf("unknown lambda status %s", res.JSON200.Data.Status)
+		return "", fmt.Errorf("unknown lambda status %s", status)
 	}
 }
 
@@ -475,6 +550,7 @@ func (s *Session) Watch(ctx context.Context, nodeID string) (<-chan types.Sessio
 				status, e := s.NodeStatus(ctx, nodeID)
 				if e != nil {
 					errch <- fmt.Errorf("failed to get node state: %w", e)
+					continue
 				}
 				if status == currentStatus {
 					continue
@@ -41,9 +41,15 @@ type Session interface {
 	// implemented at. For example, it could be implemented at a VM level for a bare-metal
 	// provider, at a container level, batch job level, etc. In each case, the node must
 	// be accessible via SSH.
-	InitNode(ctx context.Context, sshKey types.SSHKey, nodeTypeID string, region *string) (node types.Node, err error)
+	//
+	// All sshKeys must already exist with the provider and are given access to the node.
+	// The first one is the node's KeyPair.
+	InitNode(ctx context.Context, sshKeys []types.SSHKey, nodeTypeID string, region *string) (node types.Node, err error)
 	// ListSSHKeys returns a list of all SSH keys associated with the provider.
 	ListSSHKeys(ctx context.Context) ([]types.SSHKey, error)
+	// ListNodes returns all nodes on the provider account, including ones that weren't
+	// launched by Unweave. The KeyPair of each node only has its name set.
+	ListNodes(ctx context.Context) ([]types.Node, error)
 	// ListNodeTypes returns a list of all node types available on the provider.
 	ListNodeTypes(ctx context.Context, filterAvailable bool) ([]types.NodeType, error)
 	// NodeStatus returns the status of the node running a session.